@@ -1,13 +1,82 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/albakov/go-currency-exchange/dbinit"
 	"github.com/albakov/go-currency-exchange/internal/app"
+	"github.com/albakov/go-currency-exchange/internal/cli"
 	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	c := config.MustNew()
-	dbinit.New(c).MustCreateDatabaseIfNotExists()
-	app.New(c).MustStart()
+	os.Exit(run())
+}
+
+func run() int {
+	c, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "config: %v\n", err)
+
+		return 2
+	}
+
+	err = logging.Setup(c.LogLevel)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "log_level: %v\n", err)
+
+		return 1
+	}
+
+	// the server and the commands managing API keys need the database, printing the configuration does not
+	if len(args) == 0 || cli.NeedsDatabase(args) {
+		err = dbinit.New(c).CreateDatabaseIfNotExists()
+		if err != nil {
+			slog.Error("database", "error", err)
+
+			return 1
+		}
+	}
+
+	if len(args) > 0 {
+		return cli.New(c, os.Stdout, os.Stderr).Run(args)
+	}
+
+	shutdownTracing, err := tracing.Setup(c)
+	if err != nil {
+		slog.Error("tracing", "error", err)
+
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = app.New(c).Run(ctx)
+
+	// spans of the shutdown are flushed as well
+	tracingErr := shutdownTracing(context.Background())
+	if tracingErr != nil {
+		slog.Error("tracing", "error", tracingErr)
+	}
+
+	if err != nil {
+		slog.Error("server", "error", err)
+
+		return 1
+	}
+
+	return 0
 }
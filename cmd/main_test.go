@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// waitFor polls the URL until it answers with the status, or fails t after the timeout.
+func waitFor(t *testing.T, url string, status int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		response, err := http.Get(url)
+		if err == nil {
+			_ = response.Body.Close()
+
+			if response.StatusCode == status {
+				return
+			}
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not answer with %d in %s, last error %v", url, status, timeout, err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunShutsDownOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+	path := filepath.Join(dir, "app.toml")
+
+	options := fmt.Sprintf(
+		"host = '127.0.0.1'\nport = %d\nabs_path_to_database = '%s'\nshutdown_delay = 1\nshutdown_timeout = 5\n",
+		port,
+		filepath.Join(dir, "sqlite.db"),
+	)
+
+	err := os.WriteFile(path, []byte(options), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"currency-exchange", "--config", path}
+
+	code := make(chan int, 1)
+	go func() { code <- run() }()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitFor(t, base+"/readyz", http.StatusOK, 5*time.Second)
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// readiness fails while the server still serves during the shutdown delay
+	waitFor(t, base+"/readyz", http.StatusServiceUnavailable, time.Second)
+
+	select {
+	case c := <-code:
+		if c != 0 {
+			t.Errorf("exit code %d, want 0", c)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("server not stopped")
+	}
+
+	_, err = http.Get(base + "/healthz")
+	if err == nil {
+		t.Error("connections accepted after the shutdown")
+	}
+}
@@ -1,84 +1,257 @@
 package dbinit
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"github.com/albakov/go-currency-exchange/internal/config"
 	_ "github.com/mattn/go-sqlite3"
 	"os"
 )
 
+// migrations are applied in order, each one exactly once. The index of the last
+// applied migration plus one is stored in PRAGMA user_version.
+var migrations = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS Currencies (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	Code VARCHAR(255) NOT NULL UNIQUE,
+    	FullName VARCHAR(255) NOT NULL,
+    	Sign VARCHAR(255) NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS ExchangeRates (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	BaseCurrencyId INT NOT NULL,
+    	TargetCurrencyId INT NOT NULL,
+    	Rate DECIMAL(6) NOT NULL,
+    	FOREIGN KEY (BaseCurrencyId) REFERENCES Currencies (ID) ON DELETE CASCADE ON UPDATE NO ACTION,
+    	FOREIGN KEY (TargetCurrencyId) REFERENCES Currencies (ID) ON DELETE CASCADE ON UPDATE NO ACTION,
+    	UNIQUE(BaseCurrencyId, TargetCurrencyId) ON CONFLICT ABORT)`,
+	},
+	{
+		`ALTER TABLE ExchangeRates ADD COLUMN UpdatedAt DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+		`UPDATE ExchangeRates SET UpdatedAt = CURRENT_TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS ExchangeRatesUpdatedAt ON ExchangeRates (UpdatedAt)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS Webhooks (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	Url VARCHAR(2048) NOT NULL,
+    	Secret VARCHAR(255) NOT NULL,
+    	Events VARCHAR(255) NOT NULL,
+    	CreatedAt DATETIME NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS WebhookDeliveries (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	WebhookId INT NOT NULL,
+    	EventId VARCHAR(64) NOT NULL,
+    	EventType VARCHAR(64) NOT NULL,
+    	Payload TEXT NOT NULL,
+    	Status VARCHAR(16) NOT NULL,
+    	Attempts INT NOT NULL DEFAULT 0,
+    	NextAttemptAt DATETIME NOT NULL,
+    	LastAttemptAt DATETIME,
+    	ResponseStatus INT NOT NULL DEFAULT 0,
+    	LastError TEXT NOT NULL DEFAULT '',
+    	CreatedAt DATETIME NOT NULL,
+    	FOREIGN KEY (WebhookId) REFERENCES Webhooks (ID) ON DELETE CASCADE ON UPDATE NO ACTION)`,
+		`CREATE INDEX IF NOT EXISTS WebhookDeliveriesDue ON WebhookDeliveries (Status, NextAttemptAt)`,
+		`CREATE INDEX IF NOT EXISTS WebhookDeliveriesWebhook ON WebhookDeliveries (WebhookId, ID)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS ApiKeys (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	Name VARCHAR(255) NOT NULL,
+    	Prefix VARCHAR(32) NOT NULL,
+    	Hash VARCHAR(64) NOT NULL UNIQUE,
+    	Scopes VARCHAR(255) NOT NULL,
+    	CreatedAt DATETIME NOT NULL,
+    	RevokedAt DATETIME)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS RateAudit (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	ExchangeRateId INT NOT NULL,
+    	BaseCurrencyCode VARCHAR(3) NOT NULL,
+    	TargetCurrencyCode VARCHAR(3) NOT NULL,
+    	Action VARCHAR(16) NOT NULL,
+    	OldRate DECIMAL(6),
+    	NewRate DECIMAL(6) NOT NULL,
+    	Subject VARCHAR(255) NOT NULL,
+    	RequestId VARCHAR(64) NOT NULL,
+    	ChangedAt DATETIME NOT NULL)`,
+		`CREATE INDEX IF NOT EXISTS RateAuditExchangeRate ON RateAudit (ExchangeRateId, ID)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS RateProposals (
+    	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    	Action VARCHAR(16) NOT NULL,
+    	ExchangeRateId INT NOT NULL DEFAULT 0,
+    	BaseCurrencyCode VARCHAR(3) NOT NULL,
+    	TargetCurrencyCode VARCHAR(3) NOT NULL,
+    	OldRate DECIMAL(6),
+    	Rate DECIMAL(6) NOT NULL,
+    	Status VARCHAR(16) NOT NULL,
+    	ProposedBy VARCHAR(255) NOT NULL,
+    	DecidedBy VARCHAR(255) NOT NULL DEFAULT '',
+    	Reason TEXT NOT NULL DEFAULT '',
+    	CreatedAt DATETIME NOT NULL,
+    	ExpiresAt DATETIME NOT NULL,
+    	DecidedAt DATETIME)`,
+		`CREATE INDEX IF NOT EXISTS RateProposalsStatus ON RateProposals (Status, ExpiresAt)`,
+		`ALTER TABLE RateAudit ADD COLUMN ProposalId INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE RateAudit ADD COLUMN ProposedBy VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS Usage (
+    	Client VARCHAR(255) NOT NULL,
+    	Route VARCHAR(32) NOT NULL,
+    	Day VARCHAR(10) NOT NULL,
+    	Requests INT NOT NULL,
+    	PRIMARY KEY (Client, Route, Day))`,
+		`CREATE INDEX IF NOT EXISTS UsageDay ON Usage (Day)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS IdempotencyKeys (
+    	Client VARCHAR(255) NOT NULL,
+    	Key VARCHAR(255) NOT NULL,
+    	RequestHash VARCHAR(64) NOT NULL,
+    	Status INT NOT NULL DEFAULT 0,
+    	Headers TEXT NOT NULL DEFAULT '{}',
+    	Body BLOB,
+    	CreatedAt DATETIME NOT NULL,
+    	ExpiresAt DATETIME NOT NULL,
+    	PRIMARY KEY (Client, Key))`,
+		`CREATE INDEX IF NOT EXISTS IdempotencyKeysExpiresAt ON IdempotencyKeys (ExpiresAt)`,
+	},
+	{
+		`ALTER TABLE ExchangeRates ADD COLUMN Version INT NOT NULL DEFAULT 1`,
+	},
+	{
+		// pending updates are applied only to the version they were proposed for, the ones whose rate changed
+		// since are left at version 0 and cannot be applied anymore
+		`ALTER TABLE RateProposals ADD COLUMN ExchangeRateVersion INT NOT NULL DEFAULT 0`,
+		`UPDATE RateProposals SET ExchangeRateVersion = IFNULL((SELECT Version FROM ExchangeRates
+    	WHERE ExchangeRates.ID = RateProposals.ExchangeRateId AND ExchangeRates.Rate = RateProposals.OldRate), 0)
+    	WHERE Status = 'pending' AND Action = 'update'`,
+		// proposals are made and decided by the owners of the keys rather than by the keys themselves
+		`UPDATE RateProposals SET ProposedBy = (SELECT Name FROM ApiKeys WHERE 'apikey:' || ApiKeys.Prefix = ProposedBy)
+    	WHERE ProposedBy IN (SELECT 'apikey:' || Prefix FROM ApiKeys)`,
+		`UPDATE RateProposals SET DecidedBy = (SELECT Name FROM ApiKeys WHERE 'apikey:' || ApiKeys.Prefix = DecidedBy)
+    	WHERE DecidedBy IN (SELECT 'apikey:' || Prefix FROM ApiKeys)`,
+	},
+}
+
 type DBInit struct {
 	pathToDb string
 }
 
+// SchemaVersion is the version of the schema with all migrations applied.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Version returns the version of the schema of db, the number of migrations applied to it.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+
+	err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
 func New(config *config.Config) *DBInit {
 	return &DBInit{
 		pathToDb: config.PathToDB,
 	}
 }
 
-func (d *DBInit) MustCreateDatabaseIfNotExists() {
-	if !d.mustCheckIsDatabaseExists() {
-		_, err := os.Create(d.pathToDb)
+// CreateDatabaseIfNotExists creates the file of the database if it does not exist and applies the migrations
+// not applied to it yet.
+func (d *DBInit) CreateDatabaseIfNotExists() error {
+	exists, err := d.isDatabaseExists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		file, err := os.Create(d.pathToDb)
+		if err != nil {
+			return err
+		}
+
+		err = file.Close()
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
-	d.mustCreateTablesIfNotExists()
+	return d.migrateAll()
 }
 
-func (d *DBInit) mustCheckIsDatabaseExists() bool {
+func (d *DBInit) isDatabaseExists() (bool, error) {
 	_, err := os.Stat(d.pathToDb)
 	if err != nil {
-		if os.IsExist(err) {
-			return true
-		}
-
 		if os.IsNotExist(err) {
-			return false
+			return false, nil
 		}
 
-		panic(err)
+		return false, err
 	}
 
-	return true
+	return true, nil
 }
 
-func (d *DBInit) mustCreateTablesIfNotExists() {
+func (d *DBInit) migrateAll() (err error) {
 	db, err := sql.Open("sqlite3", d.pathToDb)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			panic(err)
+		closeErr := db.Close()
+		if err == nil {
+			err = closeErr
 		}
 	}(db)
 
-	_, err = db.Exec(
-		`CREATE TABLE IF NOT EXISTS Currencies (
-    	ID INTEGER PRIMARY KEY AUTOINCREMENT, 
-    	Code VARCHAR(255) NOT NULL UNIQUE, 
-    	FullName VARCHAR(255) NOT NULL, 
-    	Sign VARCHAR(255) NOT NULL)`,
-	)
+	version, err := Version(context.Background(), db)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	_, err = db.Exec(
-		`CREATE TABLE IF NOT EXISTS ExchangeRates (
-    	ID INTEGER PRIMARY KEY AUTOINCREMENT, 
-    	BaseCurrencyId INT NOT NULL, 
-    	TargetCurrencyId INT NOT NULL, 
-    	Rate DECIMAL(6) NOT NULL,
-    	FOREIGN KEY (BaseCurrencyId) REFERENCES Currencies (ID) ON DELETE CASCADE ON UPDATE NO ACTION,
-    	FOREIGN KEY (TargetCurrencyId) REFERENCES Currencies (ID) ON DELETE CASCADE ON UPDATE NO ACTION,
-    	UNIQUE(BaseCurrencyId, TargetCurrencyId) ON CONFLICT ABORT)`,
-	)
+	for i := version; i < len(migrations); i++ {
+		err = d.migrate(db, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *DBInit) migrate(db *sql.DB, i int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range migrations[i] {
+		_, err = tx.Exec(query)
+		if err != nil {
+			_ = tx.Rollback()
+
+			return fmt.Errorf("migration %d: %v", i+1, err)
+		}
+	}
+
+	// PRAGMA does not accept bound parameters
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
 	if err != nil {
-		panic(err)
+		_ = tx.Rollback()
+
+		return fmt.Errorf("migration %d: %v", i+1, err)
 	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,102 @@
+package dbinit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateDatabaseIfNotExists(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, pathToDb string)
+		version int
+		failing bool
+	}{
+		{name: "new database", version: SchemaVersion()},
+		{
+			name: "migrated database",
+			prepare: func(t *testing.T, pathToDb string) {
+				err := New(&config.Config{PathToDB: pathToDb}).CreateDatabaseIfNotExists()
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			version: SchemaVersion(),
+		},
+		{
+			name: "partly migrated database",
+			prepare: func(t *testing.T, pathToDb string) {
+				db, err := sql.Open("sqlite3", pathToDb)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer db.Close()
+
+				err = New(&config.Config{PathToDB: pathToDb}).migrate(db, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			version: SchemaVersion(),
+		},
+		{
+			name: "directory missing",
+			prepare: func(t *testing.T, pathToDb string) {
+				err := os.Remove(filepath.Dir(pathToDb))
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			failing: true,
+		},
+		{
+			name: "not a database",
+			prepare: func(t *testing.T, pathToDb string) {
+				err := os.WriteFile(pathToDb, []byte("not a database, but long enough to be read as its header"), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			failing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathToDb := filepath.Join(t.TempDir(), "data", "sqlite.db")
+
+			err := os.Mkdir(filepath.Dir(pathToDb), 0o700)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(t, pathToDb)
+			}
+
+			err = New(&config.Config{PathToDB: pathToDb}).CreateDatabaseIfNotExists()
+			if (err != nil) != tt.failing {
+				t.Fatalf("error %v, want failing %v", err, tt.failing)
+			}
+
+			if tt.failing {
+				return
+			}
+
+			db, err := sql.Open("sqlite3", pathToDb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			version, err := Version(context.Background(), db)
+			if err != nil || version != tt.version {
+				t.Errorf("version %d and error %v, want %d", version, err, tt.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
+	"github.com/albakov/go-currency-exchange/internal/requestid"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// accessWriter counts the status and the bytes of a response for the access log.
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController flush the streamed responses.
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets the rate stream upgrade the connection to a WebSocket.
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+
+	w.status = http.StatusSwitchingProtocols
+
+	return hijacker.Hijack()
+}
+
+// withRequestLogger propagates the X-Request-ID of the request, or generates one, and adds a logger carrying it
+// to the context of the request.
+func withRequestLogger(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(requestid.Header)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+
+	w.Header().Set(requestid.Header, id)
+
+	ctx := requestid.NewContext(r.Context(), id)
+	ctx = logging.NewContext(ctx, slog.Default().With("requestId", id))
+
+	return r.WithContext(ctx)
+}
+
+// logAccess serves the request with the handler in a span continuing the trace propagated by the client, logs its
+// method, route, status, latency and bytes and counts it in the metrics.
+func (a *App) logAccess(w http.ResponseWriter, r *http.Request, handler http.Handler) {
+	start := time.Now()
+	_, route := a.mux.Handler(r)
+	writer := &accessWriter{ResponseWriter: w}
+
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.StartServer(
+		ctx,
+		strings.TrimSpace(r.Method+" "+route),
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.HTTPRoute(route),
+		semconv.URLPath(r.URL.Path),
+	)
+	defer span.End()
+
+	r = r.WithContext(ctx)
+
+	handler.ServeHTTP(writer, r)
+
+	status := writer.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	metrics.ObserveRequest(route, r.Method, status, start)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	logging.FromContext(r.Context()).Log(
+		r.Context(),
+		level,
+		"request",
+		"method", r.Method,
+		"route", route,
+		"path", r.URL.Path,
+		"status", status,
+		"latencyMs", float64(time.Since(start).Microseconds())/1000,
+		"bytes", writer.bytes,
+	)
+}
@@ -1,59 +1,488 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/certs"
 	"github.com/albakov/go-currency-exchange/internal/config"
 	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/controller/apikeys"
+	auditController "github.com/albakov/go-currency-exchange/internal/controller/audit"
 	"github.com/albakov/go-currency-exchange/internal/controller/currencies"
 	"github.com/albakov/go-currency-exchange/internal/controller/exchange"
 	"github.com/albakov/go-currency-exchange/internal/controller/exchangerates"
+	healthController "github.com/albakov/go-currency-exchange/internal/controller/health"
+	"github.com/albakov/go-currency-exchange/internal/controller/proposals"
+	"github.com/albakov/go-currency-exchange/internal/controller/stream"
+	usageController "github.com/albakov/go-currency-exchange/internal/controller/usage"
+	"github.com/albakov/go-currency-exchange/internal/controller/webhooks"
+	"github.com/albakov/go-currency-exchange/internal/cors"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/grpcapi"
+	"github.com/albakov/go-currency-exchange/internal/health"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
+	"github.com/albakov/go-currency-exchange/internal/openapi"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	storageAPIKeys "github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	storageIdempotency "github.com/albakov/go-currency-exchange/internal/storage/idempotency"
+	storageRateAudit "github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+	storageRateProposals "github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	storageUsage "github.com/albakov/go-currency-exchange/internal/storage/usage"
+	storageWebhooks "github.com/albakov/go-currency-exchange/internal/storage/webhooks"
+	dispatcher "github.com/albakov/go-currency-exchange/internal/webhooks"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type App struct {
-	mux                     *http.ServeMux
-	config                  *config.Config
+	mux           *http.ServeMux
+	config        *config.Config
+	live          *config.Live
+	v1            *api
+	v2            *api
+	stream        *stream.Controller
+	hub           *events.Hub
+	webhooks      *webhooks.Controller
+	apiKeys       *apikeys.Controller
+	audit         *auditController.Controller
+	proposals     *proposals.Controller
+	usageReport   *usageController.Controller
+	health        *healthController.Controller
+	checker       *health.Checker
+	dispatcher    *dispatcher.Dispatcher
+	authenticator *auth.Authenticator
+	grpc          *grpcapi.Server
+	limits        *limits
+	corsPolicy    atomic.Pointer[cors.Policy]
+	idempotency   storageIdempotency.StorageIdempotency
+}
+
+// api holds the controllers serving one version of the API.
+type api struct {
+	commonController        controller.ServerResponse
 	exchangeController      *exchange.Controller
 	currenciesController    *currencies.Controller
 	exchangeRatesController *exchangerates.Controller
 }
 
-func New(config *config.Config) *App {
-	commonController := controller.New()
+func New(c *config.Config) *App {
+	catalog := i18n.MustNew(c.DefaultLanguage)
+	hub := events.NewHub()
+	webhooksStorage := storageWebhooks.New(c.PathToDB)
+	webhooksDispatcher := dispatcher.New(c, webhooksStorage)
+	s := &storages{
+		currencies:    storageCurrencies.New(c.PathToDB, webhooksDispatcher, webhooksDispatcher),
+		exchangeRates: storageExchangeRates.New(c.PathToDB, events.Publishers{hub, webhooksDispatcher}, webhooksDispatcher),
+		webhooks:      webhooksStorage,
+		apiKeys:       storageAPIKeys.New(c.PathToDB),
+		rateAudit:     storageRateAudit.New(c.PathToDB),
+		usage:         storageUsage.New(c.PathToDB),
+	}
+	s.recorder = audit.New(s.rateAudit)
+	s.approval = approval.New(
+		c,
+		storageRateProposals.New(c.PathToDB),
+		s.currencies,
+		s.exchangeRates,
+		s.recorder,
+	)
+	authenticator := auth.New(s.apiKeys, auth.NewJWTVerifier(c))
+	metrics.RegisterInventory(s.inventory)
+	v2Controller := controller.New(controller.V2, catalog)
+	checker := health.New(c, s.exchangeRates)
+	live := config.NewLive(c)
+	limits := newLimits(c, s.usage)
+	grpcServer := grpcapi.New(live, s.currencies, s.exchangeRates, authenticator, limits, s.recorder, s.approval, catalog)
 
-	return &App{
-		mux:                     http.NewServeMux(),
-		config:                  config,
-		exchangeController:      exchange.New(config, commonController),
-		currenciesController:    currencies.New(config, commonController),
-		exchangeRatesController: exchangerates.New(config, commonController),
+	a := &App{
+		mux:           http.NewServeMux(),
+		config:        c,
+		live:          live,
+		v1:            newAPI(s, live, controller.New(controller.V1, catalog)),
+		v2:            newAPI(s, live, v2Controller),
+		stream:        stream.New(live, v2Controller, hub, s.exchangeRates),
+		hub:           hub,
+		webhooks:      webhooks.New(v2Controller, s.webhooks),
+		apiKeys:       apikeys.New(v2Controller, s.apiKeys, authenticator),
+		audit:         auditController.New(v2Controller, s.rateAudit),
+		proposals:     proposals.New(v2Controller, s.approval),
+		usageReport:   usageController.New(v2Controller, s.usage),
+		health:        healthController.New(v2Controller, checker),
+		checker:       checker,
+		dispatcher:    webhooksDispatcher,
+		authenticator: authenticator,
+		grpc:          grpcServer,
+		limits:        limits,
+		idempotency:   storageIdempotency.New(c.PathToDB),
 	}
+
+	a.corsPolicy.Store(cors.New(c.CORS))
+
+	return a
+}
+
+// storages, along with the services built on them, are shared by all controllers.
+type storages struct {
+	currencies    storageCurrencies.StorageCurrencies
+	exchangeRates storageExchangeRates.StorageExchangeRates
+	webhooks      storageWebhooks.StorageWebhooks
+	apiKeys       storageAPIKeys.StorageAPIKeys
+	rateAudit     storageRateAudit.StorageRateAudit
+	usage         storageUsage.StorageUsage
+	recorder      *audit.Recorder
+	approval      *approval.Service
 }
 
-func (a *App) MustStart() {
+// inventory reads the figures of the stored data reported by the metrics.
+func (s *storages) inventory() (metrics.Inventory, error) {
+	ctx := context.Background()
+
+	_, currenciesTotal, err := s.currencies.List(
+		ctx,
+		storageCurrencies.Filter{ListOptions: storage.ListOptions{Limit: 1}},
+	)
+	if err != nil {
+		return metrics.Inventory{}, err
+	}
+
+	oldest, pairsTotal, err := s.exchangeRates.List(ctx, storageExchangeRates.Filter{
+		ListOptions: storage.ListOptions{Limit: 1, Sort: "updated"},
+	})
+	if err != nil {
+		return metrics.Inventory{}, err
+	}
+
+	inventory := metrics.Inventory{
+		Currencies: currenciesTotal,
+		Pairs:      pairsTotal,
+	}
+
+	if len(oldest) > 0 {
+		inventory.OldestRate = oldest[0].UpdatedAt
+	}
+
+	return inventory, nil
+}
+
+func newAPI(s *storages, live *config.Live, commonController controller.ServerResponse) *api {
+	return &api{
+		commonController:     commonController,
+		exchangeController:   exchange.New(live, commonController, s.currencies, s.exchangeRates),
+		currenciesController: currencies.New(commonController, s.currencies),
+		exchangeRatesController: exchangerates.New(
+			commonController,
+			s.currencies,
+			s.exchangeRates,
+			s.recorder,
+			s.approval,
+		),
+	}
+}
+
+// Run serves the APIs and runs the background jobs until ctx is done or a server fails. It shuts down gracefully
+// then: readiness fails, the servers stop accepting connections and drain in-flight requests, rate streams end
+// and the background jobs stop, so no handle to the database is left open.
+func (a *App) Run(ctx context.Context) error {
 	a.SetRoutes()
 
-	err := http.ListenAndServe(fmt.Sprintf("%s:%d", a.config.Host, a.config.Port), a)
+	server := &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", a.config.Host, a.config.Port),
+		Handler:           a,
+		ReadTimeout:       time.Duration(a.config.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(a.config.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(a.config.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(a.config.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    a.config.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	server.RegisterOnShutdown(a.hub.Close)
+
+	reloader, err := certs.New(a.config)
+	if err != nil {
+		return err
+	}
+
+	grpcOptions := []grpc.ServerOption{grpc.ChainUnaryInterceptor(a.grpc.Log, a.grpc.Authorize, a.grpc.Limit)}
+
+	if reloader != nil {
+		server.TLSConfig = reloader.HTTPConfig()
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(reloader.GRPCConfig())))
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+
+	if a.config.GRPCPort != 0 {
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", a.config.Host, a.config.GRPCPort))
+		if err != nil {
+			_ = listener.Close()
+
+			return err
+		}
+
+		grpcServer = grpc.NewServer(grpcOptions...)
+		a.grpc.Register(grpcServer)
+	}
+
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.dispatcher.Run(jobs)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.watchConfig(jobs)
+	}()
+
+	if reloader != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reloader.Watch(jobs)
+		}()
+	}
+
+	failed := make(chan error, 2)
+
+	go func() {
+		var err error
+		if reloader != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+
+		if !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	if grpcServer != nil {
+		go func() {
+			err := grpcServer.Serve(grpcListener)
+			if err != nil {
+				failed <- err
+			}
+		}()
+	}
+
+	slog.Info("started", "addr", server.Addr, "grpcPort", a.config.GRPCPort, "tls", reloader != nil)
+
+	select {
+	case <-ctx.Done():
+		err = nil
+	case err = <-failed:
+	}
+
+	slog.Info("shutting down")
+	a.checker.ShutDown()
+
+	if err == nil {
+		time.Sleep(time.Duration(a.config.ShutdownDelay) * time.Second)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(a.config.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	shutdownErr := server.Shutdown(shutdownCtx)
+
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+
+	stopJobs()
+	wg.Wait()
+
+	slog.Info("stopped")
+
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	return shutdownErr
+}
+
+// stopGRPC waits for the calls in progress to complete, or cancels them once ctx is done.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
 	}
 }
 
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	a.setCORS(w)
+	r = withRequestLogger(w, r)
+
+	a.logAccess(w, a.authenticator.Request(r), http.HandlerFunc(a.serve))
+}
+
+// serve answers the CORS preflight requests of all routes and serves other requests with the CORS headers set,
+// once their client presented a certificate if one is required.
+func (a *App) serve(w http.ResponseWriter, r *http.Request) {
+	if a.corsPolicy.Load().Apply(w, r) {
+		return
+	}
+
+	if !a.verifiedClient(w, r) {
+		return
+	}
+
 	a.mux.ServeHTTP(w, r)
 }
 
+// probes are the routes served to clients without a certificate, so health checks need none.
+var probes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// verifiedClient reports whether the client of r presented a verified certificate if tls_client_ca requires one,
+// otherwise it responds with the error.
+func (a *App) verifiedClient(w http.ResponseWriter, r *http.Request) bool {
+	if a.config.TLSClientCA == "" || r.TLS == nil || probes[r.URL.Path] || certs.Verified(r.TLS) {
+		return true
+	}
+
+	a.v2.commonController.ShowError(w, r, http.StatusUnauthorized, controller.CodeClientCertificateRequired)
+
+	return false
+}
+
+// SetRoutes mounts v2 under /v2 and v1 under /v1 as well as without a prefix, as it was served before versioning.
 func (a *App) SetRoutes() {
-	a.mux.HandleFunc("/exchange", a.exchangeController.Exchange)
-	a.mux.HandleFunc("/currencies", a.currenciesController.CurrenciesHandler)
-	a.mux.HandleFunc("/currency/{code}", a.currenciesController.CurrencyCodeHandler)
-	a.mux.HandleFunc("/exchangeRates", a.exchangeRatesController.ExchangeRatesHandler)
-	a.mux.HandleFunc("/exchangeRate/{pair}", a.exchangeRatesController.ExchangeRatesPairHandler)
+	a.setAPIRoutes("", a.v1, a.deprecated)
+	a.setAPIRoutes("/v1", a.v1, a.deprecated)
+	a.setAPIRoutes("/v2", a.v2, nil)
+
+	v2 := a.v2.commonController
+
+	a.mux.HandleFunc(
+		"/stream/rates",
+		a.limit(v2, limitRead, limitRead, a.authorize(v2, auth.ScopeRatesRead, a.stream.RatesHandler)),
+	)
+	a.mux.HandleFunc(
+		"/stream/rates/ws",
+		a.limit(v2, limitRead, limitRead, a.authorize(v2, auth.ScopeRatesRead, a.stream.RatesWebSocketHandler)),
+	)
+
+	a.mux.HandleFunc("/webhooks", a.admin(v2, a.idempotent(v2, a.webhooks.WebhooksHandler)))
+	a.mux.HandleFunc("/webhooks/{id}", a.admin(v2, a.idempotent(v2, a.webhooks.WebhookHandler)))
+	a.mux.HandleFunc("/webhooks/{id}/deliveries", a.admin(v2, a.webhooks.DeliveriesHandler))
+	a.mux.HandleFunc(
+		"/webhooks/{id}/deliveries/{deliveryId}/redeliver",
+		a.admin(v2, a.idempotent(v2, a.webhooks.RedeliverHandler)),
+	)
+
+	// responses issuing keys are not stored for replays, as they show the plain key
+	a.mux.HandleFunc("/apikeys", a.admin(v2, a.apiKeys.APIKeysHandler))
+	a.mux.HandleFunc("/apikeys/{id}", a.admin(v2, a.idempotent(v2, a.apiKeys.APIKeyHandler)))
+
+	a.mux.HandleFunc("/audit/rates", a.admin(v2, a.audit.RatesHandler))
+	a.mux.HandleFunc("/usage", a.admin(v2, a.usageReport.UsageHandler))
+
+	a.mux.HandleFunc("/proposals", a.require(v2, auth.ScopeRatesRead, a.proposals.ProposalsHandler))
+	a.mux.HandleFunc("/proposals/{id}", a.require(v2, auth.ScopeRatesRead, a.proposals.ProposalHandler))
+	a.mux.HandleFunc(
+		"/proposals/{id}/approve",
+		a.require(v2, auth.ScopeRatesApprove, a.idempotent(v2, a.proposals.ApproveHandler)),
+	)
+	a.mux.HandleFunc(
+		"/proposals/{id}/reject",
+		a.require(v2, auth.ScopeRatesApprove, a.idempotent(v2, a.proposals.RejectHandler)),
+	)
+
+	a.mux.Handle("/metrics", metrics.Handler())
+	a.mux.HandleFunc("/healthz", a.health.HealthzHandler)
+	a.mux.HandleFunc("/readyz", a.health.ReadyzHandler)
+	a.mux.HandleFunc("/version", a.health.VersionHandler)
+	a.mux.HandleFunc("/maintenance", a.admin(v2, a.health.MaintenanceHandler))
+
+	a.mux.HandleFunc("/openapi.json", openapi.SpecV1Handler)
+	a.mux.HandleFunc("/docs", openapi.DocsHandler)
+	a.mux.HandleFunc("/v1/openapi.json", openapi.SpecV1Handler)
+	a.mux.HandleFunc("/v1/docs", openapi.DocsHandler)
+	a.mux.HandleFunc("/v2/openapi.json", openapi.SpecV2Handler)
+	a.mux.HandleFunc("/v2/docs", openapi.DocsHandler)
+}
+
+func (a *App) setAPIRoutes(prefix string, api *api, wrap func(handler http.HandlerFunc) http.HandlerFunc) {
+	if wrap == nil {
+		wrap = func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	}
+
+	cc := api.commonController
+
+	// route limits the rate of the requests, authorizes them and makes them idempotent
+	route := func(readGroup string, scope string, handler http.HandlerFunc) http.HandlerFunc {
+		return wrap(a.limit(cc, readGroup, limitWrite, a.authorize(cc, scope, a.idempotent(cc, handler))))
+	}
+
+	a.mux.HandleFunc(
+		prefix+"/exchange",
+		route(limitExchange, auth.ScopeRatesRead, api.exchangeController.Exchange),
+	)
+	a.mux.HandleFunc(
+		prefix+"/currencies",
+		route(limitRead, auth.ScopeCurrenciesWrite, api.currenciesController.CurrenciesHandler),
+	)
+	a.mux.HandleFunc(
+		prefix+"/currency/{code}",
+		route(limitRead, auth.ScopeCurrenciesWrite, api.currenciesController.CurrencyCodeHandler),
+	)
+	a.mux.HandleFunc(
+		prefix+"/exchangeRates",
+		route(limitRead, auth.ScopeRatesWrite, api.exchangeRatesController.ExchangeRatesHandler),
+	)
+	a.mux.HandleFunc(
+		prefix+"/exchangeRate/{pair}",
+		route(limitRead, auth.ScopeRatesWrite, api.exchangeRatesController.ExchangeRatesPairHandler),
+	)
 }
 
-func (a *App) setCORS(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", a.config.AccessControlAllowOrigin)
-	w.Header().Set("Access-Control-Allow-Headers", a.config.AccessControlAllowHeaders)
-	w.Header().Set("Access-Control-Allow-Methods", a.config.AccessControlAllowMethods)
+// deprecated announces the deprecation of v1 and points to the v2 successor of the requested resource.
+func (a *App) deprecated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := "/v2" + strings.TrimPrefix(r.URL.Path, "/v1")
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+		if a.config.V1Sunset != "" {
+			w.Header().Set("Sunset", a.config.V1Sunset)
+		}
+
+		handler(w, r)
+	}
 }
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/cors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		sunset string
+		link   string
+	}{
+		{name: "v1", target: "/v1/exchangeRate/USDEUR", link: `</v2/exchangeRate/USDEUR>; rel="successor-version"`},
+		{name: "unprefixed alias", target: "/currencies?limit=2", link: `</v2/currencies>; rel="successor-version"`},
+		{
+			name:   "sunset",
+			target: "/v1/currencies",
+			sunset: "Wed, 01 Jul 2026 00:00:00 GMT",
+			link:   `</v2/currencies>; rel="successor-version"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{config: &config.Config{V1Sunset: tt.sunset}}
+			w := httptest.NewRecorder()
+
+			a.deprecated(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != http.StatusTeapot {
+				t.Errorf("status %d, want the one of the handler", w.Code)
+			}
+
+			if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != tt.link {
+				t.Errorf("Deprecation %q and Link %q, want true and %q", w.Header().Get("Deprecation"), w.Header().Get("Link"), tt.link)
+			}
+
+			if w.Header().Get("Sunset") != tt.sunset {
+				t.Errorf("Sunset %q, want %q", w.Header().Get("Sunset"), tt.sunset)
+			}
+		})
+	}
+}
+
+func TestInventory(t *testing.T) {
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &storages{
+		currencies:    storageCurrencies.New(c.PathToDB, nil, nil),
+		exchangeRates: storageExchangeRates.New(c.PathToDB, nil, nil),
+	}
+
+	inventory, err := s.inventory()
+	if err != nil || inventory != (metrics.Inventory{}) {
+		t.Fatalf("inventory %+v and error %v of an empty database", inventory, err)
+	}
+
+	ids := []int64{}
+
+	for _, code := range []string{"USD", "EUR", "RUB"} {
+		id, err := s.currencies.Add(context.Background(), entity.Currency{Code: code, FullName: code, Sign: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	oldest := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, updated := range []time.Time{oldest.Add(time.Hour), oldest} {
+		_, err = s.exchangeRates.Add(context.Background(), entity.ExchangeRates{
+			BaseCurrency:   entity.Currency{ID: ids[0]},
+			TargetCurrency: entity.Currency{ID: ids[i+1]},
+			Rate:           1,
+			UpdatedAt:      updated,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inventory, err = s.inventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := metrics.Inventory{Currencies: 3, Pairs: 2, OldestRate: oldest}
+	if inventory.Currencies != want.Currencies || inventory.Pairs != want.Pairs || !inventory.OldestRate.Equal(oldest) {
+		t.Errorf("inventory %+v, want %+v", inventory, want)
+	}
+}
+
+func TestServeAnswersPreflights(t *testing.T) {
+	a := &App{config: &config.Config{}, mux: http.NewServeMux()}
+	a.corsPolicy.Store(cors.New(config.CORS{AccessControlAllowOrigin: "https://example.com"}))
+
+	// the handler rejects OPTIONS, as the list routes did before preflights were answered for all of them
+	a.mux.HandleFunc("/v2/currencies", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name            string
+		method          string
+		target          string
+		origin          string
+		requestedMethod string
+		status          int
+		allowOrigin     string
+	}{
+		{
+			name:            "preflight",
+			method:          http.MethodOptions,
+			target:          "/v2/currencies",
+			origin:          "https://example.com",
+			requestedMethod: "POST",
+			status:          http.StatusNoContent,
+			allowOrigin:     "https://example.com",
+		},
+		{
+			name:            "preflight of another origin",
+			method:          http.MethodOptions,
+			target:          "/v2/currencies",
+			origin:          "https://example.org",
+			requestedMethod: "POST",
+			status:          http.StatusNoContent,
+		},
+		{
+			name:            "preflight of an unknown route",
+			method:          http.MethodOptions,
+			target:          "/v2/unknown",
+			origin:          "https://example.com",
+			requestedMethod: "GET",
+			status:          http.StatusNoContent,
+			allowOrigin:     "https://example.com",
+		},
+		{
+			name:        "cross-origin request",
+			method:      http.MethodPost,
+			target:      "/v2/currencies",
+			origin:      "https://example.com",
+			status:      http.StatusOK,
+			allowOrigin: "https://example.com",
+		},
+		{name: "OPTIONS not a preflight", method: http.MethodOptions, target: "/v2/currencies", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.requestedMethod)
+
+			w := httptest.NewRecorder()
+			a.serve(w, r)
+
+			if w.Code != tt.status || w.Header().Get("Access-Control-Allow-Origin") != tt.allowOrigin {
+				t.Errorf(
+					"status %d with origin %q allowed, want %d with %q",
+					w.Code, w.Header().Get("Access-Control-Allow-Origin"), tt.status, tt.allowOrigin,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"net/http"
+)
+
+const f = "app.App"
+
+// authorize requires the API key or the token of the request to be granted the scope. Reads need the rates:read
+// scope if reads are keyed and are open otherwise, though an invalid credential is rejected anyway.
+// CORS preflight requests carry no credentials and are always let through.
+func (a *App) authorize(
+	commonController controller.ServerResponse,
+	scope string,
+	handler http.HandlerFunc,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			handler(w, r)
+
+			return
+		}
+
+		required := scope
+
+		if isRead(r) {
+			_, err := auth.FromContext(r.Context())
+			if !a.config.KeyedReads && errors.Is(err, auth.MissingCredentialsError) {
+				handler(w, r)
+
+				return
+			}
+
+			required = auth.ScopeRatesRead
+		}
+
+		if a.allowed(w, r, commonController, required) {
+			handler(w, r)
+		}
+	}
+}
+
+// admin requires the admin scope for every method.
+func (a *App) admin(commonController controller.ServerResponse, handler http.HandlerFunc) http.HandlerFunc {
+	return a.require(commonController, auth.ScopeAdmin, handler)
+}
+
+// require requires the scope for every method, reads included.
+func (a *App) require(
+	commonController controller.ServerResponse,
+	scope string,
+	handler http.HandlerFunc,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || a.allowed(w, r, commonController, scope) {
+			handler(w, r)
+		}
+	}
+}
+
+// allowed reports whether the principal authenticated by ServeHTTP is granted the scope, or responds with the error.
+func (a *App) allowed(
+	w http.ResponseWriter,
+	r *http.Request,
+	commonController controller.ServerResponse,
+	scope string,
+) bool {
+	const op = "allowed"
+
+	principal, err := auth.FromContext(r.Context())
+	if err != nil {
+		if errors.Is(err, auth.MissingCredentialsError) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="currency-exchange"`)
+			commonController.ShowError(w, r, http.StatusUnauthorized, controller.CodeAPIKeyMissing)
+
+			return false
+		}
+
+		if errors.Is(err, auth.InvalidCredentialsError) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="currency-exchange", error="invalid_token"`)
+			commonController.ShowError(w, r, http.StatusUnauthorized, controller.CodeAPIKeyInvalid)
+
+			return false
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return false
+	}
+
+	if !principal.HasScope(scope) {
+		commonController.ShowError(w, r, http.StatusForbidden, controller.CodeAPIKeyForbidden)
+
+		return false
+	}
+
+	return true
+}
+
+func isRead(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	reader := []string{auth.ScopeRatesRead}
+	writer := []string{auth.ScopeRatesRead, auth.ScopeRatesWrite}
+
+	tests := []struct {
+		name       string
+		keyedReads bool
+		method     string
+		// scopes of the principal, none if the request carries no credential
+		scopes []string
+		err    error
+		status int
+	}{
+		{name: "open read", method: http.MethodGet, status: http.StatusOK},
+		{name: "open read with a key", method: http.MethodGet, scopes: reader, status: http.StatusOK},
+		{name: "open read with an invalid key", method: http.MethodGet, err: auth.InvalidCredentialsError, status: http.StatusUnauthorized},
+		{name: "keyed read without a key", keyedReads: true, method: http.MethodGet, status: http.StatusUnauthorized},
+		{name: "keyed read", keyedReads: true, method: http.MethodHead, scopes: reader, status: http.StatusOK},
+		{name: "keyed read without the scope", keyedReads: true, method: http.MethodGet, scopes: []string{auth.ScopeCurrenciesWrite}, status: http.StatusForbidden},
+		{name: "write without a key", method: http.MethodPatch, status: http.StatusUnauthorized},
+		{name: "write with a read key", method: http.MethodPatch, scopes: reader, status: http.StatusForbidden},
+		{name: "write", method: http.MethodPatch, scopes: writer, status: http.StatusOK},
+		{name: "write as admin", method: http.MethodPatch, scopes: []string{auth.ScopeAdmin}, status: http.StatusOK},
+		{name: "preflight", keyedReads: true, method: http.MethodOptions, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{config: &config.Config{KeyedReads: tt.keyedReads}}
+			r := httptest.NewRequest(tt.method, "/v2/exchangeRate/USDEUR", nil)
+
+			if tt.scopes != nil || tt.err != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), auth.Principal{Scopes: tt.scopes}, tt.err))
+			}
+
+			w := httptest.NewRecorder()
+
+			a.authorize(controller.New(controller.V2, i18n.MustNew("en")), auth.ScopeRatesWrite, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+
+			if (w.Code == http.StatusUnauthorized) != (w.Header().Get("WWW-Authenticate") != "") {
+				t.Errorf("WWW-Authenticate %q with status %d", w.Header().Get("WWW-Authenticate"), w.Code)
+			}
+		})
+	}
+}
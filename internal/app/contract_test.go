@@ -0,0 +1,742 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/storage/webhooks"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// document is an OpenAPI document served by the app, the responses of the routes it describes are checked
+// against it.
+type document struct {
+	name string
+	root map[string]interface{}
+	// called are the documented operations requested, as "get /currencies"
+	called map[string]bool
+}
+
+func object(node interface{}) map[string]interface{} {
+	o, _ := node.(map[string]interface{})
+
+	return o
+}
+
+func loadDocument(t *testing.T, a *App, target string) *document {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d", target, w.Code)
+	}
+
+	root := map[string]interface{}{}
+
+	err := decode(w.Body.Bytes(), &root)
+	if err != nil {
+		t.Fatalf("%s: %v", target, err)
+	}
+
+	return &document{name: target, root: root, called: map[string]bool{}}
+}
+
+// decode decodes JSON with the numbers kept as written, to tell integers from other numbers.
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+// resolve follows the $ref of node within the document.
+func (d *document) resolve(node map[string]interface{}) map[string]interface{} {
+	for node != nil {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+
+		node = d.root
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			node = object(node[key])
+		}
+	}
+
+	return nil
+}
+
+// schema returns the schema of the components of the document.
+func (d *document) schema(name string) map[string]interface{} {
+	return d.resolve(map[string]interface{}{"$ref": "#/components/schemas/" + name})
+}
+
+// check fails t if the response to the operation of the route is not described by the document: its status,
+// the headers it requires, its content type and its body.
+func (d *document) check(t *testing.T, method, route string, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	method = strings.ToLower(method)
+	at := fmt.Sprintf("%s: %s %s %d", d.name, method, route, w.Code)
+
+	operation := object(object(object(d.root["paths"])[route])[method])
+	if operation == nil {
+		t.Errorf("%s: operation not documented", at)
+
+		return
+	}
+
+	d.called[method+" "+route] = true
+
+	response := d.resolve(object(object(operation["responses"])[strconv.Itoa(w.Code)]))
+	if response == nil {
+		t.Errorf("%s: status not documented, body %s", at, w.Body)
+
+		return
+	}
+
+	for name, header := range object(response["headers"]) {
+		if d.resolve(object(header))["required"] == true && w.Header().Get(name) == "" {
+			t.Errorf("%s: header %s missing", at, name)
+		}
+	}
+
+	content := object(response["content"])
+	if len(content) == 0 {
+		if w.Body.Len() != 0 {
+			t.Errorf("%s: body %s not documented", at, w.Body)
+		}
+
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	media := object(content[mediaType])
+	if err != nil || media == nil {
+		t.Errorf("%s: content type %q not documented", at, w.Header().Get("Content-Type"))
+
+		return
+	}
+
+	if strings.HasSuffix(mediaType, "json") {
+		d.conforms(t, at, object(media["schema"]), w.Body.Bytes())
+	}
+}
+
+// conforms fails t if the JSON data does not conform to the schema.
+func (d *document) conforms(t *testing.T, at string, schema map[string]interface{}, data []byte) {
+	t.Helper()
+
+	var body interface{}
+
+	err := decode(data, &body)
+	if err != nil {
+		t.Errorf("%s: %v", at, err)
+
+		return
+	}
+
+	for _, violation := range d.validate(schema, body, "body") {
+		t.Errorf("%s: %s", at, violation)
+	}
+}
+
+// validate returns the violations of the schema by the value at the path. Objects are closed: properties the
+// schema does not list are violations unless additionalProperties allows them, so undocumented fields are caught.
+func (d *document) validate(schema map[string]interface{}, value interface{}, path string) []string {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+
+		return []string{path + ": null"}
+	}
+
+	if options, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, option := range options {
+			if len(d.validate(object(option), value, path)) == 0 {
+				matched++
+			}
+		}
+
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: %v matches %d of the schemas of oneOf", path, value, matched)}
+		}
+
+		return nil
+	}
+
+	var violations []string
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, value) {
+		violations = append(violations, fmt.Sprintf("%s: %v not in %v", path, value, enum))
+	}
+
+	switch schema["type"] {
+	case "object":
+		o, ok := value.(map[string]interface{})
+		if !ok {
+			return append(violations, fmt.Sprintf("%s: %v is not an object", path, value))
+		}
+
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := o[name.(string)]; !ok {
+				violations = append(violations, fmt.Sprintf("%s.%s: missing", path, name))
+			}
+		}
+
+		properties := object(schema["properties"])
+		additional, free := schema["additionalProperties"]
+		free = free || len(properties) == 0
+
+		for name, property := range o {
+			switch {
+			case properties[name] != nil:
+				violations = append(violations, d.validate(object(properties[name]), property, path+"."+name)...)
+			case object(additional) != nil:
+				violations = append(violations, d.validate(object(additional), property, path+"."+name)...)
+			case !free || additional == false:
+				violations = append(violations, fmt.Sprintf("%s.%s: not documented", path, name))
+			}
+		}
+	case "array":
+		a, ok := value.([]interface{})
+		if !ok {
+			return append(violations, fmt.Sprintf("%s: %v is not an array", path, value))
+		}
+
+		for i, item := range a {
+			violations = append(violations, d.validate(object(schema["items"]), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(violations, fmt.Sprintf("%s: %v is not a string", path, value))
+		}
+
+		violations = append(violations, validateString(schema, s, path)...)
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			violations = append(violations, fmt.Sprintf("%s: %v is not an integer", path, value))
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a number", path, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a boolean", path, value))
+		}
+	}
+
+	return violations
+}
+
+func validateString(schema map[string]interface{}, s string, path string) []string {
+	var violations []string
+
+	if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+		violations = append(violations, fmt.Sprintf("%s: %q does not match %s", path, s, pattern))
+	}
+
+	if minLength, ok := schema["minLength"].(json.Number); ok {
+		if n, _ := minLength.Int64(); int64(utf8.RuneCountInString(s)) < n {
+			violations = append(violations, fmt.Sprintf("%s: %q shorter than %d", path, s, n))
+		}
+	}
+
+	if maxLength, ok := schema["maxLength"].(json.Number); ok {
+		if n, _ := maxLength.Int64(); int64(utf8.RuneCountInString(s)) > n {
+			violations = append(violations, fmt.Sprintf("%s: %q longer than %d", path, s, n))
+		}
+	}
+
+	var err error
+
+	switch schema["format"] {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse(time.DateOnly, s)
+	case "uri":
+		_, err = url.ParseRequestURI(s)
+	}
+
+	if err != nil {
+		violations = append(violations, fmt.Sprintf("%s: %q is not a %s", path, s, schema["format"]))
+	}
+
+	return violations
+}
+
+// uncalled returns the documented operations not requested.
+func (d *document) uncalled() []string {
+	var operations []string
+
+	for route, item := range object(d.root["paths"]) {
+		for method := range object(item) {
+			if method != "parameters" && method != "servers" && !d.called[method+" "+route] {
+				operations = append(operations, method+" "+route)
+			}
+		}
+	}
+
+	sort.Strings(operations)
+
+	return operations
+}
+
+// contractApp serves the requests of the contract test: the exchange group is limited to two requests and
+// updates of USD/GBP need approval.
+func contractApp(t *testing.T) *App {
+	t.Helper()
+
+	c := &config.Config{
+		PathToDB:        filepath.Join(t.TempDir(), "sqlite.db"),
+		DefaultLanguage: "en",
+		StreamHeartbeat: 15,
+		StreamBuffer:    16,
+		IdempotencyTTL:  3600,
+		RateLimits:      map[string]config.RateLimit{limitExchange: {Rate: 0.001, Burst: 2}},
+		Approval:        config.Approval{RateApprovalPairs: []string{"USDGBP"}, RateProposalTTL: 3600},
+	}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(c)
+	a.SetRoutes()
+
+	return a
+}
+
+// newRequest returns a request authenticated by the API key, if any, with the form as its body.
+func newRequest(method, target, key string, form url.Values) *http.Request {
+	var r *http.Request
+	if form == nil {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if key != "" {
+		r.Header.Set("X-API-Key", key)
+	}
+
+	return r
+}
+
+// serve serves r, the request of the documented route, fails t if the response has another status than
+// the expected one and checks it against the document.
+func serve(t *testing.T, a *App, d *document, route string, r *http.Request, status int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+
+	if w.Code != status {
+		t.Errorf("%s %s: status %d, want %d, body %s", r.Method, r.URL, w.Code, status, w.Body)
+	}
+
+	d.check(t, r.Method, route, w)
+
+	return w
+}
+
+// id returns the id of the entity of the response.
+func id(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	entity := struct {
+		ID json.Number `json:"id"`
+	}{}
+
+	err := decode(w.Body.Bytes(), &entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entity.ID.String()
+}
+
+// snapshot returns the response to the stream request up to its first message, the snapshot, as streams are
+// served by a server to be flushed and do not end.
+func snapshot(t *testing.T, a *App, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	w := httptest.NewRecorder()
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(response.StatusCode)
+
+	reader := bufio.NewReader(response.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream %q: %v", w.Body, err)
+		}
+
+		if line == "\n" {
+			return w
+		}
+
+		w.WriteString(line)
+	}
+}
+
+func TestContract(t *testing.T) {
+	a := contractApp(t)
+	v1 := loadDocument(t, a, "/v1/openapi.json")
+	v2 := loadDocument(t, a, "/v2/openapi.json")
+
+	issue := func(name string, scopes ...string) string {
+		apiKey, err := a.authenticator.Issue(name, scopes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return apiKey.Key
+	}
+
+	admin := issue("admin", auth.ScopeAdmin)
+	maker := issue("maker", auth.ScopeRatesRead, auth.ScopeRatesWrite, auth.ScopeRatesApprove)
+	checker := issue("checker", auth.ScopeRatesRead, auth.ScopeRatesApprove)
+	reader := issue("reader", auth.ScopeRatesRead)
+
+	var webhookID string
+
+	t.Run("webhooks", func(t *testing.T) {
+		form := url.Values{
+			"url":    {"https://example.com/hook"},
+			"events": {"rate.created,rate.updated"},
+			"secret": {"0123456789abcdef"},
+		}
+
+		r := newRequest(http.MethodPost, "/webhooks", admin, form)
+		r.Header.Set("Idempotency-Key", "webhook-1")
+		webhookID = id(t, serve(t, a, v2, "/webhooks", r, http.StatusCreated))
+
+		r = newRequest(http.MethodPost, "/webhooks", admin, form)
+		r.Header.Set("Idempotency-Key", "webhook-1")
+		if w := serve(t, a, v2, "/webhooks", r, http.StatusCreated); w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("response not replayed, headers %v", w.Header())
+		}
+
+		r = newRequest(http.MethodPost, "/webhooks", admin, url.Values{"url": {"https://example.com/other"}})
+		r.Header.Set("Idempotency-Key", "webhook-1")
+		serve(t, a, v2, "/webhooks", r, http.StatusUnprocessableEntity)
+
+		r = newRequest(http.MethodPost, "/webhooks", admin, form)
+		r.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+		serve(t, a, v2, "/webhooks", r, http.StatusBadRequest)
+
+		r = newRequest(http.MethodPost, "/webhooks", admin, url.Values{"url": {"ftp://example.com"}})
+		serve(t, a, v2, "/webhooks", r, http.StatusBadRequest)
+
+		serve(t, a, v2, "/webhooks", newRequest(http.MethodGet, "/webhooks", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/webhooks/{id}", newRequest(http.MethodGet, "/webhooks/"+webhookID, admin, nil), http.StatusOK)
+		serve(t, a, v2, "/webhooks/{id}", newRequest(http.MethodGet, "/webhooks/999", admin, nil), http.StatusNotFound)
+	})
+
+	t.Run("currencies", func(t *testing.T) {
+		for _, currency := range [][]string{{"USD", "US Dollar", "$"}, {"EUR", "Euro", "€"}, {"GBP", "Pound", "£"}} {
+			form := url.Values{"code": {currency[0]}, "fullName": {currency[1]}, "sign": {currency[2]}}
+			serve(t, a, v2, "/currencies", newRequest(http.MethodPost, "/v2/currencies", admin, form), http.StatusCreated)
+		}
+
+		form := url.Values{"code": {"JPY"}, "name": {"Yen"}, "sign": {"¥"}}
+		serve(t, a, v1, "/currencies", newRequest(http.MethodPost, "/v1/currencies", admin, form), http.StatusCreated)
+		serve(t, a, v1, "/currencies", newRequest(http.MethodPost, "/currencies", admin, form), http.StatusConflict)
+
+		form = url.Values{"code": {"USD"}, "fullName": {"US Dollar"}, "sign": {"$"}}
+		serve(t, a, v2, "/currencies", newRequest(http.MethodPost, "/v2/currencies", admin, form), http.StatusConflict)
+
+		form = url.Values{"code": {"US"}, "name": {"Dollar"}}
+		serve(t, a, v1, "/currencies", newRequest(http.MethodPost, "/v1/currencies", admin, form), http.StatusBadRequest)
+		serve(t, a, v2, "/currencies", newRequest(http.MethodPost, "/v2/currencies", admin, form), http.StatusBadRequest)
+
+		serve(t, a, v1, "/currencies", newRequest(http.MethodGet, "/v1/currencies", "", nil), http.StatusOK)
+		serve(t, a, v1, "/currencies", newRequest(http.MethodGet, "/currencies?limit=2", "", nil), http.StatusOK)
+		serve(t, a, v2, "/currencies", newRequest(http.MethodGet, "/v2/currencies?sort=-code", "", nil), http.StatusOK)
+		serve(t, a, v2, "/currencies", newRequest(http.MethodGet, "/v2/currencies?limit=x", "", nil), http.StatusBadRequest)
+
+		serve(t, a, v1, "/currency/{code}", newRequest(http.MethodGet, "/v1/currency/USD", "", nil), http.StatusOK)
+		serve(t, a, v1, "/currency/{code}", newRequest(http.MethodGet, "/v1/currency/XXX", "", nil), http.StatusNotFound)
+		serve(t, a, v2, "/currency/{code}", newRequest(http.MethodGet, "/v2/currency/XXX", "", nil), http.StatusNotFound)
+
+		w := serve(t, a, v2, "/currency/{code}", newRequest(http.MethodGet, "/v2/currency/USD", "", nil), http.StatusOK)
+		r := newRequest(http.MethodGet, "/v2/currency/USD", "", nil)
+		r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		serve(t, a, v2, "/currency/{code}", r, http.StatusNotModified)
+	})
+
+	t.Run("exchange rates", func(t *testing.T) {
+		form := url.Values{"baseCurrencyCode": {"USD"}, "targetCurrencyCode": {"EUR"}, "rate": {"0.9"}}
+		serve(t, a, v2, "/exchangeRates", newRequest(http.MethodPost, "/v2/exchangeRates", admin, form), http.StatusCreated)
+		serve(t, a, v2, "/exchangeRates", newRequest(http.MethodPost, "/v2/exchangeRates", admin, form), http.StatusConflict)
+
+		form = url.Values{"baseCurrencyCode": {"EUR"}, "targetCurrencyCode": {"JPY"}, "rate": {"160"}}
+		serve(t, a, v1, "/exchangeRates", newRequest(http.MethodPost, "/v1/exchangeRates", admin, form), http.StatusCreated)
+		serve(t, a, v1, "/exchangeRates", newRequest(http.MethodPost, "/v1/exchangeRates", admin, form), http.StatusConflict)
+
+		form = url.Values{"baseCurrencyCode": {"USD"}, "targetCurrencyCode": {"XXX"}, "rate": {"1"}}
+		serve(t, a, v1, "/exchangeRates", newRequest(http.MethodPost, "/v1/exchangeRates", admin, form), http.StatusNotFound)
+		serve(t, a, v2, "/exchangeRates", newRequest(http.MethodPost, "/v2/exchangeRates", admin, form), http.StatusNotFound)
+
+		serve(t, a, v1, "/exchangeRates", newRequest(http.MethodGet, "/v1/exchangeRates", "", nil), http.StatusOK)
+		serve(t, a, v2, "/exchangeRates", newRequest(http.MethodGet, "/v2/exchangeRates", "", nil), http.StatusOK)
+
+		serve(t, a, v1, "/exchangeRate/{pair}", newRequest(http.MethodGet, "/v1/exchangeRate/USDXXX", "", nil), http.StatusNotFound)
+		serve(t, a, v2, "/exchangeRate/{pair}", newRequest(http.MethodGet, "/v2/exchangeRate/USD", "", nil), http.StatusBadRequest)
+		serve(t, a, v1, "/exchangeRate/{pair}", newRequest(http.MethodOptions, "/v1/exchangeRate/USDEUR", "", nil), http.StatusOK)
+		serve(t, a, v2, "/exchangeRate/{pair}", newRequest(http.MethodOptions, "/v2/exchangeRate/USDEUR", "", nil), http.StatusOK)
+
+		for _, d := range []*document{v1, v2} {
+			prefix := "/" + strings.Split(d.name, "/")[1]
+			target := prefix + "/exchangeRate/USDEUR"
+
+			w := serve(t, a, d, "/exchangeRate/{pair}", newRequest(http.MethodGet, target, "", nil), http.StatusOK)
+			etag := w.Header().Get("ETag")
+
+			r := newRequest(http.MethodGet, target, "", nil)
+			r.Header.Set("If-None-Match", etag)
+			serve(t, a, d, "/exchangeRate/{pair}", r, http.StatusNotModified)
+
+			form := url.Values{"rate": {"0.91"}}
+			r = newRequest(http.MethodPatch, target, admin, form)
+			r.Header.Set("If-Match", `"stale"`)
+			serve(t, a, d, "/exchangeRate/{pair}", r, http.StatusPreconditionFailed)
+
+			r = newRequest(http.MethodPatch, target, admin, form)
+			r.Header.Set("If-Match", etag)
+			serve(t, a, d, "/exchangeRate/{pair}", r, http.StatusOK)
+
+			r = newRequest(http.MethodPatch, target, admin, url.Values{"rate": {"-1"}})
+			r.Header.Set("If-Match", etag)
+			serve(t, a, d, "/exchangeRate/{pair}", r, http.StatusBadRequest)
+
+			// v1 keeps updating the rates without If-Match
+			unconditional := http.StatusPreconditionRequired
+			if d == v1 {
+				unconditional = http.StatusOK
+			}
+
+			serve(t, a, d, "/exchangeRate/{pair}", newRequest(http.MethodPatch, target, admin, form), unconditional)
+		}
+	})
+
+	t.Run("exchange", func(t *testing.T) {
+		serve(t, a, v1, "/exchange", newRequest(http.MethodGet, "/v1/exchange?from=USD&to=EUR&amount=10", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/exchange", newRequest(http.MethodGet, "/v2/exchange?from=EUR&to=USD&amount=10", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/exchange", newRequest(http.MethodGet, "/v2/exchange?from=USD&to=GBP&amount=1", maker, nil), http.StatusNotFound)
+		serve(t, a, v1, "/exchange", newRequest(http.MethodGet, "/exchange?from=USD&to=EUR", maker, nil), http.StatusBadRequest)
+
+		// an anonymous client shares the limit of the group between the versions
+		for _, status := range []int{http.StatusOK, http.StatusTooManyRequests} {
+			for _, target := range []string{"/v1/exchange", "/v2/exchange"} {
+				d := v1
+				if strings.HasPrefix(target, "/v2") {
+					d = v2
+				}
+
+				r := newRequest(http.MethodGet, target+"?from=USD&to=EUR&amount=1", "", nil)
+				r.RemoteAddr = "198.51.100.1:1234"
+				serve(t, a, d, "/exchange", r, status)
+			}
+		}
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		form := url.Values{"code": {"CHF"}, "fullName": {"Franc"}, "sign": {"Fr"}}
+
+		w := serve(t, a, v2, "/currencies", newRequest(http.MethodPost, "/v2/currencies", "", form), http.StatusUnauthorized)
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("no WWW-Authenticate challenge")
+		}
+
+		serve(t, a, v1, "/currencies", newRequest(http.MethodPost, "/v1/currencies", "", form), http.StatusUnauthorized)
+		serve(t, a, v2, "/currencies", newRequest(http.MethodGet, "/v2/currencies", "ce_invalid", nil), http.StatusUnauthorized)
+		serve(t, a, v1, "/currencies", newRequest(http.MethodGet, "/v1/currencies", "ce_invalid", nil), http.StatusUnauthorized)
+		serve(t, a, v2, "/currencies", newRequest(http.MethodPost, "/v2/currencies", reader, form), http.StatusForbidden)
+		serve(t, a, v1, "/currencies", newRequest(http.MethodPost, "/v1/currencies", reader, form), http.StatusForbidden)
+
+		serve(t, a, v2, "/webhooks", newRequest(http.MethodGet, "/webhooks", "", nil), http.StatusUnauthorized)
+		serve(t, a, v2, "/webhooks", newRequest(http.MethodGet, "/webhooks", reader, nil), http.StatusForbidden)
+		serve(t, a, v2, "/proposals", newRequest(http.MethodGet, "/proposals", "", nil), http.StatusUnauthorized)
+		serve(t, a, v2, "/maintenance", newRequest(http.MethodGet, "/maintenance", reader, nil), http.StatusForbidden)
+	})
+
+	t.Run("proposals", func(t *testing.T) {
+		form := url.Values{"baseCurrencyCode": {"USD"}, "targetCurrencyCode": {"GBP"}, "rate": {"0.8"}}
+		w := serve(t, a, v2, "/exchangeRates", newRequest(http.MethodPost, "/v2/exchangeRates", maker, form), http.StatusAccepted)
+		proposalID := id(t, w)
+
+		if w.Header().Get("Location") != "/proposals/"+proposalID {
+			t.Errorf("location %q, want the proposal %s", w.Header().Get("Location"), proposalID)
+		}
+
+		approve := "/proposals/" + proposalID + "/approve"
+
+		serve(t, a, v2, "/proposals", newRequest(http.MethodGet, "/proposals?status=pending", maker, nil), http.StatusOK)
+		serve(t, a, v2, "/proposals", newRequest(http.MethodGet, "/proposals?status=bogus", maker, nil), http.StatusBadRequest)
+		serve(t, a, v2, "/proposals/{id}", newRequest(http.MethodGet, "/proposals/"+proposalID, checker, nil), http.StatusOK)
+		serve(t, a, v2, "/proposals/{id}", newRequest(http.MethodGet, "/proposals/999", checker, nil), http.StatusNotFound)
+		serve(t, a, v2, "/proposals/{id}/approve", newRequest(http.MethodPost, approve, maker, nil), http.StatusForbidden)
+		serve(t, a, v2, "/proposals/{id}/approve", newRequest(http.MethodPost, approve, admin, nil), http.StatusForbidden)
+		serve(t, a, v2, "/proposals/{id}/approve", newRequest(http.MethodPost, approve, checker, nil), http.StatusOK)
+		serve(t, a, v2, "/proposals/{id}/approve", newRequest(http.MethodPost, approve, checker, nil), http.StatusConflict)
+		serve(t, a, v2, "/proposals/{id}/approve", newRequest(http.MethodPost, "/proposals/999/approve", checker, nil), http.StatusNotFound)
+
+		// v1 updates need approval as well
+		w = serve(t, a, v1, "/exchangeRate/{pair}", newRequest(http.MethodGet, "/v1/exchangeRate/USDGBP", "", nil), http.StatusOK)
+
+		r := newRequest(http.MethodPatch, "/v1/exchangeRate/USDGBP", maker, url.Values{"rate": {"0.85"}})
+		r.Header.Set("If-Match", w.Header().Get("ETag"))
+		reject := "/proposals/" + id(t, serve(t, a, v1, "/exchangeRate/{pair}", r, http.StatusAccepted)) + "/reject"
+
+		r = newRequest(http.MethodPost, reject, checker, url.Values{"reason": {strings.Repeat("r", 1001)}})
+		serve(t, a, v2, "/proposals/{id}/reject", r, http.StatusBadRequest)
+
+		r = newRequest(http.MethodPost, reject, maker, url.Values{"reason": {"mine"}})
+		serve(t, a, v2, "/proposals/{id}/reject", r, http.StatusForbidden)
+
+		r = newRequest(http.MethodPost, reject, checker, url.Values{"reason": {"outdated"}})
+		serve(t, a, v2, "/proposals/{id}/reject", r, http.StatusOK)
+		serve(t, a, v2, "/proposals/{id}/reject", newRequest(http.MethodPost, reject, checker, nil), http.StatusConflict)
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		deliveries := "/webhooks/" + webhookID + "/deliveries"
+
+		w := serve(t, a, v2, "/webhooks/{id}/deliveries", newRequest(http.MethodGet, deliveries+"?limit=1", admin, nil), http.StatusOK)
+		if w.Header().Get("X-Total-Count") == "" || w.Header().Get("X-Next-Cursor") == "" {
+			t.Errorf("page headers missing, headers %v", w.Header())
+		}
+
+		// the first delivery failed all attempts
+		storageWebhooks := webhooks.New(a.config.PathToDB)
+
+		webhookId, _ := strconv.ParseInt(webhookID, 10, 64)
+
+		pending, _, err := storageWebhooks.Deliveries(webhookId, webhooks.DeliveriesFilter{})
+		if err != nil || len(pending) == 0 {
+			t.Fatalf("deliveries %+v, %v, want the events of the rates added", pending, err)
+		}
+
+		dead := pending[0]
+		lastAttemptAt := time.Now().UTC()
+		dead.Status, dead.Attempts, dead.LastAttemptAt = entity.DeliveryDead, 3, &lastAttemptAt
+		dead.ResponseStatus, dead.LastError = http.StatusBadGateway, "502 Bad Gateway"
+
+		err = storageWebhooks.UpdateDelivery(dead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serve(t, a, v2, "/webhooks/{id}/deliveries", newRequest(http.MethodGet, deliveries+"?status=dead", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/webhooks/{id}/deliveries", newRequest(http.MethodGet, deliveries+"?status=bogus", admin, nil), http.StatusBadRequest)
+		serve(t, a, v2, "/webhooks/{id}/deliveries", newRequest(http.MethodGet, "/webhooks/999/deliveries", admin, nil), http.StatusNotFound)
+
+		route := "/webhooks/{id}/deliveries/{deliveryId}/redeliver"
+		redeliver := deliveries + "/" + strconv.FormatInt(dead.ID, 10) + "/redeliver"
+		serve(t, a, v2, route, newRequest(http.MethodPost, redeliver, admin, nil), http.StatusAccepted)
+		serve(t, a, v2, route, newRequest(http.MethodPost, redeliver, admin, nil), http.StatusNotFound)
+
+		serve(t, a, v2, "/webhooks/{id}", newRequest(http.MethodDelete, "/webhooks/"+webhookID, admin, nil), http.StatusNoContent)
+		serve(t, a, v2, "/webhooks/{id}", newRequest(http.MethodDelete, "/webhooks/"+webhookID, admin, nil), http.StatusNotFound)
+	})
+
+	t.Run("api keys", func(t *testing.T) {
+		serve(t, a, v2, "/apikeys", newRequest(http.MethodGet, "/apikeys", admin, nil), http.StatusOK)
+
+		form := url.Values{"name": {"ci"}, "scopes": {"rates:read,rates:write"}}
+		keyID := id(t, serve(t, a, v2, "/apikeys", newRequest(http.MethodPost, "/apikeys", admin, form), http.StatusCreated))
+
+		form = url.Values{"name": {"ci"}, "scopes": {"bogus"}}
+		serve(t, a, v2, "/apikeys", newRequest(http.MethodPost, "/apikeys", admin, form), http.StatusBadRequest)
+
+		serve(t, a, v2, "/apikeys/{id}", newRequest(http.MethodDelete, "/apikeys/"+keyID, admin, nil), http.StatusNoContent)
+		serve(t, a, v2, "/apikeys/{id}", newRequest(http.MethodDelete, "/apikeys/999", admin, nil), http.StatusNotFound)
+	})
+
+	t.Run("audit and usage", func(t *testing.T) {
+		serve(t, a, v2, "/audit/rates", newRequest(http.MethodGet, "/audit/rates?base=USD", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/audit/rates", newRequest(http.MethodGet, "/audit/rates?base=US", admin, nil), http.StatusBadRequest)
+		serve(t, a, v2, "/usage", newRequest(http.MethodGet, "/usage", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/usage", newRequest(http.MethodGet, "/usage?from=yesterday", admin, nil), http.StatusBadRequest)
+	})
+
+	t.Run("streams", func(t *testing.T) {
+		w := snapshot(t, a, "/stream/rates?pairs=USDEUR,EURJPY")
+		v2.check(t, http.MethodGet, "/stream/rates", w)
+
+		data, ok := strings.CutPrefix(w.Body.String(), "event: snapshot\ndata: ")
+		if !ok {
+			t.Fatalf("stream %q, want the snapshot", w.Body)
+		}
+
+		v2.conforms(t, "/stream/rates snapshot", v2.schema("StreamMessage"), []byte(data))
+
+		serve(t, a, v2, "/stream/rates", newRequest(http.MethodGet, "/stream/rates?pairs=USD", "", nil), http.StatusBadRequest)
+		serve(t, a, v2, "/stream/rates", newRequest(http.MethodGet, "/stream/rates", "ce_invalid", nil), http.StatusUnauthorized)
+		serve(t, a, v2, "/stream/rates/ws", newRequest(http.MethodGet, "/stream/rates/ws?pairs=USD", "", nil), http.StatusBadRequest)
+	})
+
+	t.Run("health", func(t *testing.T) {
+		serve(t, a, v2, "/healthz", newRequest(http.MethodGet, "/healthz", "", nil), http.StatusOK)
+		serve(t, a, v2, "/readyz", newRequest(http.MethodGet, "/readyz", "", nil), http.StatusOK)
+		serve(t, a, v2, "/version", newRequest(http.MethodGet, "/version", "", nil), http.StatusOK)
+		serve(t, a, v2, "/metrics", newRequest(http.MethodGet, "/metrics", "", nil), http.StatusOK)
+
+		serve(t, a, v2, "/maintenance", newRequest(http.MethodPut, "/maintenance", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/readyz", newRequest(http.MethodGet, "/readyz", "", nil), http.StatusServiceUnavailable)
+		serve(t, a, v2, "/maintenance", newRequest(http.MethodDelete, "/maintenance", admin, nil), http.StatusOK)
+		serve(t, a, v2, "/maintenance", newRequest(http.MethodGet, "/maintenance", admin, nil), http.StatusOK)
+	})
+
+	for _, d := range []*document{v1, v2} {
+		if uncalled := d.uncalled(); len(uncalled) > 0 {
+			t.Errorf("%s: operations not checked: %v", d.name, uncalled)
+		}
+	}
+}
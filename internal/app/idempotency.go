@@ -0,0 +1,182 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader names the key of a mutating request the client may safely retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the headers of a response stored along with its status and body. The RateLimit headers
+// are not among them, they are set for the repeat itself.
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Location", "ETag"}
+
+// idempotent stores the response to a mutating request with an Idempotency-Key and replays it on the repeats
+// of the request by the same client. A key reused with another request is rejected, as is a repeat made while
+// the first request is in progress. Responses with server errors are not stored, nor are the requests whose
+// handler panicked, so the request may be retried.
+func (a *App) idempotent(commonController controller.ServerResponse, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "idempotent"
+
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || isRead(r) || r.Method == http.MethodOptions {
+			handler(w, r)
+
+			return
+		}
+
+		if len(key) > 255 {
+			commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeIdempotencyKeyInvalid)
+
+			return
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeBodyInvalid)
+
+			return
+		}
+
+		now := time.Now().UTC()
+		record := entity.IdempotencyRecord{
+			Client:      clientOf(r),
+			Key:         key,
+			RequestHash: requestHash(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(time.Duration(a.config.IdempotencyTTL) * time.Second),
+		}
+
+		stored, started, err := a.idempotency.Start(record)
+		if err != nil {
+			logging.ErrorContext(r.Context(), f, op, err)
+			commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+			return
+		}
+
+		if !started {
+			replay(w, r, commonController, stored, record.RequestHash)
+
+			return
+		}
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			err := a.idempotency.Delete(record.Client, record.Key)
+			if err != nil {
+				logging.ErrorContext(r.Context(), f, op, err)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		completed = true
+
+		if recorder.status >= http.StatusInternalServerError {
+			err = a.idempotency.Delete(record.Client, record.Key)
+		} else {
+			record.Status = recorder.status
+			record.Headers = map[string]string{}
+			record.Body = recorder.body.Bytes()
+
+			for _, name := range replayedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					record.Headers[name] = value
+				}
+			}
+
+			err = a.idempotency.Complete(record)
+		}
+
+		if err != nil {
+			logging.ErrorContext(r.Context(), f, op, err)
+		}
+	}
+}
+
+// replay responds with the stored response if it was made to the same request.
+func replay(
+	w http.ResponseWriter,
+	r *http.Request,
+	commonController controller.ServerResponse,
+	stored entity.IdempotencyRecord,
+	requestHash string,
+) {
+	if stored.RequestHash != requestHash {
+		commonController.ShowError(w, r, http.StatusUnprocessableEntity, controller.CodeIdempotencyKeyReused)
+
+		return
+	}
+
+	if stored.Status == 0 {
+		commonController.ShowError(w, r, http.StatusConflict, controller.CodeIdempotencyKeyInProgress)
+
+		return
+	}
+
+	for name, value := range stored.Headers {
+		w.Header().Set(name, value)
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.Status)
+
+	_, _ = w.Write(stored.Body)
+}
+
+// readBody reads the body up to the limit of the validators and puts it back for the handler.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, validation.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + r.Header.Get("Content-Type") + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	rr.status = statusCode
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body.Write(b)
+
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	storageIdempotency "github.com/albakov/go-currency-exchange/internal/storage/idempotency"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newIdempotentApp returns an app storing the idempotency records in a new database.
+func newIdempotentApp(t *testing.T) *App {
+	t.Helper()
+
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db"), IdempotencyTTL: 60}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &App{config: c, idempotency: storageIdempotency.New(c.PathToDB)}
+}
+
+func idempotentRequest(key string, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/currencies", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	return r
+}
+
+func TestIdempotentRepeats(t *testing.T) {
+	created := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/currencies/1")
+		w.Header().Set("ETag", `"1-1"`)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}
+	failed := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	form := url.Values{"code": {"CHF"}}
+
+	tests := []struct {
+		name string
+		key  string
+		// first responds to the first request, the repeats are created
+		first    func(w http.ResponseWriter)
+		repeat   url.Values
+		want     int
+		replayed bool
+		calls    int
+	}{
+		{name: "replayed", key: "k1", first: created, repeat: form, want: http.StatusCreated, replayed: true, calls: 1},
+		{name: "key reused with another body", key: "k1", first: created, repeat: url.Values{"code": {"GBP"}}, want: http.StatusUnprocessableEntity, calls: 1},
+		{name: "server error not stored", key: "k1", first: failed, repeat: form, want: http.StatusCreated, calls: 2},
+		{name: "without a key", first: created, repeat: form, want: http.StatusCreated, calls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newIdempotentApp(t)
+			calls := 0
+
+			handler := a.idempotent(controller.New(controller.V2, i18n.MustNew("en")), func(w http.ResponseWriter, r *http.Request) {
+				calls++
+
+				if calls == 1 {
+					tt.first(w)
+
+					return
+				}
+
+				created(w)
+			})
+
+			first := httptest.NewRecorder()
+			handler(first, idempotentRequest(tt.key, form))
+
+			w := httptest.NewRecorder()
+			handler(w, idempotentRequest(tt.key, tt.repeat))
+
+			if w.Code != tt.want || calls != tt.calls {
+				t.Fatalf("status %d after %d calls, want %d after %d", w.Code, calls, tt.want, tt.calls)
+			}
+
+			if (w.Header().Get("Idempotent-Replayed") == "true") != tt.replayed {
+				t.Errorf("replayed %q, want %t", w.Header().Get("Idempotent-Replayed"), tt.replayed)
+			}
+
+			if !tt.replayed {
+				return
+			}
+
+			for _, name := range replayedHeaders {
+				if w.Header().Get(name) != first.Header().Get(name) {
+					t.Errorf("%s %q, want %q", name, w.Header().Get(name), first.Header().Get(name))
+				}
+			}
+
+			if w.Body.String() != first.Body.String() {
+				t.Errorf("body %q, want %q", w.Body.String(), first.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	a := newIdempotentApp(t)
+	form := url.Values{"code": {"CHF"}}
+	repeat := httptest.NewRecorder()
+
+	var handler http.HandlerFunc
+	handler = a.idempotent(controller.New(controller.V2, i18n.MustNew("en")), func(w http.ResponseWriter, r *http.Request) {
+		// the client repeats the request before the response
+		if repeat.Body.Len() == 0 {
+			handler(repeat, idempotentRequest("k1", form))
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	handler(httptest.NewRecorder(), idempotentRequest("k1", form))
+
+	if repeat.Code != http.StatusConflict || !strings.Contains(repeat.Body.String(), controller.CodeIdempotencyKeyInProgress) {
+		t.Errorf("repeat %d %s, want the conflict of a request in progress", repeat.Code, repeat.Body.String())
+	}
+}
+
+func TestIdempotentPanicReleasesKey(t *testing.T) {
+	a := newIdempotentApp(t)
+	form := url.Values{"code": {"CHF"}}
+	calls := 0
+
+	handler := a.idempotent(controller.New(controller.V2, i18n.MustNew("en")), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls == 1 {
+			panic("handler failed")
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic of the handler swallowed")
+			}
+		}()
+
+		handler(httptest.NewRecorder(), idempotentRequest("k1", form))
+	}()
+
+	w := httptest.NewRecorder()
+	handler(w, idempotentRequest("k1", form))
+
+	if w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("retry %d after %d calls, want it handled again", w.Code, calls)
+	}
+}
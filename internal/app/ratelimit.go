@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/ratelimit"
+	"github.com/albakov/go-currency-exchange/internal/storage/usage"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Route groups of config.RateLimits.
+const (
+	limitExchange = "exchange"
+	limitRead     = "read"
+	limitWrite    = "write"
+)
+
+// limits applies the rate limits and the daily quotas of the route groups to the requests of clients, made over
+// HTTP or gRPC.
+type limits struct {
+	limiters atomic.Pointer[map[string]*ratelimit.Limiter]
+	usage    usage.StorageUsage
+}
+
+// decision is the outcome of the limits of a group for a request.
+type decision struct {
+	// result is the state of the bucket of the client, nil if the rate of the group is not limited
+	result *ratelimit.Result
+	// exceeded is the code of the error of the limit the request exceeds, empty if it is allowed
+	exceeded   string
+	retryAfter time.Duration
+}
+
+func newLimits(config *config.Config, storageUsage usage.StorageUsage) *limits {
+	l := &limits{usage: storageUsage}
+	l.update(config)
+
+	return l
+}
+
+// update replaces the limiters of the route groups whose limits changed, the limiters of the other groups are
+// kept along with the buckets of their clients.
+func (l *limits) update(config *config.Config) {
+	var previous map[string]*ratelimit.Limiter
+	if current := l.limiters.Load(); current != nil {
+		previous = *current
+	}
+
+	limiters := map[string]*ratelimit.Limiter{}
+
+	for group, limit := range config.RateLimits {
+		limiter, ok := previous[group]
+		if !ok || limiter.Limit() != limit {
+			limiter = ratelimit.New(limit)
+		}
+
+		limiters[group] = limiter
+	}
+
+	l.limiters.Store(&limiters)
+}
+
+// check takes a token of the client from the bucket of the group and, if the group has a daily quota, counts
+// the request towards it. Requests of groups without a quota are not counted.
+func (l *limits) check(ctx context.Context, client, group string, now time.Time) decision {
+	const op = "check"
+
+	limiter, ok := (*l.limiters.Load())[group]
+	if !ok {
+		return decision{}
+	}
+
+	d := decision{}
+
+	if limiter.Limited() {
+		result := limiter.Take(client, now)
+		d.result = &result
+
+		if !result.Allowed {
+			d.exceeded = controller.CodeRateLimitExceeded
+			d.retryAfter = result.RetryAfter
+
+			return d
+		}
+	}
+
+	quota := limiter.Limit().DailyQuota
+	if quota <= 0 {
+		return d
+	}
+
+	_, err := l.usage.Count(client, group, now.Format(time.DateOnly), quota)
+	if err != nil {
+		if errors.Is(err, usage.QuotaExceededError) {
+			midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+			d.exceeded = controller.CodeQuotaExceeded
+			d.retryAfter = midnight.Sub(now)
+
+			return d
+		}
+
+		// a failing counter does not take the API down
+		logging.ErrorContext(ctx, f, op, err)
+	}
+
+	return d
+}
+
+// Allow applies the limits of the group to a gRPC call of the client.
+func (l *limits) Allow(ctx context.Context, client, group string) (string, time.Duration) {
+	d := l.check(ctx, client, group, time.Now().UTC())
+
+	return d.exceeded, d.retryAfter
+}
+
+// limit limits the requests of the client to the read group, for reads, or to the write group.
+// The requests within the limits are counted towards the daily quota of the group, if it has one.
+func (a *App) limit(
+	commonController controller.ServerResponse,
+	readGroup string,
+	writeGroup string,
+	handler http.HandlerFunc,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			handler(w, r)
+
+			return
+		}
+
+		group := writeGroup
+		if isRead(r) {
+			group = readGroup
+		}
+
+		if a.allowedRate(w, r, commonController, group) {
+			handler(w, r)
+		}
+	}
+}
+
+// allowedRate reports whether the request is within the limits of the group, or responds with the error.
+func (a *App) allowedRate(
+	w http.ResponseWriter,
+	r *http.Request,
+	commonController controller.ServerResponse,
+	group string,
+) bool {
+	d := a.limits.check(r.Context(), clientOf(r), group, time.Now().UTC())
+
+	if d.result != nil {
+		w.Header().Set("RateLimit-Limit", strconv.FormatInt(d.result.Limit, 10))
+		w.Header().Set("RateLimit-Remaining", strconv.FormatInt(d.result.Remaining, 10))
+		w.Header().Set("RateLimit-Reset", seconds(d.result.Reset))
+	}
+
+	if d.exceeded != "" {
+		w.Header().Set("Retry-After", seconds(d.retryAfter))
+		commonController.ShowError(w, r, http.StatusTooManyRequests, d.exceeded)
+
+		return false
+	}
+
+	return true
+}
+
+// clientOf returns the principal subject of the request, or its IP if it is not authenticated.
+func clientOf(r *http.Request) string {
+	principal, err := auth.FromContext(r.Context())
+	if err == nil {
+		return principal.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// seconds formats the duration in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
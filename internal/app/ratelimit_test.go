@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/storage/usage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func limitsApp(t *testing.T, rateLimits map[string]config.RateLimit) *App {
+	t.Helper()
+
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db"), RateLimits: rateLimits}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &App{config: c, limits: newLimits(c, usage.New(c.PathToDB))}
+}
+
+func TestLimitsCheck(t *testing.T) {
+	a := limitsApp(t, map[string]config.RateLimit{
+		limitExchange: {Rate: 1, Burst: 1, DailyQuota: 2},
+		limitRead:     {DailyQuota: 1},
+	})
+
+	day := time.Date(2026, 1, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		client     string
+		group      string
+		at         time.Time
+		exceeded   string
+		retryAfter time.Duration
+	}{
+		{name: "first request", client: "a", group: limitExchange, at: day},
+		{name: "empty bucket", client: "a", group: limitExchange, at: day, exceeded: controller.CodeRateLimitExceeded, retryAfter: time.Second},
+		{name: "refilled bucket", client: "a", group: limitExchange, at: day.Add(time.Second)},
+		{
+			name:       "quota used up",
+			client:     "a",
+			group:      limitExchange,
+			at:         day.Add(time.Minute),
+			exceeded:   controller.CodeQuotaExceeded,
+			retryAfter: 6*time.Hour - time.Minute,
+		},
+		{name: "quota of another client", client: "b", group: limitExchange, at: day.Add(time.Minute)},
+		{name: "quota renewed the next day", client: "a", group: limitExchange, at: day.Add(6 * time.Hour)},
+		{name: "quota without a rate", client: "a", group: limitRead, at: day},
+		{
+			name:       "quota of the group used up",
+			client:     "a",
+			group:      limitRead,
+			at:         day.Add(time.Hour),
+			exceeded:   controller.CodeQuotaExceeded,
+			retryAfter: 5 * time.Hour,
+		},
+		{name: "group without limits", client: "a", group: limitWrite, at: day},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := a.limits.check(context.Background(), tt.client, tt.group, tt.at)
+			if d.exceeded != tt.exceeded || d.retryAfter != tt.retryAfter {
+				t.Errorf("exceeded %q after %v, want %q after %v", d.exceeded, d.retryAfter, tt.exceeded, tt.retryAfter)
+			}
+		})
+	}
+
+	uses, _, err := a.limits.usage.List(usage.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, use := range uses {
+		if use.Route == limitWrite {
+			t.Error("requests of the group without a quota counted")
+		}
+	}
+}
+
+func TestLimitHeaders(t *testing.T) {
+	a := limitsApp(t, map[string]config.RateLimit{limitExchange: {Rate: 0.001, Burst: 2}})
+
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		remaining  string
+		retryAfter bool
+	}{
+		{name: "first request", method: http.MethodGet, status: http.StatusOK, remaining: "1"},
+		{name: "preflight not limited", method: http.MethodOptions, status: http.StatusOK},
+		{name: "last request", method: http.MethodGet, status: http.StatusOK, remaining: "0"},
+		{name: "exceeded", method: http.MethodGet, status: http.StatusTooManyRequests, remaining: "0", retryAfter: true},
+		{name: "write group not limited", method: http.MethodPost, status: http.StatusOK},
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	handler := a.limit(controller.New(controller.V2, i18n.MustNew("en")), limitExchange, limitWrite, ok)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v2/exchange?from=USD&to=EUR&amount=1", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+
+			handler(w, r)
+
+			if w.Code != tt.status || w.Header().Get("RateLimit-Remaining") != tt.remaining {
+				t.Errorf(
+					"status %d with %q remaining, want %d with %q",
+					w.Code, w.Header().Get("RateLimit-Remaining"), tt.status, tt.remaining,
+				)
+			}
+
+			if tt.remaining != "" && (w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Reset") == "") {
+				t.Errorf("RateLimit-Limit %q and RateLimit-Reset %q", w.Header().Get("RateLimit-Limit"), w.Header().Get("RateLimit-Reset"))
+			}
+
+			if (w.Header().Get("Retry-After") != "") != tt.retryAfter {
+				t.Errorf("Retry-After %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/internal/cors"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// configPollInterval is the interval of the checks of the configuration file for changes.
+const configPollInterval = 5 * time.Second
+
+// watchConfig reloads the configuration on SIGHUP and whenever its file changes, until ctx is done.
+func (a *App) watchConfig(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	path := a.config.Path()
+	modified := lastModified(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-ticker.C:
+			if lastModified(path).Equal(modified) {
+				continue
+			}
+		}
+
+		modified = lastModified(path)
+		a.reloadConfig()
+	}
+}
+
+// reloadConfig swaps in the reloadable options of the configuration loaded again and applies them: the cross pivots
+// and the rate stream settings are read per request, the CORS policy, the log level and the limiters of changed
+// groups are replaced.
+// The changed options that need a restart are reported and ignored.
+func (a *App) reloadConfig() {
+	const op = "reloadConfig"
+
+	rejected, err := a.live.Reload()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return
+	}
+
+	for _, key := range rejected {
+		slog.Warn("option not reloaded, restart the service to change it", "option", key)
+	}
+
+	current := a.live.Current()
+
+	err = logging.SetLevel(current.LogLevel)
+	if err != nil {
+		logging.Error(f, op, err)
+	}
+
+	a.limits.update(current)
+	a.corsPolicy.Store(cors.New(current.CORS))
+
+	slog.Info("configuration reloaded", "path", current.Path())
+}
+
+// lastModified returns the modification time of the file, zero if it can't be read.
+func lastModified(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
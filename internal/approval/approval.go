@@ -0,0 +1,282 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const f = "approval.Service"
+
+var (
+	NotPendingError = errors.New("proposal is not pending")
+	// SelfDecisionError is returned when the user proposing a change tries to decide on it, with any of their
+	// credentials
+	SelfDecisionError = errors.New("proposal decided on by its author")
+)
+
+// Service implements the maker-checker workflow: rate changes needing approval are stored as proposals
+// and applied only when another user approves them.
+type Service struct {
+	storageProposals     rateproposals.StorageRateProposals
+	storageCurrencies    currencies.StorageCurrencies
+	storageExchangeRates exchangerates.StorageExchangeRates
+	recorder             *audit.Recorder
+	all                  bool
+	pairs                []string
+	ttl                  time.Duration
+	// mu serializes decisions, so a proposal is applied at most once
+	mu sync.Mutex
+}
+
+func New(
+	config *config.Config,
+	storageProposals rateproposals.StorageRateProposals,
+	storageCurrencies currencies.StorageCurrencies,
+	storageExchangeRates exchangerates.StorageExchangeRates,
+	recorder *audit.Recorder,
+) *Service {
+	pairs := make([]string, len(config.RateApprovalPairs))
+	for i, pair := range config.RateApprovalPairs {
+		pairs[i] = strings.ToUpper(pair)
+	}
+
+	return &Service{
+		storageProposals:     storageProposals,
+		storageCurrencies:    storageCurrencies,
+		storageExchangeRates: storageExchangeRates,
+		recorder:             recorder,
+		all:                  config.RateApproval,
+		pairs:                pairs,
+		ttl:                  time.Duration(config.RateProposalTTL) * time.Second,
+	}
+}
+
+// Required reports whether changes of the pair need approval.
+func (s *Service) Required(base, target string) bool {
+	return s.all || slices.Contains(s.pairs, base+target)
+}
+
+// Propose stores the proposal of the owner of the principal of ctx to create the rate of the pair, or to update
+// the version of exchangeRate if it has an id.
+func (s *Service) Propose(ctx context.Context, exchangeRate entity.ExchangeRates, rate float64) (entity.RateProposal, error) {
+	principal, _ := auth.FromContext(ctx)
+	now := time.Now().UTC()
+
+	proposal := entity.RateProposal{
+		Action:             entity.ProposalCreate,
+		BaseCurrencyCode:   exchangeRate.BaseCurrency.Code,
+		TargetCurrencyCode: exchangeRate.TargetCurrency.Code,
+		Rate:               rate,
+		Status:             entity.ProposalPending,
+		ProposedBy:         principal.Owner,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(s.ttl),
+	}
+
+	if exchangeRate.ID != 0 {
+		proposal.Action = entity.ProposalUpdate
+		proposal.ExchangeRateID = exchangeRate.ID
+		proposal.OldRate = &exchangeRate.Rate
+		proposal.ExchangeRateVersion = exchangeRate.Version
+	}
+
+	id, err := s.storageProposals.Add(proposal)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	proposal.ID = id
+
+	return proposal, nil
+}
+
+// ById returns the proposal, storage.EntitiesNotFoundError if there is none.
+func (s *Service) ById(id int64) (entity.RateProposal, error) {
+	err := s.storageProposals.Expire(time.Now())
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	return s.storageProposals.ById(id)
+}
+
+// List returns the proposals, the newest first, and their total number.
+func (s *Service) List(filter rateproposals.Filter) ([]entity.RateProposal, int64, error) {
+	err := s.storageProposals.Expire(time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s.storageProposals.List(filter)
+}
+
+// Approve applies the proposal on behalf of the principal of ctx. The proposal is claimed as approved first, so
+// it is applied at most once and never left pending with its rate applied. Creating a rate that exists by now
+// fails with storage.EntityAlreadyExistsError, updating a rate changed since the proposal fails with
+// storage.EntityChangedError, both return the proposal to pending.
+func (s *Service) Approve(ctx context.Context, id int64) (entity.RateProposal, error) {
+	const op = "Approve"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proposal, err := s.pending(ctx, id)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	err = s.decide(ctx, &proposal, entity.ProposalApproved, "")
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	exchangeRate, oldRate, err := s.apply(ctx, proposal)
+	if err != nil {
+		reopenErr := s.storageProposals.Reopen(proposal.ID)
+		if reopenErr != nil {
+			logging.ErrorContext(ctx, f, op, reopenErr)
+		}
+
+		return entity.RateProposal{}, err
+	}
+
+	if proposal.ExchangeRateID != exchangeRate.ID {
+		proposal.ExchangeRateID = exchangeRate.ID
+
+		err = s.storageProposals.SetExchangeRateId(proposal.ID, exchangeRate.ID)
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+		}
+	}
+
+	err = s.recorder.ProposalApproved(ctx, exchangeRate, oldRate, proposal)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+	}
+
+	return proposal, nil
+}
+
+// Reject rejects the proposal on behalf of the principal of ctx.
+func (s *Service) Reject(ctx context.Context, id int64, reason string) (entity.RateProposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proposal, err := s.pending(ctx, id)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	err = s.decide(ctx, &proposal, entity.ProposalRejected, reason)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	return proposal, nil
+}
+
+// pending returns the proposal if the principal of ctx may decide on it.
+func (s *Service) pending(ctx context.Context, id int64) (entity.RateProposal, error) {
+	proposal, err := s.ById(id)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	if proposal.Status != entity.ProposalPending {
+		return entity.RateProposal{}, NotPendingError
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	if principal.Owner == proposal.ProposedBy {
+		return entity.RateProposal{}, SelfDecisionError
+	}
+
+	return proposal, nil
+}
+
+func (s *Service) decide(ctx context.Context, proposal *entity.RateProposal, status, reason string) error {
+	principal, _ := auth.FromContext(ctx)
+	now := time.Now().UTC()
+
+	proposal.Status = status
+	proposal.DecidedBy = principal.Owner
+	proposal.Reason = reason
+	proposal.DecidedAt = &now
+
+	err := s.storageProposals.Decide(*proposal)
+	if errors.Is(err, storage.EntitiesNotFoundError) {
+		return NotPendingError
+	}
+
+	return err
+}
+
+// apply writes the rate of the proposal and returns it along with the rate it replaced, nil if the rate was created.
+// An update is applied only to the version of the rate it was proposed for.
+func (s *Service) apply(ctx context.Context, proposal entity.RateProposal) (entity.ExchangeRates, *float64, error) {
+	baseCurrency, err := s.storageCurrencies.ByCode(ctx, proposal.BaseCurrencyCode)
+	if err != nil {
+		return entity.ExchangeRates{}, nil, applyError(err)
+	}
+
+	targetCurrency, err := s.storageCurrencies.ByCode(ctx, proposal.TargetCurrencyCode)
+	if err != nil {
+		return entity.ExchangeRates{}, nil, applyError(err)
+	}
+
+	if proposal.Action == entity.ProposalCreate {
+		exchangeRate := entity.ExchangeRates{
+			BaseCurrency:   baseCurrency,
+			TargetCurrency: targetCurrency,
+			Rate:           proposal.Rate,
+			UpdatedAt:      time.Now().UTC(),
+		}
+
+		exchangeRate.ID, err = s.storageExchangeRates.Add(ctx, exchangeRate)
+		if err != nil {
+			return entity.ExchangeRates{}, nil, applyError(err)
+		}
+
+		return exchangeRate, nil, nil
+	}
+
+	exchangeRate := entity.ExchangeRates{
+		ID:             proposal.ExchangeRateID,
+		BaseCurrency:   baseCurrency,
+		TargetCurrency: targetCurrency,
+		Rate:           proposal.Rate,
+		UpdatedAt:      time.Now().UTC(),
+		Version:        proposal.ExchangeRateVersion,
+	}
+
+	err = s.storageExchangeRates.UpdateRate(ctx, exchangeRate)
+	if err != nil {
+		return entity.ExchangeRates{}, nil, err
+	}
+
+	exchangeRate.Version++
+
+	return exchangeRate, proposal.OldRate, nil
+}
+
+// applyError keeps a missing currency, which is never deleted, from passing for a missing proposal.
+func applyError(err error) error {
+	if errors.Is(err, storage.EntitiesNotFoundError) {
+		return errors.New("apply proposal: currency not found")
+	}
+
+	return err
+}
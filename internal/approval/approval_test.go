@@ -0,0 +1,335 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixture is the service of a new database with the USD/EUR rate stored.
+type fixture struct {
+	service       *Service
+	authenticator *auth.Authenticator
+	exchangeRates *storageExchangeRates.ExchangeRates
+	exchangeRate  entity.ExchangeRates
+}
+
+func setup(t *testing.T) fixture {
+	t.Helper()
+
+	c := &config.Config{
+		PathToDB: filepath.Join(t.TempDir(), "sqlite.db"),
+		Approval: config.Approval{RateApproval: true, RateProposalTTL: 3600},
+	}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currencies := storageCurrencies.New(c.PathToDB, nil, nil)
+	exchangeRates := storageExchangeRates.New(c.PathToDB, nil, nil)
+
+	exchangeRate := entity.ExchangeRates{
+		BaseCurrency:   entity.Currency{Code: "USD", FullName: "US Dollar", Sign: "$"},
+		TargetCurrency: entity.Currency{Code: "EUR", FullName: "Euro", Sign: "€"},
+		Rate:           0.9,
+	}
+
+	for _, currency := range []*entity.Currency{&exchangeRate.BaseCurrency, &exchangeRate.TargetCurrency} {
+		currency.ID, err = currencies.Add(context.Background(), *currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = exchangeRates.Add(context.Background(), exchangeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangeRate, err = exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		context.Background(),
+		exchangeRate.BaseCurrency.ID,
+		exchangeRate.TargetCurrency.ID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := audit.New(rateaudit.New(c.PathToDB))
+
+	return fixture{
+		service:       New(c, rateproposals.New(c.PathToDB), currencies, exchangeRates, recorder),
+		authenticator: auth.New(apikeys.New(c.PathToDB), nil),
+		exchangeRates: exchangeRates,
+		exchangeRate:  exchangeRate,
+	}
+}
+
+// as returns the context of a request authenticated by a new key of the owner.
+func (fx fixture) as(t *testing.T, owner string, scopes ...string) context.Context {
+	t.Helper()
+
+	apiKey, err := fx.authenticator.Issue(owner, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := fx.authenticator.Authenticate(apiKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return auth.NewContext(context.Background(), principal, nil)
+}
+
+func TestApproveRequiresAnotherOwner(t *testing.T) {
+	fx := setup(t)
+	maker := fx.as(t, "alice", auth.ScopeRatesWrite, auth.ScopeRatesApprove)
+
+	proposal, err := fx.service.Propose(maker, fx.exchangeRate, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proposal.ProposedBy != "alice" {
+		t.Errorf("proposed by %q, want the owner of the key", proposal.ProposedBy)
+	}
+
+	// a rotated key of the maker is still the maker
+	_, err = fx.service.Approve(fx.as(t, "alice", auth.ScopeRatesApprove), proposal.ID)
+	if !errors.Is(err, SelfDecisionError) {
+		t.Fatalf("approval by another key of the maker: %v, want SelfDecisionError", err)
+	}
+
+	_, err = fx.service.Reject(maker, proposal.ID, "")
+	if !errors.Is(err, SelfDecisionError) {
+		t.Fatalf("rejection by the maker: %v, want SelfDecisionError", err)
+	}
+
+	approved, err := fx.service.Approve(fx.as(t, "bob", auth.ScopeRatesApprove), proposal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if approved.Status != entity.ProposalApproved || approved.DecidedBy != "bob" {
+		t.Errorf("proposal %+v, want approved by bob", approved)
+	}
+
+	exchangeRate, err := fx.exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		context.Background(),
+		fx.exchangeRate.BaseCurrency.ID,
+		fx.exchangeRate.TargetCurrency.ID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exchangeRate.Rate != 0.95 || exchangeRate.Version != fx.exchangeRate.Version+1 {
+		t.Errorf("exchange rate %+v, want the proposed rate in the next version", exchangeRate)
+	}
+}
+
+func TestApproveRejectsChangedRate(t *testing.T) {
+	fx := setup(t)
+
+	proposal, err := fx.service.Propose(fx.as(t, "alice", auth.ScopeRatesWrite), fx.exchangeRate, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rate is changed after the proposal was made
+	changed := fx.exchangeRate
+	changed.Rate = 0.8
+	changed.UpdatedAt = time.Now().UTC()
+
+	err = fx.exchangeRates.UpdateRate(context.Background(), changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := fx.as(t, "bob", auth.ScopeRatesApprove)
+
+	_, err = fx.service.Approve(checker, proposal.ID)
+	if !errors.Is(err, storage.EntityChangedError) {
+		t.Fatalf("approval of a changed rate: %v, want EntityChangedError", err)
+	}
+
+	exchangeRate, err := fx.exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		context.Background(),
+		fx.exchangeRate.BaseCurrency.ID,
+		fx.exchangeRate.TargetCurrency.ID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exchangeRate.Rate != 0.8 {
+		t.Errorf("rate %v, want the change made after the proposal kept", exchangeRate.Rate)
+	}
+
+	pending, err := fx.service.ById(proposal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pending.Status != entity.ProposalPending {
+		t.Errorf("status %q, want the proposal left pending", pending.Status)
+	}
+
+	_, err = fx.service.Reject(checker, proposal.ID, "outdated")
+	if err != nil {
+		t.Errorf("rejection of the outdated proposal: %v", err)
+	}
+}
+
+// staleProposals reads every proposal as pending, as an instance that read it before another one decided on it.
+type staleProposals struct {
+	rateproposals.StorageRateProposals
+}
+
+func (s staleProposals) ById(id int64) (entity.RateProposal, error) {
+	proposal, err := s.StorageRateProposals.ById(id)
+	proposal.Status = entity.ProposalPending
+
+	return proposal, err
+}
+
+// failingProposals fails to store decisions.
+type failingProposals struct {
+	rateproposals.StorageRateProposals
+}
+
+func (s failingProposals) Decide(entity.RateProposal) error {
+	return errors.New("disk I/O error")
+}
+
+func TestApproveAppliesProposalOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		reverse bool
+	}{
+		{name: "update"},
+		{name: "create", reverse: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := setup(t)
+
+			proposed := fx.exchangeRate
+			if tt.reverse {
+				proposed = entity.ExchangeRates{BaseCurrency: proposed.TargetCurrency, TargetCurrency: proposed.BaseCurrency}
+			}
+
+			proposal, err := fx.service.Propose(fx.as(t, "alice", auth.ScopeRatesWrite), proposed, 1.05)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checker := fx.as(t, "bob", auth.ScopeRatesApprove)
+
+			approved, err := fx.service.Approve(checker, proposal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exchangeRate, err := fx.exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+				context.Background(),
+				proposed.BaseCurrency.ID,
+				proposed.TargetCurrency.ID,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := fx.service.ById(proposal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if approved.ExchangeRateID != exchangeRate.ID || stored.ExchangeRateID != exchangeRate.ID {
+				t.Errorf("proposal linked to the rate %d and stored with %d, want %d", approved.ExchangeRateID, stored.ExchangeRateID, exchangeRate.ID)
+			}
+
+			// another instance approving the proposal it read before the approval
+			stale := &Service{
+				storageProposals:     staleProposals{fx.service.storageProposals},
+				storageCurrencies:    fx.service.storageCurrencies,
+				storageExchangeRates: fx.service.storageExchangeRates,
+				recorder:             fx.service.recorder,
+				all:                  true,
+			}
+
+			_, err = stale.Approve(checker, proposal.ID)
+			if !errors.Is(err, NotPendingError) {
+				t.Fatalf("second approval: %v, want NotPendingError", err)
+			}
+
+			again, err := fx.exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+				context.Background(),
+				proposed.BaseCurrency.ID,
+				proposed.TargetCurrency.ID,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if again.Version != exchangeRate.Version {
+				t.Errorf("version %d, want the rate applied once in version %d", again.Version, exchangeRate.Version)
+			}
+		})
+	}
+}
+
+func TestApproveKeepsRateWhenDecisionFails(t *testing.T) {
+	fx := setup(t)
+
+	proposal, err := fx.service.Propose(fx.as(t, "alice", auth.ScopeRatesWrite), fx.exchangeRate, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fx.service.storageProposals = failingProposals{fx.service.storageProposals}
+
+	_, err = fx.service.Approve(fx.as(t, "bob", auth.ScopeRatesApprove), proposal.ID)
+	if err == nil {
+		t.Fatal("approval stored no decision but succeeded")
+	}
+
+	exchangeRate, err := fx.exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		context.Background(),
+		fx.exchangeRate.BaseCurrency.ID,
+		fx.exchangeRate.TargetCurrency.ID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exchangeRate.Rate != fx.exchangeRate.Rate || exchangeRate.Version != fx.exchangeRate.Version {
+		t.Errorf("exchange rate %+v, want it unchanged without the decision", exchangeRate)
+	}
+
+	pending, err := fx.service.ById(proposal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pending.Status != entity.ProposalPending {
+		t.Errorf("status %q, want the proposal left pending", pending.Status)
+	}
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/requestid"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+)
+
+// Recorder records rate changes along with the principal of the request making them.
+type Recorder struct {
+	storage rateaudit.StorageRateAudit
+}
+
+func New(storage rateaudit.StorageRateAudit) *Recorder {
+	return &Recorder{
+		storage: storage,
+	}
+}
+
+// RateCreated records the creation of the rate.
+func (a *Recorder) RateCreated(ctx context.Context, exchangeRate entity.ExchangeRates) error {
+	return a.record(a.entry(ctx, entity.RateAuditCreated, exchangeRate, nil))
+}
+
+// RateUpdated records the change of the rate from oldRate.
+func (a *Recorder) RateUpdated(ctx context.Context, exchangeRate entity.ExchangeRates, oldRate float64) error {
+	return a.record(a.entry(ctx, entity.RateAuditUpdated, exchangeRate, &oldRate))
+}
+
+// ProposalApproved records the change of the rate approved on the proposal, oldRate is nil if it was created.
+func (a *Recorder) ProposalApproved(
+	ctx context.Context,
+	exchangeRate entity.ExchangeRates,
+	oldRate *float64,
+	proposal entity.RateProposal,
+) error {
+	action := entity.RateAuditUpdated
+	if oldRate == nil {
+		action = entity.RateAuditCreated
+	}
+
+	entry := a.entry(ctx, action, exchangeRate, oldRate)
+	entry.ProposalID = proposal.ID
+	entry.ProposedBy = proposal.ProposedBy
+
+	return a.record(entry)
+}
+
+func (a *Recorder) entry(
+	ctx context.Context,
+	action string,
+	exchangeRate entity.ExchangeRates,
+	oldRate *float64,
+) entity.RateAudit {
+	principal, _ := auth.FromContext(ctx)
+
+	return entity.RateAudit{
+		ExchangeRateID:     exchangeRate.ID,
+		BaseCurrencyCode:   exchangeRate.BaseCurrency.Code,
+		TargetCurrencyCode: exchangeRate.TargetCurrency.Code,
+		Action:             action,
+		OldRate:            oldRate,
+		NewRate:            exchangeRate.Rate,
+		Subject:            principal.Subject,
+		RequestID:          requestid.FromContext(ctx),
+		ChangedAt:          exchangeRate.UpdatedAt,
+	}
+}
+
+func (a *Recorder) record(entry entity.RateAudit) error {
+	_, err := a.storage.Add(entry)
+
+	return err
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes of API keys and token roles.
+const (
+	ScopeRatesRead       = "rates:read"
+	ScopeRatesWrite      = "rates:write"
+	ScopeCurrenciesWrite = "currencies:write"
+	// ScopeRatesApprove allows to approve and reject rate proposals of other users
+	ScopeRatesApprove = "rates:approve"
+	// ScopeAdmin grants every scope but rates:approve, which is granted only explicitly, so the admin issuing keys
+	// is not a checker as well
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists all scopes.
+var Scopes = []string{ScopeRatesRead, ScopeRatesWrite, ScopeRatesApprove, ScopeCurrenciesWrite, ScopeAdmin}
+
+// KeyHeader is the header carrying the key, as an alternative to "Authorization: Bearer <key>".
+const KeyHeader = "X-API-Key"
+
+// keyPrefix starts every key, so leaked keys are easy to recognize.
+const keyPrefix = "ce_"
+
+var (
+	MissingCredentialsError = errors.New("credentials missing")
+	InvalidCredentialsError = errors.New("api key invalid or revoked, or token invalid")
+)
+
+// Principal is the authenticated client.
+type Principal struct {
+	// Subject is the sub claim of a token, or apikey:<prefix> for API keys
+	Subject string
+	// Owner is the user the credential belongs to: the sub claim of a token, or the name of an API key, which
+	// all keys of the user, including the rotated ones, share
+	Owner  string
+	Scopes []string
+}
+
+// HasScope reports whether the principal is granted the scope.
+func (p Principal) HasScope(scope string) bool {
+	if slices.Contains(p.Scopes, scope) {
+		return true
+	}
+
+	return scope != ScopeRatesApprove && slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// Authenticator accepts API keys and, if a verifier is given, JWT bearer tokens.
+type Authenticator struct {
+	storage  apikeys.StorageAPIKeys
+	verifier *JWTVerifier
+}
+
+// New returns the authenticator, verifier may be nil if tokens are not accepted.
+func New(storage apikeys.StorageAPIKeys, verifier *JWTVerifier) *Authenticator {
+	return &Authenticator{
+		storage:  storage,
+		verifier: verifier,
+	}
+}
+
+// Issue stores a new key with the scopes and returns it with the plain key, which is not stored.
+func (a *Authenticator) Issue(name string, scopes []string) (entity.APIKey, error) {
+	b := make([]byte, 24)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+
+	key := keyPrefix + hex.EncodeToString(b)
+
+	apiKey := entity.APIKey{
+		Name:      name,
+		Prefix:    key[:len(keyPrefix)+8],
+		Scopes:    scopes,
+		Key:       key,
+		Hash:      Hash(key),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	apiKey.ID, err = a.storage.Add(apiKey)
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+
+	return apiKey, nil
+}
+
+// Authenticate returns the principal of the API key or the token, InvalidCredentialsError if they are not valid.
+func (a *Authenticator) Authenticate(credential string) (Principal, error) {
+	if a.verifier != nil && !strings.HasPrefix(credential, keyPrefix) && strings.Count(credential, ".") == 2 {
+		return a.verifier.Verify(credential)
+	}
+
+	apiKey, err := a.storage.ByHash(Hash(credential))
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			return Principal{}, InvalidCredentialsError
+		}
+
+		return Principal{}, err
+	}
+
+	if apiKey.RevokedAt != nil {
+		return Principal{}, InvalidCredentialsError
+	}
+
+	return Principal{Subject: "apikey:" + apiKey.Prefix, Owner: apiKey.Name, Scopes: apiKey.Scopes}, nil
+}
+
+// Request authenticates the credential sent with the request and returns the request carrying the outcome,
+// which is read with FromContext.
+func (a *Authenticator) Request(r *http.Request) *http.Request {
+	credential := CredentialFromRequest(r)
+	if credential == "" {
+		return r
+	}
+
+	principal, err := a.Authenticate(credential)
+
+	return r.WithContext(NewContext(r.Context(), principal, err))
+}
+
+type contextKey struct{}
+
+type credentials struct {
+	principal Principal
+	err       error
+}
+
+// NewContext returns the context carrying the outcome of an authentication.
+func NewContext(ctx context.Context, principal Principal, err error) context.Context {
+	return context.WithValue(ctx, contextKey{}, credentials{principal: principal, err: err})
+}
+
+// FromContext returns the authenticated principal, MissingCredentialsError if the request had no credential
+// or the error of its authentication.
+func FromContext(ctx context.Context) (Principal, error) {
+	c, ok := ctx.Value(contextKey{}).(credentials)
+	if !ok {
+		return Principal{}, MissingCredentialsError
+	}
+
+	return c.principal, c.err
+}
+
+// CredentialFromRequest returns the Authorization bearer token or the key of the X-API-Key header.
+func CredentialFromRequest(r *http.Request) string {
+	return Credential(r.Header.Get("Authorization"), r.Header.Get(KeyHeader))
+}
+
+// Credential returns the credential of the authorization value, "Bearer <credential>", or of the key value.
+func Credential(authorization, key string) string {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if ok {
+		return strings.TrimSpace(token)
+	}
+
+	return strings.TrimSpace(key)
+}
+
+// Hash returns the stored form of the key. Keys are random enough for a plain SHA-256.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdminDoesNotImplyApprove(t *testing.T) {
+	admin := Principal{Scopes: []string{ScopeAdmin}}
+
+	for _, scope := range []string{ScopeRatesRead, ScopeRatesWrite, ScopeCurrenciesWrite, ScopeAdmin} {
+		if !admin.HasScope(scope) {
+			t.Errorf("admin is not granted %s", scope)
+		}
+	}
+
+	if admin.HasScope(ScopeRatesApprove) {
+		t.Errorf("admin is granted %s without it", ScopeRatesApprove)
+	}
+
+	checker := Principal{Scopes: []string{ScopeAdmin, ScopeRatesApprove}}
+	if !checker.HasScope(ScopeRatesApprove) {
+		t.Errorf("%s not granted explicitly", ScopeRatesApprove)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := apikeys.New(c.PathToDB)
+	authenticator := New(storage, nil)
+
+	reader, err := authenticator.Issue("reader", []string{ScopeRatesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := authenticator.Issue("revoked", []string{ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Revoke(revoked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		credential string
+		owner      string
+		err        error
+	}{
+		{name: "issued key", credential: reader.Key, owner: "reader"},
+		{name: "revoked key", credential: revoked.Key, err: InvalidCredentialsError},
+		{name: "unknown key", credential: keyPrefix + strings.Repeat("0", 48), err: InvalidCredentialsError},
+		{name: "hash of the key", credential: reader.Hash, err: InvalidCredentialsError},
+		{name: "token without a verifier", credential: "a.b.c", err: InvalidCredentialsError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(tt.credential)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+
+			if principal.Owner != tt.owner {
+				t.Errorf("owner %q, want %q", principal.Owner, tt.owner)
+			}
+		})
+	}
+
+	keys, err := storage.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, apiKey := range keys {
+		if apiKey.Key != "" || apiKey.Hash == reader.Key || apiKey.Hash == revoked.Key {
+			t.Errorf("key %s stored in plain", apiKey.Prefix)
+		}
+	}
+}
+
+func TestCredentialFromRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		key           string
+		want          string
+	}{
+		{name: "none"},
+		{name: "bearer", authorization: "Bearer ce_1", want: "ce_1"},
+		{name: "key header", key: " ce_2 ", want: "ce_2"},
+		{name: "bearer over key header", authorization: "Bearer ce_1", key: "ce_2", want: "ce_1"},
+		{name: "other scheme", authorization: "Basic dXNlcjpwYXNz", key: "ce_2", want: "ce_2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", tt.authorization)
+			r.Header.Set(KeyHeader, tt.key)
+
+			got := CredentialFromRequest(r)
+			if got != tt.want {
+				t.Errorf("credential %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
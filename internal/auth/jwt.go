@@ -0,0 +1,450 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const f = "auth.JWTVerifier"
+
+// jwksRefetch is the least interval between fetches of the JWKS, it is fetched again when a token is signed
+// by an unknown key, so rotated keys are picked up.
+const jwksRefetch = time.Minute
+
+// jwksRetry is the interval before the first retry of a failed fetch of the JWKS, it doubles with every failure
+// up to jwksRefetch, so an unavailable JWKS is not fetched on every request.
+const jwksRetry = time.Second
+
+// leeway allows for clock skew in the checks of exp and nbf.
+const leeway = 30 * time.Second
+
+// JWTVerifier verifies bearer tokens signed by a key of the configured JWKS and maps their roles to scopes.
+type JWTVerifier struct {
+	jwks       string
+	issuer     string
+	audience   string
+	rolesClaim string
+	roles      map[string][]string
+	client     *http.Client
+	mu         sync.Mutex
+	keys       map[string]crypto.PublicKey
+	// nextFetch is the time the JWKS may be fetched again, after the last attempt
+	nextFetch time.Time
+	// failures counts the failed fetches since the last successful one, fetchErr is the error of the last one
+	failures int
+	fetchErr error
+}
+
+// NewJWTVerifier returns the verifier of the config, nil if tokens are not accepted.
+func NewJWTVerifier(config *config.Config) *JWTVerifier {
+	if config.JWTJWKS == "" {
+		return nil
+	}
+
+	return &JWTVerifier{
+		jwks:       config.JWTJWKS,
+		issuer:     config.JWTIssuer,
+		audience:   config.JWTAudience,
+		rolesClaim: config.JWTRolesClaim,
+		roles:      config.JWTRoles,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify returns the principal of the token, its subject and the scopes of its roles.
+// It returns an error wrapping InvalidCredentialsError if the token is not valid.
+func (v *JWTVerifier) Verify(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, invalidToken("malformed")
+	}
+
+	header := jwtHeader{}
+
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return Principal{}, invalidToken("malformed header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, invalidToken("malformed signature")
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	claims := map[string]any{}
+
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return Principal{}, invalidToken("malformed claims")
+	}
+
+	err = v.validate(claims, time.Now())
+	if err != nil {
+		return Principal{}, err
+	}
+
+	subject := claims["sub"].(string)
+
+	return Principal{Subject: subject, Owner: subject, Scopes: v.scopes(claims)}, nil
+}
+
+func (v *JWTVerifier) validate(claims map[string]any, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return invalidToken("exp missing")
+	}
+
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return invalidToken("expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return invalidToken("not valid yet")
+	}
+
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return invalidToken("issuer mismatch")
+	}
+
+	if v.audience != "" && !slices.Contains(stringList(claims["aud"]), v.audience) {
+		return invalidToken("audience mismatch")
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return invalidToken("sub missing")
+	}
+
+	return nil
+}
+
+// scopes returns the scopes granted to the roles of the roles claim, which may be nested, as realm_access.roles.
+func (v *JWTVerifier) scopes(claims map[string]any) []string {
+	var value any = claims
+
+	for _, name := range strings.Split(v.rolesClaim, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		value = object[name]
+	}
+
+	scopes := []string{}
+
+	for _, role := range stringList(value) {
+		for _, scope := range v.roles[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// key returns the key with the id, the only key of the JWKS if the token names none. Unknown keys are looked up
+// in the JWKS fetched again, at most once per jwksRefetch, and after failed fetches once their backoff has passed.
+// While the JWKS can not be fetched tokens are rejected as invalid, the error of each fetch is logged once.
+func (v *JWTVerifier) key(kid string) (crypto.PublicKey, error) {
+	const op = "key"
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.lookup(kid)
+	if ok {
+		return key, nil
+	}
+
+	now := time.Now()
+	if now.Before(v.nextFetch) {
+		if v.fetchErr != nil {
+			return nil, v.fetchErr
+		}
+
+		return nil, invalidToken("unknown key")
+	}
+
+	keys, err := v.fetch()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		v.failures++
+		v.fetchErr = invalidToken("not verifiable, " + err.Error())
+		v.nextFetch = now.Add(retryDelay(v.failures))
+
+		return nil, v.fetchErr
+	}
+
+	v.keys = keys
+	v.failures = 0
+	v.fetchErr = nil
+	v.nextFetch = now.Add(jwksRefetch)
+
+	key, ok = v.lookup(kid)
+	if !ok {
+		return nil, invalidToken("unknown key")
+	}
+
+	return key, nil
+}
+
+// retryDelay returns the backoff after the failed fetches, doubling from jwksRetry up to jwksRefetch.
+func retryDelay(failures int) time.Duration {
+	delay := jwksRetry
+	for i := 1; i < failures && delay < jwksRefetch; i++ {
+		delay *= 2
+	}
+
+	return min(delay, jwksRefetch)
+}
+
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+
+	return key, ok
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch reads the JWKS from its URL or file. Keys not meant for signatures and of unsupported types are skipped.
+func (v *JWTVerifier) fetch() (map[string]crypto.PublicKey, error) {
+	var body []byte
+	var err error
+
+	if strings.HasPrefix(v.jwks, "http://") || strings.HasPrefix(v.jwks, "https://") {
+		body, err = v.download()
+	} else {
+		body, err = os.ReadFile(v.jwks)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	set := jwks{}
+
+	err = json.Unmarshal(body, &set)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (v *JWTVerifier) download() ([]byte, error) {
+	response, err := v.client.Get(v.jwks)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", response.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := bigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := bigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384()}
+
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := bigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := bigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+}
+
+// ecdsaCurves are the curves of the keys the ES algorithms are defined for.
+var ecdsaCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := algorithms[alg]
+	if !ok {
+		return invalidToken("unsupported alg " + alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") || rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return invalidToken("bad signature")
+		}
+
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if ecdsaCurves[alg] != key.Curve || len(signature) != 2*size {
+			return invalidToken("bad signature")
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+
+		if !ecdsa.Verify(key, digest, r, s) {
+			return invalidToken("bad signature")
+		}
+
+		return nil
+	}
+
+	return invalidToken("bad signature")
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+func bigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// stringList returns the strings of a claim holding a string array or a space separated string.
+func stringList(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		list := make([]string, 0, len(value))
+
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	}
+
+	return nil
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: token %s", InvalidCredentialsError, reason)
+}
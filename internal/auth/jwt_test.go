@@ -0,0 +1,366 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jwksServer is a JWKS endpoint stub serving the public keys set, or failing with the status set.
+type jwksServer struct {
+	mu       sync.Mutex
+	keys     []jwk
+	status   int
+	requests int
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(jwks{Keys: s.keys})
+}
+
+func (s *jwksServer) set(status int, keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = status
+	s.keys = keys
+}
+
+func (s *jwksServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   encode(key.N.Bytes()),
+		E:   encode(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	size := (key.Curve.Params().BitSize + 7) / 8
+
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: key.Curve.Params().Name,
+		X:   encode(key.X.FillBytes(make([]byte, size))),
+		Y:   encode(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+// sign returns the token of the claims signed with the key by the algorithm, RS256, ES256 or ES384.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(jwtHeader{Alg: alg, Kid: kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := encode(header) + "." + encode(payload)
+
+	var digest []byte
+
+	if alg == "ES384" {
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	}
+
+	var signature []byte
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+
+	return signed + "." + encode(signature)
+}
+
+func claims(overrides map[string]any) map[string]any {
+	c := map[string]any{
+		"sub":   "alice",
+		"iss":   "https://issuer.test",
+		"aud":   []string{"currency-exchange"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"operator"},
+	}
+
+	for name, value := range overrides {
+		if value == nil {
+			delete(c, name)
+
+			continue
+		}
+
+		c[name] = value
+	}
+
+	return c
+}
+
+func newVerifier(t *testing.T, stub *jwksServer) *JWTVerifier {
+	t.Helper()
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	return NewJWTVerifier(&config.Config{JWT: config.JWT{
+		JWTJWKS:       server.URL,
+		JWTIssuer:     "https://issuer.test",
+		JWTAudience:   "currency-exchange",
+		JWTRolesClaim: "roles",
+		JWTRoles:      map[string][]string{"operator": {ScopeRatesRead, ScopeRatesWrite}},
+	}})
+}
+
+func TestVerifyAcceptsSignedTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &jwksServer{}
+	stub.set(0, rsaJWK("rsa", rsaKey), ecJWK("p256", p256), ecJWK("p384", p384))
+	verifier := newVerifier(t, stub)
+
+	tokens := map[string]string{
+		"RS256": sign(t, "RS256", "rsa", rsaKey, claims(nil)),
+		"ES256": sign(t, "ES256", "p256", p256, claims(nil)),
+		"ES384": sign(t, "ES384", "p384", p384, claims(nil)),
+	}
+
+	for alg, token := range tokens {
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			t.Errorf("%s: %v", alg, err)
+
+			continue
+		}
+
+		if principal.Subject != "alice" {
+			t.Errorf("%s: subject %q, want alice", alg, principal.Subject)
+		}
+
+		if !slices.Equal(principal.Scopes, []string{ScopeRatesRead, ScopeRatesWrite}) {
+			t.Errorf("%s: scopes %v, want the scopes of operator", alg, principal.Scopes)
+		}
+	}
+
+	if stub.count() != 1 {
+		t.Errorf("JWKS fetched %d times, want once", stub.count())
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &jwksServer{}
+	stub.set(0, rsaJWK("rsa", rsaKey), ecJWK("p256", p256))
+	verifier := newVerifier(t, stub)
+
+	valid := strings.Split(sign(t, "ES256", "p256", p256, claims(nil)), ".")
+	tampered, err := json.Marshal(claims(map[string]any{"sub": "mallory"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"expired":         sign(t, "ES256", "p256", p256, claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"not valid yet":   sign(t, "ES256", "p256", p256, claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"exp missing":     sign(t, "ES256", "p256", p256, claims(map[string]any{"exp": nil})),
+		"sub missing":     sign(t, "ES256", "p256", p256, claims(map[string]any{"sub": nil})),
+		"other issuer":    sign(t, "ES256", "p256", p256, claims(map[string]any{"iss": "https://other.test"})),
+		"other audience":  sign(t, "ES256", "p256", p256, claims(map[string]any{"aud": "other"})),
+		"other key":       sign(t, "ES256", "p256", other, claims(nil)),
+		"alg of RSA":      sign(t, "RS256", "p256", p256, claims(nil)),
+		"alg of P-384":    sign(t, "ES384", "p256", p256, claims(nil)),
+		"ES256 of RSA":    sign(t, "ES256", "rsa", p256, claims(nil)),
+		"alg none":        encode([]byte(`{"alg":"none","kid":"p256"}`)) + "." + valid[1] + ".",
+		"tampered claims": valid[0] + "." + encode(tampered) + "." + valid[2],
+		"malformed":       "a.b",
+	}
+
+	for name, token := range tokens {
+		_, err := verifier.Verify(token)
+		if !errors.Is(err, InvalidCredentialsError) {
+			t.Errorf("%s: error %v, want InvalidCredentialsError", name, err)
+		}
+	}
+}
+
+func TestVerifyFetchesRotatedKeys(t *testing.T) {
+	old, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &jwksServer{}
+	stub.set(0, ecJWK("old", old))
+	verifier := newVerifier(t, stub)
+
+	_, err = verifier.Verify(sign(t, "ES256", "old", old, claims(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub.set(0, ecJWK("old", old), ecJWK("rotated", rotated))
+
+	// the JWKS fetched less than jwksRefetch ago is not fetched again for an unknown key
+	_, err = verifier.Verify(sign(t, "ES256", "rotated", rotated, claims(nil)))
+	if !errors.Is(err, InvalidCredentialsError) {
+		t.Fatalf("error %v, want InvalidCredentialsError", err)
+	}
+
+	verifier.mu.Lock()
+	verifier.nextFetch = time.Now()
+	verifier.mu.Unlock()
+
+	_, err = verifier.Verify(sign(t, "ES256", "rotated", rotated, claims(nil)))
+	if err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+
+	if stub.count() != 2 {
+		t.Errorf("JWKS fetched %d times, want twice", stub.count())
+	}
+}
+
+func TestVerifyBacksOffFailedFetches(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &jwksServer{}
+	stub.set(http.StatusServiceUnavailable)
+	verifier := newVerifier(t, stub)
+
+	token := sign(t, "ES256", "key", key, claims(nil))
+
+	for range 5 {
+		_, err = verifier.Verify(token)
+		if !errors.Is(err, InvalidCredentialsError) || !strings.Contains(err.Error(), "status 503") {
+			t.Fatalf("error %v, want the token rejected with the error of the fetch", err)
+		}
+	}
+
+	if stub.count() != 1 {
+		t.Errorf("JWKS fetched %d times during the backoff, want once", stub.count())
+	}
+
+	stub.set(0, ecJWK("key", key))
+
+	verifier.mu.Lock()
+	verifier.nextFetch = time.Now()
+	verifier.mu.Unlock()
+
+	_, err = verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("token after the JWKS recovered: %v", err)
+	}
+
+	if verifier.failures != 0 || verifier.fetchErr != nil {
+		t.Errorf("failures %d and error %v kept after a successful fetch", verifier.failures, verifier.fetchErr)
+	}
+}
+
+func TestRetryDelayDoublesUpToRefetch(t *testing.T) {
+	delays := map[int]time.Duration{
+		1:  jwksRetry,
+		2:  2 * jwksRetry,
+		3:  4 * jwksRetry,
+		10: jwksRefetch,
+		64: jwksRefetch,
+	}
+
+	for failures, want := range delays {
+		if got := retryDelay(failures); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit are set when building, as with -ldflags "-X .../buildinfo.Version=1.2.0". Commit defaults to
+// the VCS revision stamped by go build.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Info describes the build of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build info.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+
+	if info.Commit != "" {
+		return info
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, setting := range build.Settings {
+		if setting.Key == "vcs.revision" {
+			info.Commit = setting.Value
+		}
+	}
+
+	return info
+}
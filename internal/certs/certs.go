@@ -0,0 +1,199 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const f = "certs.Reloader"
+
+// pollInterval is the interval of the checks of the files for changes.
+const pollInterval = 5 * time.Second
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Reloader serves the certificate, and the client CAs if any, read from the files and read again once they change,
+// so renewed certificates are used without a restart.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	minVersion   uint16
+	current      atomic.Pointer[tls.Config]
+	// strict is current requiring a verified client certificate if client CAs are set, served to gRPC
+	strict   atomic.Pointer[tls.Config]
+	modified time.Time
+}
+
+// New reads the files of the TLS settings of config, it returns nil if TLS is not configured.
+func New(config *config.Config) (*Reloader, error) {
+	if config.TLSCert == "" && config.TLSKey == "" {
+		return nil, nil
+	}
+
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return nil, errors.New("both tls_cert and tls_key are required")
+	}
+
+	minVersion, ok := versions[config.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("tls_min_version %q is not one of 1.2, 1.3", config.TLSMinVersion)
+	}
+
+	r := &Reloader{
+		certFile:     config.TLSCert,
+		keyFile:      config.TLSKey,
+		clientCAFile: config.TLSClientCA,
+		minVersion:   minVersion,
+	}
+
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// HTTPConfig returns the TLS config of the HTTP server, offering HTTP/2 and HTTP/1.1. Client certificates are
+// verified if given, the server requires them per route, so health probes need none.
+func (r *Reloader) HTTPConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// GRPCConfig returns the TLS config of the gRPC server, requiring verified client certificates if client CAs are set.
+func (r *Reloader) GRPCConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: []string{"h2"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.strict.Load(), nil
+		},
+	}
+}
+
+// Verified reports whether the client of the connection presented a certificate signed by the client CAs.
+func Verified(state *tls.ConnectionState) bool {
+	return state != nil && len(state.VerifiedChains) > 0
+}
+
+// Watch reads the files again whenever they change, until ctx is done. The current certificate is kept if they
+// cannot be read.
+func (r *Reloader) Watch(ctx context.Context) {
+	const op = "Watch"
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modified, err := r.lastModified()
+		if err != nil {
+			logging.Error(f, op, err)
+
+			continue
+		}
+
+		if modified.Equal(r.modified) {
+			continue
+		}
+
+		err = r.load()
+		if err != nil {
+			logging.Error(f, op, err)
+
+			continue
+		}
+
+		logging.FromContext(ctx).Info("certificate reloaded", "cert", r.certFile)
+	}
+}
+
+func (r *Reloader) load() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	current := &tls.Config{
+		MinVersion:   r.minVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in %s", r.clientCAFile)
+		}
+
+		current.ClientCAs = pool
+		current.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	strict := current.Clone()
+	strict.NextProtos = []string{"h2"}
+	if strict.ClientCAs != nil {
+		strict.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.current.Store(current)
+	r.strict.Store(strict)
+	r.modified = modified
+
+	return nil
+}
+
+// lastModified returns the latest modification time of the files.
+func (r *Reloader) lastModified() (time.Time, error) {
+	var last time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last, nil
+}
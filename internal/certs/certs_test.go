@@ -0,0 +1,341 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authority signs the certificates of a test.
+type authority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authority{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns the PEM certificate and key of name, a server certificate for 127.0.0.1 or a client certificate.
+func (a *authority) issue(t *testing.T, name string, server bool) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (a *authority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.certificate)
+
+	return pool
+}
+
+func write(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fixture writes the server certificate signed by ca and the client CAs to a temporary directory and returns
+// the TLS settings reading them.
+func fixture(t *testing.T, ca *authority, clientCA *authority) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	c := &config.Config{TLS: config.TLS{
+		TLSCert:       filepath.Join(dir, "server.crt"),
+		TLSKey:        filepath.Join(dir, "server.key"),
+		TLSClientCA:   filepath.Join(dir, "client-ca.crt"),
+		TLSMinVersion: "1.2",
+	}}
+
+	certificate, key := ca.issue(t, "server", true)
+	write(t, c.TLSCert, certificate)
+	write(t, c.TLSKey, key)
+	write(t, c.TLSClientCA, clientCA.pem)
+
+	return c
+}
+
+// handshake connects to a server of the config and returns the state of the connection seen by the server.
+func handshake(t *testing.T, server *tls.Config, client *tls.Config) (tls.ConnectionState, error) {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	type result struct {
+		state tls.ConnectionState
+		err   error
+	}
+
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			accepted <- result{err: err}
+
+			return
+		}
+		defer conn.Close()
+
+		tlsConn := conn.(*tls.Conn)
+		err = tlsConn.Handshake()
+		accepted <- result{state: tlsConn.ConnectionState(), err: err}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), client)
+	if err == nil {
+		// the client finishes its handshake before the server verifies its certificate
+		_, _ = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+	}
+
+	r := <-accepted
+	if r.err != nil {
+		return r.state, r.err
+	}
+
+	return r.state, err
+}
+
+func TestHTTPConfigVerifiesClientCertificateIfGiven(t *testing.T) {
+	ca := newAuthority(t, "server CA")
+	clientCA := newAuthority(t, "client CA")
+
+	r, err := New(fixture(t, ca, clientCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: ca.pool()})
+	if err != nil {
+		t.Fatalf("handshake without a client certificate: %v", err)
+	}
+
+	if Verified(&state) {
+		t.Error("connection without a client certificate is verified")
+	}
+
+	certificate, key := clientCA.issue(t, "client", false)
+
+	pair, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: ca.pool(), Certificates: []tls.Certificate{pair}})
+	if err != nil {
+		t.Fatalf("handshake with a client certificate: %v", err)
+	}
+
+	if !Verified(&state) {
+		t.Error("connection with a client certificate is not verified")
+	}
+
+	stranger := newAuthority(t, "other CA")
+	certificate, key = stranger.issue(t, "stranger", false)
+
+	pair, err = tls.X509KeyPair(certificate, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate is sent although the server asks for the client CA
+	client := &tls.Config{
+		RootCAs: ca.pool(),
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &pair, nil
+		},
+	}
+
+	_, err = handshake(t, r.HTTPConfig(), client)
+	if err == nil {
+		t.Error("handshake with a certificate of another CA succeeded")
+	}
+}
+
+func TestGRPCConfigRequiresClientCertificate(t *testing.T) {
+	ca := newAuthority(t, "server CA")
+	clientCA := newAuthority(t, "client CA")
+
+	r, err := New(fixture(t, ca, clientCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = handshake(t, r.GRPCConfig(), &tls.Config{RootCAs: ca.pool(), NextProtos: []string{"h2"}})
+	if err == nil {
+		t.Error("handshake without a client certificate succeeded")
+	}
+
+	certificate, key := clientCA.issue(t, "client", false)
+
+	pair, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &tls.Config{RootCAs: ca.pool(), Certificates: []tls.Certificate{pair}, NextProtos: []string{"h2"}}
+
+	state, err := handshake(t, r.GRPCConfig(), client)
+	if err != nil {
+		t.Fatalf("handshake with a client certificate: %v", err)
+	}
+
+	if !Verified(&state) {
+		t.Error("connection with a client certificate is not verified")
+	}
+}
+
+func TestLoadServesRenewedCertificate(t *testing.T) {
+	ca := newAuthority(t, "server CA")
+	clientCA := newAuthority(t, "client CA")
+	c := fixture(t, ca, clientCA)
+
+	r, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed := newAuthority(t, "renewed CA")
+	certificate, key := renewed.issue(t, "server", true)
+	write(t, c.TLSCert, certificate)
+	write(t, c.TLSKey, key)
+
+	err = r.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: renewed.pool()})
+	if err != nil {
+		t.Fatalf("handshake trusting the renewed CA: %v", err)
+	}
+
+	// a broken key keeps the renewed certificate served
+	write(t, c.TLSKey, []byte("broken"))
+
+	err = r.load()
+	if err == nil {
+		t.Fatal("load of a broken key succeeded")
+	}
+
+	_, err = handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: renewed.pool()})
+	if err != nil {
+		t.Fatalf("handshake after a failed reload: %v", err)
+	}
+}
+
+func TestMinVersionRejectsOlderClients(t *testing.T) {
+	ca := newAuthority(t, "server CA")
+	c := fixture(t, ca, newAuthority(t, "client CA"))
+
+	r, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS11, MaxVersion: tls.VersionTLS11}
+
+	_, err = handshake(t, r.HTTPConfig(), client)
+	if err == nil {
+		t.Error("handshake of TLS 1.1 succeeded with tls_min_version 1.2")
+	}
+
+	c.TLSMinVersion = "1.3"
+
+	r, err = New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: ca.pool(), MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		t.Error("handshake of TLS 1.2 succeeded with tls_min_version 1.3")
+	}
+
+	_, err = handshake(t, r.HTTPConfig(), &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS13})
+	if err != nil {
+		t.Errorf("handshake of TLS 1.3: %v", err)
+	}
+}
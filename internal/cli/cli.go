@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// usage lists the scopes from auth.Scopes, so it names every scope a key can be issued with.
+var usage = `Usage:
+  currency_exchange [flags]         start the server
+  currency_exchange [flags] apikey create -name <name> -scopes <scope,...>
+  currency_exchange [flags] apikey list
+  currency_exchange [flags] apikey revoke <id>
+  currency_exchange [flags] config print
+
+Flags are listed by currency_exchange -help.
+
+Scopes: ` + strings.Join(auth.Scopes, ", ") + `
+A checker approving the rate proposals of others needs a key with the rates:approve scope, admin does not grant it.
+The name is the owner of the key: keys of the same name belong to one user, who cannot approve their own proposals.
+`
+
+// CLI runs the administrative commands.
+type CLI struct {
+	config         *config.Config
+	storageAPIKeys apikeys.StorageAPIKeys
+	authenticator  *auth.Authenticator
+	localizer      *i18n.Localizer
+	stdout, stderr io.Writer
+}
+
+func New(config *config.Config, stdout, stderr io.Writer) *CLI {
+	storageAPIKeys := apikeys.New(config.PathToDB)
+
+	return &CLI{
+		config:         config,
+		storageAPIKeys: storageAPIKeys,
+		authenticator:  auth.New(storageAPIKeys, nil),
+		localizer:      i18n.MustNew(config.DefaultLanguage).ForLanguages(),
+		stdout:         stdout,
+		stderr:         stderr,
+	}
+}
+
+// NeedsDatabase reports whether the command of args reads or writes the database, so it must be created and
+// migrated first.
+func NeedsDatabase(args []string) bool {
+	return len(args) > 0 && args[0] == "apikey"
+}
+
+// Run runs the command of args and returns the exit code.
+func (c *CLI) Run(args []string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		return c.printConfig()
+	}
+
+	if len(args) < 2 || args[0] != "apikey" {
+		_, _ = fmt.Fprint(c.stderr, usage)
+
+		return 2
+	}
+
+	switch args[1] {
+	case "create":
+		return c.createAPIKey(args[2:])
+	case "list":
+		return c.listAPIKeys()
+	case "revoke":
+		return c.revokeAPIKey(args[2:])
+	}
+
+	_, _ = fmt.Fprint(c.stderr, usage)
+
+	return 2
+}
+
+func (c *CLI) createAPIKey(args []string) int {
+	flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	name := flags.String("name", "", "name of the key owner")
+	scopes := flags.String("scopes", "", "comma separated scopes")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	validated := validation.NewAPIKeysValues(map[string]string{"name": *name, "scopes": *scopes})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		for _, fieldError := range validated.Errors() {
+			_, _ = fmt.Fprintln(c.stderr, c.localizer.Message(fieldError.Message))
+		}
+
+		return 2
+	}
+
+	apiKey, err := c.authenticator.Issue(validated.Field("name"), validated.Split("scopes"))
+	if err != nil {
+		_, _ = fmt.Fprintln(c.stderr, err)
+
+		return 1
+	}
+
+	_, _ = fmt.Fprintf(c.stdout, "id: %d\nkey: %s\n", apiKey.ID, apiKey.Key)
+	_, _ = fmt.Fprintln(c.stderr, "The key is shown only once, keep it safe.")
+
+	return 0
+}
+
+func (c *CLI) listAPIKeys() int {
+	items, err := c.storageAPIKeys.All()
+	if err != nil {
+		_, _ = fmt.Fprintln(c.stderr, err)
+
+		return 1
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tREVOKED")
+
+	for _, apiKey := range items {
+		revoked := ""
+		if apiKey.RevokedAt != nil {
+			revoked = apiKey.RevokedAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(
+			w,
+			"%d\t%s\t%s\t%s\t%s\t%s\n",
+			apiKey.ID,
+			apiKey.Name,
+			apiKey.Prefix,
+			strings.Join(apiKey.Scopes, ","),
+			apiKey.CreatedAt.Format(time.RFC3339),
+			revoked,
+		)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+func (c *CLI) revokeAPIKey(args []string) int {
+	if len(args) != 1 {
+		_, _ = fmt.Fprint(c.stderr, usage)
+
+		return 2
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		_, _ = fmt.Fprint(c.stderr, usage)
+
+		return 2
+	}
+
+	err = c.storageAPIKeys.Revoke(id)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			_, _ = fmt.Fprintln(c.stderr, c.localizer.Message(i18n.NewMessage(controller.CodeAPIKeyNotFound)))
+
+			return 1
+		}
+
+		_, _ = fmt.Fprintln(c.stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// printConfig prints the effective configuration as TOML, secrets of URLs redacted.
+func (c *CLI) printConfig() int {
+	err := toml.NewEncoder(c.stdout).Encode(c.config.Redacted())
+	if err != nil {
+		_, _ = fmt.Fprintln(c.stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
@@ -1,36 +1,140 @@
 package config
 
-import (
-	"github.com/BurntSushi/toml"
-	"os"
-	"path"
-)
-
 type Config struct {
 	Host     string `toml:"host"`
 	Port     int64  `toml:"port"`
 	PathToDB string `toml:"abs_path_to_database"`
+	// KeyedReads requires the rates:read scope for reads, otherwise only writes need an API key
+	KeyedReads bool `toml:"api_keys_for_reads"`
+	// GRPCPort is the port of the gRPC API, it is not served if zero
+	GRPCPort int64 `toml:"grpc_port"`
+	// DefaultLanguage is used for messages when the client asks for no supported language
+	DefaultLanguage string `toml:"default_language"`
+	// V1Sunset is the HTTP date sent in the Sunset header of v1 responses, none if empty
+	V1Sunset string `toml:"v1_sunset"`
+	// StreamHeartbeat is the interval in seconds of heartbeats sent to idle rate stream clients
+	StreamHeartbeat int64 `toml:"stream_heartbeat"`
+	// StreamBuffer is the number of events buffered for a rate stream client, a slower client is disconnected
+	StreamBuffer int `toml:"stream_buffer"`
+	// IdempotencyTTL is the number of seconds the responses to requests with an Idempotency-Key are replayed for
+	IdempotencyTTL int64 `toml:"idempotency_ttl"`
+	// Maintenance starts the service in maintenance mode, not ready to receive traffic until it is switched off
+	Maintenance bool `toml:"maintenance"`
+	// ReadinessMaxRateAge is the number of seconds since the last rate update after which the service is not ready,
+	// not checked if zero
+	ReadinessMaxRateAge int64 `toml:"readiness_max_rate_age"`
+	// LogLevel is the lowest level of the logged messages: debug, info, warn or error
+	LogLevel string `toml:"log_level"`
+	// CrossPivots are the currencies, as USD, a cross rate is tried via, in order
+	CrossPivots []string `toml:"cross_pivots"`
+	// RateLimits are the limits of the route groups exchange, read and write, groups without limits are not counted
+	RateLimits map[string]RateLimit `toml:"rate_limits"`
+	HTTPServer
+	TLS
 	CORS
+	Webhooks
+	JWT
+	Approval
+	Tracing
+	// args are the arguments and path the file the configuration was loaded with, to load it again
+	args []string
+	path string
 }
 
+// CORS allows the listed origins to call the API from browsers.
 type CORS struct {
-	AccessControlAllowOrigin  string `toml:"access_control_allow_origin"`
+	// AccessControlAllowOrigin are the allowed origins separated by commas, as https://example.com, or
+	// https://*.example.com for its subdomains, or * for any; no origin is allowed if empty
+	AccessControlAllowOrigin string `toml:"access_control_allow_origin"`
+	// AccessControlAllowHeaders are the request headers allowed besides the ones the API reads, * for any
 	AccessControlAllowHeaders string `toml:"access_control_allow_headers"`
+	// AccessControlAllowMethods are the allowed methods, * for any
 	AccessControlAllowMethods string `toml:"access_control_allow_methods"`
+	// AccessControlAllowCredentials allows requests with cookies and client certificates, not with any origin
+	AccessControlAllowCredentials bool `toml:"access_control_allow_credentials"`
+	// AccessControlMaxAge is the number of seconds browsers cache the answers to preflight requests, not sent if zero
+	AccessControlMaxAge int64 `toml:"access_control_max_age"`
+}
+
+// RateLimit limits the requests of every client, identified by its API key, token subject or IP, to a route group.
+type RateLimit struct {
+	// Rate is the number of requests per second the bucket is refilled with, no limit if zero
+	Rate float64 `toml:"rate"`
+	// Burst is the size of the bucket, at least one
+	Burst int64 `toml:"burst"`
+	// DailyQuota is the number of requests a day, counted in the database, no limit if zero
+	DailyQuota int64 `toml:"daily_quota"`
+}
+
+type Webhooks struct {
+	// WebhookMaxAttempts is the number of attempts after which a delivery is moved to the dead-letter list
+	WebhookMaxAttempts int64 `toml:"webhook_max_attempts"`
+	// WebhookBackoff is the delay in seconds before the first retry, it doubles with every next one
+	WebhookBackoff int64 `toml:"webhook_backoff"`
+	// WebhookTimeout is the timeout in seconds of a delivery
+	WebhookTimeout int64 `toml:"webhook_timeout"`
 }
 
-func MustNew() *Config {
-	wd, err := os.Getwd()
-	if err != nil {
-		panic(err)
-	}
+type JWT struct {
+	// JWTJWKS is the path or the http(s) URL of the JWKS verifying bearer tokens, tokens are not accepted if empty.
+	// It is secret as the URL may carry the credentials of the identity provider.
+	JWTJWKS string `toml:"jwt_jwks" secret:"true"`
+	// JWTIssuer is the required iss claim, any if empty
+	JWTIssuer string `toml:"jwt_issuer"`
+	// JWTAudience is the audience the aud claim must contain, any if empty
+	JWTAudience string `toml:"jwt_audience"`
+	// JWTRolesClaim is the claim listing the roles, nested claims are separated by dots
+	JWTRolesClaim string `toml:"jwt_roles_claim"`
+	// JWTRoles maps the roles to the scopes they are granted
+	JWTRoles map[string][]string `toml:"jwt_roles"`
+}
 
-	c := &Config{}
+type Approval struct {
+	// RateApproval makes every rate change a proposal to be approved by another user
+	RateApproval bool `toml:"rate_approval"`
+	// RateApprovalPairs are the pairs, as USDEUR, whose changes need approval even if RateApproval is off
+	RateApprovalPairs []string `toml:"rate_approval_pairs"`
+	// RateProposalTTL is the number of seconds after which a proposal not decided on expires
+	RateProposalTTL int64 `toml:"rate_proposal_ttl"`
+}
 
-	_, err = toml.DecodeFile(path.Join(wd, "config", "app.toml"), c)
-	if err != nil {
-		panic(err)
-	}
+type Tracing struct {
+	// TraceExporter exports the spans to stdout, a file or an OTLP collector: stdout, file or otlp, none if empty
+	TraceExporter string `toml:"trace_exporter"`
+	// TraceFile is the path of the file the spans are appended to by the file exporter
+	TraceFile string `toml:"trace_file"`
+	// TraceEndpoint is the URL spans are sent to by the otlp exporter, as http://localhost:4318/v1/traces.
+	// It is secret as the URL may carry the credentials of the collector.
+	TraceEndpoint string `toml:"trace_endpoint" secret:"true"`
+	// TraceSampleRatio is the share of the traces started by the service that are sampled, from 0 to 1
+	TraceSampleRatio float64 `toml:"trace_sample_ratio"`
+}
+
+// HTTPServer limits the connections of the HTTP server.
+type HTTPServer struct {
+	// ReadTimeout is the number of seconds to read a request, body included
+	ReadTimeout int64 `toml:"read_timeout"`
+	// WriteTimeout is the number of seconds to write a response, rate streams are not limited
+	WriteTimeout int64 `toml:"write_timeout"`
+	// IdleTimeout is the number of seconds a keep-alive connection waits for the next request
+	IdleTimeout int64 `toml:"idle_timeout"`
+	// MaxHeaderBytes is the size limit of the headers of a request
+	MaxHeaderBytes int `toml:"max_header_bytes"`
+	// ShutdownDelay is the number of seconds the server keeps serving, while not ready, after it is told to stop,
+	// so load balancers stop routing traffic to it first
+	ShutdownDelay int64 `toml:"shutdown_delay"`
+	// ShutdownTimeout is the number of seconds in-flight requests are given to complete on shutdown
+	ShutdownTimeout int64 `toml:"shutdown_timeout"`
+}
 
-	return c
+// TLS makes both servers serve TLS, they serve plain connections if no certificate is set.
+type TLS struct {
+	// TLSCert and TLSKey are the paths of the PEM certificate chain and private key, read again once they change
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+	// TLSMinVersion is the lowest accepted TLS version: 1.2 or 1.3
+	TLSMinVersion string `toml:"tls_min_version"`
+	// TLSClientCA is the path of the PEM CAs client certificates must be signed by, none are required if empty.
+	// The health probes are served to clients without a certificate.
+	TLSClientCA string `toml:"tls_client_ca"`
 }
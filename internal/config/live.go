@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Live holds the configuration of the running service, its reloadable options are swapped atomically on reload.
+type Live struct {
+	current atomic.Pointer[Config]
+}
+
+func NewLive(c *Config) *Live {
+	l := &Live{}
+	l.current.Store(c)
+
+	return l
+}
+
+// Current returns the configuration in effect, it must not be modified.
+func (l *Live) Current() *Config {
+	return l.current.Load()
+}
+
+// Reload loads the configuration again, with the arguments it was loaded with, and swaps in its reloadable options:
+// CORS, rate limits, cross pivots, the log level and the heartbeat and buffer of new rate streams. It returns
+// the changed options that need a restart, they are kept as they are. Nothing is swapped if the configuration is
+// invalid.
+func (l *Live) Reload() ([]string, error) {
+	current := l.Current()
+
+	loaded, _, err := Load(current.args)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *current
+	next.CORS = loaded.CORS
+	next.RateLimits = loaded.RateLimits
+	next.CrossPivots = loaded.CrossPivots
+	next.LogLevel = loaded.LogLevel
+	next.StreamHeartbeat = loaded.StreamHeartbeat
+	next.StreamBuffer = loaded.StreamBuffer
+
+	var rejected []string
+
+	loadedFields := fields(loaded)
+	for i, o := range fields(&next) {
+		if !reflect.DeepEqual(o.value.Interface(), loadedFields[i].value.Interface()) {
+			rejected = append(rejected, o.key)
+		}
+	}
+
+	l.current.Store(&next)
+
+	return rejected, nil
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/fs"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix prefixes the names of the environment variables overriding the options, as CURRENCY_EXCHANGE_PORT.
+const EnvPrefix = "CURRENCY_EXCHANGE_"
+
+// DefaultPath is the configuration file read if no other is set, it may be missing.
+const DefaultPath = "config/app.toml"
+
+const redacted = "REDACTED"
+
+// option is a scalar or list option of the configuration, set by its environment variable and flag as well.
+type option struct {
+	key   string
+	value reflect.Value
+	// secret options are replaced as a whole by Redacted, they are tagged secret:"true"
+	secret bool
+}
+
+// Load builds the configuration from the defaults, the TOML file, the environment variables and the flags
+// of args, each layer overriding the previous ones, and validates it. It returns the arguments left after the flags.
+func Load(args []string) (*Config, []string, error) {
+	c := defaults()
+
+	flags := flag.NewFlagSet("currency_exchange", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: currency_exchange [flags] [command]\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	configPath := flags.String(
+		"config",
+		"",
+		fmt.Sprintf("path of the TOML configuration file (env %sCONFIG, default %s)", EnvPrefix, DefaultPath),
+	)
+
+	// flags are collected first, they are applied once the file and the environment variables are
+	flagValues := map[string]string{}
+	for _, o := range options(c) {
+		key := o.key
+		usage := fmt.Sprintf("overrides %s and %s", key, envName(key))
+		flags.Func(flagName(key), usage, func(s string) error {
+			flagValues[key] = s
+
+			return nil
+		})
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.args = args
+
+	err = c.decodeFile(*configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, o := range options(c) {
+		name := envName(o.key)
+
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err = set(o.value, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	for _, o := range options(c) {
+		s, ok := flagValues[o.key]
+		if !ok {
+			continue
+		}
+
+		err = set(o.value, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-%s: %w", flagName(o.key), err)
+		}
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, flags.Args(), nil
+}
+
+// Path returns the path of the configuration file, it may not exist.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Redacted returns a copy of the configuration safe to show: the secret options that are set are replaced,
+// as are the user info and query parameters of the URLs of the other options.
+func (c *Config) Redacted() *Config {
+	r := *c
+
+	for _, o := range options(&r) {
+		if o.value.Kind() != reflect.String {
+			continue
+		}
+
+		if o.secret && o.value.String() != "" {
+			o.value.SetString(redacted)
+
+			continue
+		}
+
+		o.value.SetString(redactURL(o.value.String()))
+	}
+
+	return &r
+}
+
+// decodeFile decodes the file at path, or at the path of the environment variable, over the defaults.
+// The default file is skipped if it does not exist.
+func (c *Config) decodeFile(path string) error {
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+
+	optional := path == ""
+	if optional {
+		path = DefaultPath
+	}
+
+	c.path = path
+
+	_, err := toml.DecodeFile(path, c)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func defaults() *Config {
+	return &Config{
+		Host:            "localhost",
+		Port:            3001,
+		PathToDB:        "database/sqlite.db",
+		DefaultLanguage: "ru",
+		CrossPivots:     []string{"USD"},
+		LogLevel:        "info",
+		StreamHeartbeat: 15,
+		StreamBuffer:    64,
+		IdempotencyTTL:  86400,
+		Webhooks: Webhooks{
+			WebhookMaxAttempts: 8,
+			WebhookBackoff:     30,
+			WebhookTimeout:     10,
+		},
+		JWT: JWT{
+			JWTRolesClaim: "roles",
+		},
+		Approval: Approval{
+			RateProposalTTL: 86400,
+		},
+		Tracing: Tracing{
+			TraceSampleRatio: 1,
+		},
+		TLS: TLS{
+			TLSMinVersion: "1.2",
+		},
+		CORS: CORS{
+			AccessControlAllowOrigin:  "",
+			AccessControlAllowHeaders: "Origin, Accept, Content-Type, Content-Length, Accept-Encoding",
+			AccessControlAllowMethods: "*",
+			AccessControlMaxAge:       600,
+		},
+		HTTPServer: HTTPServer{
+			ReadTimeout:     15,
+			WriteTimeout:    30,
+			IdleTimeout:     120,
+			MaxHeaderBytes:  1 << 20,
+			ShutdownTimeout: 30,
+		},
+	}
+}
+
+// options lists the options of c that are not tables, the embedded sections included.
+func options(c *Config) []option {
+	var items []option
+	for _, o := range fields(c) {
+		if settable(o.value.Type()) {
+			items = append(items, o)
+		}
+	}
+
+	return items
+}
+
+// fields lists all the options of c, the embedded sections included.
+func fields(c *Config) []option {
+	var items []option
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i))
+
+				continue
+			}
+
+			key := field.Tag.Get("toml")
+			if key == "" || key == "-" {
+				continue
+			}
+
+			items = append(items, option{key: key, value: v.Field(i), secret: field.Tag.Get("secret") == "true"})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem())
+
+	return items
+}
+
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+
+	return false
+}
+
+// set parses s into v, lists are separated by commas.
+func set(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(split(s)))
+	}
+
+	return nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// redactURL hides the user info and the query parameter values of s if it is a URL, other values are kept.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return s
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	} else if u.User != nil {
+		u.User = url.User(redacted)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			query.Set(name, redacted)
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
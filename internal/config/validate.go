@@ -0,0 +1,297 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// token matches the names of HTTP methods and headers.
+var token = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+var currencyCode = regexp.MustCompile("^[A-Z]{3}$")
+
+// rateLimitGroups are the route groups rate_limits may limit.
+var rateLimitGroups = []string{"exchange", "read", "write"}
+
+// Validate checks the ports, the path of the database, the HTTP server limits, the CORS options, the cross pivots,
+// the rate limits, the log level, the rate stream and webhook settings, the TLS and the tracing settings, it returns
+// all the problems found.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port: must be from 1 to 65535, got %d", c.Port))
+	}
+
+	if c.GRPCPort < 0 || c.GRPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("grpc_port: must be from 0 to 65535, got %d", c.GRPCPort))
+	} else if c.GRPCPort != 0 && c.GRPCPort == c.Port {
+		errs = append(errs, fmt.Errorf("grpc_port: must differ from port %d", c.Port))
+	}
+
+	err := validateDatabasePath(c.PathToDB)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("abs_path_to_database: %w", err))
+	}
+
+	errs = append(errs, c.HTTPServer.validate()...)
+	errs = append(errs, c.CORS.validate()...)
+
+	for _, code := range c.CrossPivots {
+		if !currencyCode.MatchString(code) {
+			errs = append(errs, fmt.Errorf("cross_pivots: %q is not a currency code as USD", code))
+		}
+	}
+
+	groups := make([]string, 0, len(c.RateLimits))
+	for group := range c.RateLimits {
+		groups = append(groups, group)
+	}
+
+	slices.Sort(groups)
+
+	for _, group := range groups {
+		errs = append(errs, c.RateLimits[group].validate(group)...)
+	}
+
+	if c.StreamHeartbeat < 1 {
+		errs = append(errs, fmt.Errorf("stream_heartbeat: must be at least 1 second, got %d", c.StreamHeartbeat))
+	}
+
+	if c.StreamBuffer < 1 {
+		errs = append(errs, fmt.Errorf("stream_buffer: must be at least 1 event, got %d", c.StreamBuffer))
+	}
+
+	errs = append(errs, c.Webhooks.validate()...)
+	errs = append(errs, c.TLS.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+
+	var level slog.Level
+
+	err = level.UnmarshalText([]byte(c.LogLevel))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %q is not debug, info, warn or error", c.LogLevel))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateDatabasePath(path string) error {
+	if path == "" {
+		return errors.New("must be set")
+	}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// the file is created on start, its directory is not
+	dir := filepath.Dir(path)
+
+	info, err = os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory %s: %w", dir, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return nil
+}
+
+func (h HTTPServer) validate() []error {
+	var errs []error
+
+	for _, timeout := range []struct {
+		key     string
+		seconds int64
+	}{
+		{key: "read_timeout", seconds: h.ReadTimeout},
+		{key: "write_timeout", seconds: h.WriteTimeout},
+		{key: "idle_timeout", seconds: h.IdleTimeout},
+		{key: "shutdown_timeout", seconds: h.ShutdownTimeout},
+	} {
+		if timeout.seconds < 1 {
+			errs = append(errs, fmt.Errorf("%s: must be at least 1 second, got %d", timeout.key, timeout.seconds))
+		}
+	}
+
+	if h.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_delay: must not be negative, got %d", h.ShutdownDelay))
+	}
+
+	if h.MaxHeaderBytes < 1 {
+		errs = append(errs, fmt.Errorf("max_header_bytes: must be at least 1 byte, got %d", h.MaxHeaderBytes))
+	}
+
+	return errs
+}
+
+func (l RateLimit) validate(group string) []error {
+	var errs []error
+
+	if !slices.Contains(rateLimitGroups, group) {
+		errs = append(errs, fmt.Errorf("rate_limits.%s: is not a group, use exchange, read or write", group))
+	}
+
+	if !(l.Rate >= 0) {
+		errs = append(errs, fmt.Errorf("rate_limits.%s.rate: must not be negative, got %g", group, l.Rate))
+	}
+
+	if l.Burst < 0 {
+		errs = append(errs, fmt.Errorf("rate_limits.%s.burst: must not be negative, got %d", group, l.Burst))
+	}
+
+	if l.DailyQuota < 0 {
+		errs = append(errs, fmt.Errorf("rate_limits.%s.daily_quota: must not be negative, got %d", group, l.DailyQuota))
+	}
+
+	return errs
+}
+
+func (w Webhooks) validate() []error {
+	var errs []error
+
+	if w.WebhookMaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhook_max_attempts: must be at least 1, got %d", w.WebhookMaxAttempts))
+	}
+
+	if w.WebhookBackoff < 1 {
+		errs = append(errs, fmt.Errorf("webhook_backoff: must be at least 1 second, got %d", w.WebhookBackoff))
+	}
+
+	if w.WebhookTimeout < 1 {
+		errs = append(errs, fmt.Errorf("webhook_timeout: must be at least 1 second, got %d", w.WebhookTimeout))
+	}
+
+	return errs
+}
+
+func (c CORS) validate() []error {
+	var errs []error
+
+	anyOrigin := false
+
+	for _, origin := range split(c.AccessControlAllowOrigin) {
+		if origin == "*" {
+			anyOrigin = true
+
+			continue
+		}
+
+		if !validOrigin(strings.Replace(origin, "://*.", "://", 1)) {
+			errs = append(errs, fmt.Errorf(
+				"access_control_allow_origin: %q is not * or an origin as https://example.com or https://*.example.com",
+				origin,
+			))
+		}
+	}
+
+	if anyOrigin && c.AccessControlAllowCredentials {
+		errs = append(errs, errors.New("access_control_allow_credentials: list the origins allowed, not *"))
+	}
+
+	if c.AccessControlAllowHeaders != "*" {
+		for _, header := range split(c.AccessControlAllowHeaders) {
+			if !token.MatchString(header) {
+				errs = append(errs, fmt.Errorf("access_control_allow_headers: %q is not * or a header name", header))
+			}
+		}
+	}
+
+	if c.AccessControlAllowMethods != "*" {
+		for _, method := range split(c.AccessControlAllowMethods) {
+			if !token.MatchString(method) {
+				errs = append(errs, fmt.Errorf("access_control_allow_methods: %q is not * or a method", method))
+			}
+		}
+	}
+
+	return errs
+}
+
+func (t TLS) validate() []error {
+	var errs []error
+
+	if (t.TLSCert == "") != (t.TLSKey == "") {
+		errs = append(errs, errors.New("tls_cert, tls_key: set both or neither"))
+	}
+
+	if t.TLSClientCA != "" && t.TLSCert == "" {
+		errs = append(errs, errors.New("tls_client_ca: requires tls_cert and tls_key"))
+	}
+
+	if t.TLSMinVersion != "1.2" && t.TLSMinVersion != "1.3" {
+		errs = append(errs, fmt.Errorf("tls_min_version: %q is not 1.2 or 1.3", t.TLSMinVersion))
+	}
+
+	return errs
+}
+
+func (t Tracing) validate() []error {
+	var errs []error
+
+	switch t.TraceExporter {
+	case "", "stdout":
+	case "file":
+		if t.TraceFile == "" {
+			errs = append(errs, errors.New("trace_file: must be set for the file exporter"))
+		}
+	case "otlp":
+		u, err := url.Parse(t.TraceEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf(
+				"trace_endpoint: %q is not a URL as http://localhost:4318/v1/traces",
+				t.TraceEndpoint,
+			))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("trace_exporter: %q is not stdout, file, otlp or empty", t.TraceExporter))
+	}
+
+	if !(t.TraceSampleRatio >= 0 && t.TraceSampleRatio <= 1) {
+		errs = append(errs, fmt.Errorf("trace_sample_ratio: must be from 0 to 1, got %g", t.TraceSampleRatio))
+	}
+
+	return errs
+}
+
+func validOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User == nil &&
+		(u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == ""
+}
+
+// split splits a comma separated list, dropping empty items.
+func split(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		// want are the beginnings of the errors, none if the configuration is valid
+		want []string
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{name: "port", change: func(c *Config) { c.Port = 0 }, want: []string{"port:"}},
+		{name: "grpc port of the HTTP server", change: func(c *Config) { c.GRPCPort = c.Port }, want: []string{"grpc_port:"}},
+		{name: "database directory missing", change: func(c *Config) { c.PathToDB = "/missing/sqlite.db" }, want: []string{"abs_path_to_database:"}},
+		{
+			name: "HTTP timeouts",
+			change: func(c *Config) {
+				c.ReadTimeout, c.WriteTimeout, c.IdleTimeout, c.ShutdownTimeout, c.ShutdownDelay = 0, -1, 0, 0, -1
+			},
+			want: []string{"read_timeout:", "write_timeout:", "idle_timeout:", "shutdown_timeout:", "shutdown_delay:"},
+		},
+		{name: "header size", change: func(c *Config) { c.MaxHeaderBytes = 0 }, want: []string{"max_header_bytes:"}},
+		{
+			name:   "any origin with credentials",
+			change: func(c *Config) { c.AccessControlAllowOrigin, c.AccessControlAllowCredentials = "*", true },
+			want:   []string{"access_control_allow_credentials:"},
+		},
+		{
+			name:   "origin with a path",
+			change: func(c *Config) { c.AccessControlAllowOrigin = "https://example.com/app, https://*.example.org" },
+			want:   []string{"access_control_allow_origin:"},
+		},
+		{name: "header name", change: func(c *Config) { c.AccessControlAllowHeaders = "X Bad" }, want: []string{"access_control_allow_headers:"}},
+		{name: "cross pivot", change: func(c *Config) { c.CrossPivots = []string{"usd"} }, want: []string{"cross_pivots:"}},
+		{
+			name: "rate limits",
+			change: func(c *Config) {
+				c.RateLimits = map[string]RateLimit{
+					"write":    {Rate: -1, Burst: -1, DailyQuota: -1},
+					"exchange": {Rate: 10, Burst: 20, DailyQuota: 1000},
+					"reads":    {Rate: 1},
+				}
+			},
+			want: []string{"rate_limits.reads:", "rate_limits.write.rate:", "rate_limits.write.burst:", "rate_limits.write.daily_quota:"},
+		},
+		{
+			name:   "webhook delivery",
+			change: func(c *Config) { c.WebhookMaxAttempts, c.WebhookBackoff, c.WebhookTimeout = 0, 0, -1 },
+			want:   []string{"webhook_max_attempts:", "webhook_backoff:", "webhook_timeout:"},
+		},
+		{name: "stream buffer", change: func(c *Config) { c.StreamBuffer = 0 }, want: []string{"stream_buffer:"}},
+		{name: "TLS key without certificate", change: func(c *Config) { c.TLSKey = "key.pem" }, want: []string{"tls_cert, tls_key:"}},
+		{name: "trace exporter", change: func(c *Config) { c.TraceExporter = "zipkin" }, want: []string{"trace_exporter:"}},
+		{name: "log level", change: func(c *Config) { c.LogLevel = "verbose" }, want: []string{"log_level:"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaults()
+			c.PathToDB = filepath.Join(t.TempDir(), "sqlite.db")
+			tt.change(c)
+
+			var got []string
+
+			err := c.Validate()
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("errors %q, want %q", got, tt.want)
+			}
+
+			for i, prefix := range tt.want {
+				if !strings.HasPrefix(got[i], prefix) {
+					t.Errorf("error %q, want %q", got[i], prefix)
+				}
+			}
+		})
+	}
+}
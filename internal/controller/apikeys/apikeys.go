@@ -0,0 +1,121 @@
+package apikeys
+
+import (
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"net/http"
+	"strconv"
+)
+
+const f = "apikeys.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	storageAPIKeys   apikeys.StorageAPIKeys
+	authenticator    *auth.Authenticator
+}
+
+func New(
+	commonController controller.ServerResponse,
+	storageAPIKeys apikeys.StorageAPIKeys,
+	authenticator *auth.Authenticator,
+) *Controller {
+	return &Controller{
+		commonController: commonController,
+		storageAPIKeys:   storageAPIKeys,
+		authenticator:    authenticator,
+	}
+}
+
+func (ca *Controller) APIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		ca.apiKeysGetHandler(w, r)
+
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		ca.apiKeysAddHandler(w, r)
+
+		return
+	}
+
+	ca.commonController.ShowMethodNotAllowedError(w, r)
+}
+
+// APIKeyHandler revokes the key on DELETE, revoked keys are kept in the list.
+func (ca *Controller) APIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "APIKeyHandler"
+
+	if r.Method != http.MethodDelete {
+		ca.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		ca.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeAPIKeyNotFound)
+
+		return
+	}
+
+	err = ca.storageAPIKeys.Revoke(id)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			ca.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeAPIKeyNotFound)
+
+			return
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		ca.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ca *Controller) apiKeysGetHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "apiKeysGetHandler"
+
+	items, err := ca.storageAPIKeys.All()
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		ca.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	ca.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
+
+// apiKeysAddHandler issues the key, the response is the only one showing the plain key.
+func (ca *Controller) apiKeysAddHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "apiKeysAddHandler"
+
+	validated := validation.NewAPIKeys(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		ca.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	apiKey, err := ca.authenticator.Issue(validated.Field("name"), validated.Split("scopes"))
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		ca.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	ca.commonController.ShowResponse(w, r, http.StatusCreated, apiKey)
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"net/http"
+)
+
+const f = "audit.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	storageRateAudit rateaudit.StorageRateAudit
+}
+
+func New(commonController controller.ServerResponse, storageRateAudit rateaudit.StorageRateAudit) *Controller {
+	return &Controller{
+		commonController: commonController,
+		storageRateAudit: storageRateAudit,
+	}
+}
+
+// RatesHandler shows the audit log of rate changes, the newest first.
+func (ca *Controller) RatesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "RatesHandler"
+
+	if r.Method != http.MethodGet {
+		ca.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	validated := validation.NewRateAudit(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		ca.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := rateaudit.Filter{
+		Base:        validated.Field("base"),
+		Target:      validated.Field("target"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := ca.storageRateAudit.List(filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		ca.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	ca.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
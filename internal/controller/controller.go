@@ -3,85 +3,217 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/albakov/go-currency-exchange/internal/util"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/requestid"
+	"github.com/albakov/go-currency-exchange/internal/storage"
 	"net/http"
+	"strconv"
 )
 
 const f = "controller.Controller"
 
 type ServerResponse interface {
-	ShowResponse(w http.ResponseWriter, statusCode int, msg interface{})
-	ShowError(w http.ResponseWriter, statusCode int, message string)
-	ShowMethodNotAllowedError(w http.ResponseWriter)
+	ShowResponse(w http.ResponseWriter, r *http.Request, statusCode int, msg interface{})
+	ShowError(w http.ResponseWriter, r *http.Request, statusCode int, code string)
+	ShowValidationError(w http.ResponseWriter, r *http.Request, err ValidationError)
+	ShowMethodNotAllowedError(w http.ResponseWriter, r *http.Request)
 	ShowReadyToPatch(w http.ResponseWriter)
+	Present(r *http.Request, msg interface{}) interface{}
+	ExchangeRateETag(r *http.Request, exchangeRate entity.ExchangeRates) string
+	Version() Version
+}
+
+// Version is the version of the API contract.
+type Version int
+
+const (
+	// V1 renders the entities and errors as they were before versioning: numbers as JSON numbers, the currency name
+	// as name, rates without updatedAt and errors as {"message": "..."}.
+	V1 Version = 1
+	// V2 renders decimals as strings, the currency name as fullName and errors only as problem details.
+	V2 Version = 2
+)
+
+// ValidationError is a failed request validation.
+type ValidationError interface {
+	StatusCode() int
+	Code() string
+	ErrorMessage() i18n.Message
+	Fields() map[string]i18n.Message
 }
 
 type Controller struct {
+	version Version
+	catalog *i18n.Catalog
 }
 
+// errorResponse is the error shape of V1, used before problem details.
 type errorResponse struct {
 	Message string `json:"message"`
 }
 
-func New() *Controller {
-	return &Controller{}
+// New returns the controller rendering the version contract. Messages are translated with catalog into the language
+// asked for by the client.
+func New(version Version, catalog *i18n.Catalog) *Controller {
+	return &Controller{
+		version: version,
+		catalog: catalog,
+	}
+}
+
+func (c *Controller) Version() Version {
+	return c.version
 }
 
-func (c *Controller) ShowResponse(w http.ResponseWriter, statusCode int, msg interface{}) {
+// ShowResponse responds with msg. Successful reads are tagged with an ETag, the weak tag of the body unless
+// the handler has set one, and answered with 304 Not Modified if the If-None-Match header matches it.
+func (c *Controller) ShowResponse(w http.ResponseWriter, r *http.Request, statusCode int, msg interface{}) {
 	const op = "ShowResponse"
 
-	c.setHeaders(w)
+	msg = c.present(c.localizer(w, r), msg)
 
 	response, err := json.Marshal(msg)
 	if err != nil {
-		util.LogError(f, op, fmt.Errorf("convert response to json: %v", err))
-		c.ShowError(w, http.StatusInternalServerError, MessageServerError)
+		logging.ErrorContext(r.Context(), f, op, fmt.Errorf("convert response to json: %v", err))
+		c.ShowError(w, r, http.StatusInternalServerError, CodeServerError)
 
 		return
 	}
 
-	w.WriteHeader(statusCode)
+	if statusCode == http.StatusOK && isRead(r) {
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			etag = weakETag(response)
+			w.Header().Set("ETag", etag)
+		}
 
-	_, err = fmt.Fprint(w, string(response))
-	if err != nil {
-		util.LogError(f, op, fmt.Errorf("response to json: %v", err))
+		if ifNoneMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
 
-		return
+			return
+		}
 	}
+
+	c.write(w, statusCode, "application/json", response)
+}
+
+// Present returns msg as ShowResponse renders it, for responses written by other means, e.g. streams.
+func (c *Controller) Present(r *http.Request, msg interface{}) interface{} {
+	return c.present(c.catalog.Localizer(r), msg)
 }
 
-func (c *Controller) ShowError(w http.ResponseWriter, statusCode int, message string) {
+func (c *Controller) ShowError(w http.ResponseWriter, r *http.Request, statusCode int, code string) {
+	c.showError(w, r, statusCode, code, i18n.NewMessage(code), nil)
+}
+
+// ShowValidationError responds with the first validation error as the message and the errors of all fields.
+func (c *Controller) ShowValidationError(w http.ResponseWriter, r *http.Request, err ValidationError) {
+	c.showError(w, r, err.StatusCode(), err.Code(), err.ErrorMessage(), err.Fields())
+}
+
+func (c *Controller) ShowMethodNotAllowedError(w http.ResponseWriter, r *http.Request) {
+	c.ShowError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed)
+}
+
+func (c *Controller) ShowReadyToPatch(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetPageHeaders reports the total number of matching entities and, if there is
+// a next page, the cursor pointing to it.
+func SetPageHeaders(w http.ResponseWriter, total, nextOffset int64) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	if nextOffset >= 0 {
+		w.Header().Set("X-Next-Cursor", storage.EncodeCursor(nextOffset))
+	}
+}
+
+func (c *Controller) showError(
+	w http.ResponseWriter,
+	r *http.Request,
+	statusCode int,
+	code string,
+	message i18n.Message,
+	fieldMessages map[string]i18n.Message,
+) {
 	const op = "ShowError"
 
-	c.setHeaders(w)
+	localizer := c.localizer(w, r)
 
-	response := errorResponse{Message: message}
-	res, err := json.Marshal(response)
-	if err != nil {
-		util.LogError(f, op, fmt.Errorf("convert response to json: %v", err))
-		w.WriteHeader(http.StatusInternalServerError)
+	if c.version == V1 {
+		res, err := json.Marshal(errorResponse{Message: localizer.Message(message)})
+		if err != nil {
+			logging.ErrorContext(r.Context(), f, op, fmt.Errorf("convert response to json: %v", err))
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		c.write(w, statusCode, "application/json", res)
 
 		return
 	}
 
-	w.WriteHeader(statusCode)
+	var fields map[string]string
 
-	_, err = fmt.Fprint(w, string(res))
+	if len(fieldMessages) > 0 {
+		fields = make(map[string]string, len(fieldMessages))
+
+		for field, fieldMessage := range fieldMessages {
+			fields[field] = localizer.Message(fieldMessage)
+		}
+	}
+
+	response := problem{
+		Type:      problemTypePrefix + code,
+		Code:      code,
+		Title:     localizer.Title(code),
+		Status:    statusCode,
+		Detail:    localizer.Message(message),
+		Instance:  r.URL.Path,
+		Errors:    fields,
+		RequestID: requestid.FromContext(r.Context()),
+	}
+
+	res, err := json.Marshal(response)
 	if err != nil {
-		util.LogError(f, op, fmt.Errorf("response to json: %v", err))
+		logging.ErrorContext(r.Context(), f, op, fmt.Errorf("convert response to json: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
 
 		return
 	}
+
+	c.write(w, statusCode, "application/problem+json", res)
 }
 
-func (c *Controller) ShowMethodNotAllowedError(w http.ResponseWriter) {
-	c.ShowError(w, http.StatusMethodNotAllowed, MessageMethodNotAllowed)
+// present translates msg and converts it into the version contract.
+func (c *Controller) present(localizer *i18n.Localizer, msg interface{}) interface{} {
+	return Present(c.version, localizeEntities(localizer, msg))
 }
 
-func (c *Controller) ShowReadyToPatch(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusOK)
+// localizer returns the localizer of the request language and announces the language in the response.
+func (c *Controller) localizer(w http.ResponseWriter, r *http.Request) *i18n.Localizer {
+	localizer := c.catalog.Localizer(r)
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", localizer.Language())
+
+	return localizer
 }
 
-func (c *Controller) setHeaders(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
+func (c *Controller) write(w http.ResponseWriter, statusCode int, contentType string, response []byte) {
+	const op = "write"
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+
+	_, err := w.Write(response)
+	if err != nil {
+		logging.Error(f, op, fmt.Errorf("write response: %v", err))
+
+		return
+	}
 }
@@ -2,12 +2,11 @@ package currencies
 
 import (
 	"errors"
-	"github.com/albakov/go-currency-exchange/internal/config"
 	"github.com/albakov/go-currency-exchange/internal/controller"
 	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
 	"github.com/albakov/go-currency-exchange/internal/storage"
 	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
-	"github.com/albakov/go-currency-exchange/internal/util"
 	"github.com/albakov/go-currency-exchange/internal/validation"
 	"net/http"
 )
@@ -19,16 +18,16 @@ type Controller struct {
 	commonController  controller.ServerResponse
 }
 
-func New(config *config.Config, commonController controller.ServerResponse) *Controller {
+func New(commonController controller.ServerResponse, storageCurrencies currencies.StorageCurrencies) *Controller {
 	return &Controller{
-		storageCurrencies: currencies.New(config.PathToDB),
+		storageCurrencies: storageCurrencies,
 		commonController:  commonController,
 	}
 }
 
 func (cc *Controller) CurrenciesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		cc.currenciesGetHandler(w)
+		cc.currenciesGetHandler(w, r)
 
 		return
 	}
@@ -39,12 +38,12 @@ func (cc *Controller) CurrenciesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	cc.commonController.ShowMethodNotAllowedError(w)
+	cc.commonController.ShowMethodNotAllowedError(w, r)
 }
 
 func (cc *Controller) CurrencyCodeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		cc.commonController.ShowMethodNotAllowedError(w)
+		cc.commonController.ShowMethodNotAllowedError(w, r)
 
 		return
 	}
@@ -53,65 +52,96 @@ func (cc *Controller) CurrencyCodeHandler(w http.ResponseWriter, r *http.Request
 
 	code := r.PathValue("code")
 	if code == "" {
-		cc.commonController.ShowError(w, http.StatusBadRequest, controller.MessageCurrencyCodeEmpty)
+		cc.commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeCurrencyCodeEmpty)
 
 		return
 	}
 
-	currency, err := cc.storageCurrencies.ByCode(code)
+	currency, err := cc.storageCurrencies.ByCode(r.Context(), code)
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			cc.commonController.ShowError(w, http.StatusNotFound, controller.MessageCurrencyNotFound)
+			cc.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeCurrencyNotFound)
 
 			return
 		}
 
-		util.LogError(f, op, err)
-		cc.commonController.ShowError(w, http.StatusInternalServerError, controller.MessageServerError)
+		logging.ErrorContext(r.Context(), f, op, err)
+		cc.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
 
 		return
 	}
 
-	cc.commonController.ShowResponse(w, http.StatusOK, currency)
+	cc.commonController.ShowResponse(w, r, http.StatusOK, currency)
 }
 
-func (cc *Controller) currenciesGetHandler(w http.ResponseWriter) {
-	cc.commonController.ShowResponse(w, http.StatusOK, cc.storageCurrencies.All())
+func (cc *Controller) currenciesGetHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "currenciesGetHandler"
+
+	validated := validation.NewCurrenciesList(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cc.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := currencies.Filter{
+		CodePrefix:  validated.Field("code"),
+		Name:        validated.Field("name"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := cc.storageCurrencies.List(r.Context(), filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cc.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	cc.commonController.ShowResponse(w, r, http.StatusOK, items)
 }
 
 func (cc *Controller) currenciesAddHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "currenciesAddHandler"
 
-	validated := validation.NewCurrencies(r, map[string]string{"name": "", "code": "", "sign": ""})
+	nameField := "name"
+	if cc.commonController.Version() >= controller.V2 {
+		nameField = "fullName"
+	}
+
+	validated := validation.NewCurrencies(r, nameField)
 	validated.Validate()
 
 	if !validated.IsValid() {
-		cc.commonController.ShowError(w, http.StatusBadRequest, validated.ErrorMessage())
+		cc.commonController.ShowValidationError(w, r, validated)
 
 		return
 	}
 
 	currency := entity.Currency{
 		Code:     validated.Field("code"),
-		FullName: validated.Field("name"),
+		FullName: validated.Field(nameField),
 		Sign:     validated.Field("sign"),
 	}
 
-	id, err := cc.storageCurrencies.Add(currency)
+	id, err := cc.storageCurrencies.Add(r.Context(), currency)
 	if err != nil {
 		if errors.Is(err, storage.EntityAlreadyExistsError) {
-			cc.commonController.ShowError(w, http.StatusConflict, controller.MessageCurrencyAlreadyExists)
+			cc.commonController.ShowError(w, r, http.StatusConflict, controller.CodeCurrencyAlreadyExists)
 
 			return
 		}
 
-		util.LogError(f, op, err)
-		cc.commonController.ShowError(w, http.StatusInternalServerError, controller.MessageServerError)
+		logging.ErrorContext(r.Context(), f, op, err)
+		cc.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
 
 		return
 	}
 
 	currency.ID = id
 
-	cc.commonController.ShowResponse(w, http.StatusCreated, currency)
+	cc.commonController.ShowResponse(w, r, http.StatusCreated, currency)
 }
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"net/http"
+	"strings"
+)
+
+// ExchangeRateETag returns the strong entity tag of the rate as rendered for r: it changes with the version
+// of the rate, the version contract and the language of the response.
+func (c *Controller) ExchangeRateETag(r *http.Request, exchangeRate entity.ExchangeRates) string {
+	return fmt.Sprintf(
+		`"%d-%d-v%d-%s"`,
+		exchangeRate.ID,
+		exchangeRate.Version,
+		c.version,
+		c.catalog.Localizer(r).Language(),
+	)
+}
+
+// IfMatch reports whether the If-Match value, * or a list of entity tags, matches the etag.
+// If-Match uses the strong comparison, weak tags never match.
+func IfMatch(value, etag string) bool {
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag == etag && !strings.HasPrefix(tag, "W/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifNoneMatch reports whether the If-None-Match value, * or a list of entity tags, matches the etag.
+// If-None-Match uses the weak comparison.
+func ifNoneMatch(value, etag string) bool {
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag != "" && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// weakETag returns the weak entity tag of the response body.
+func weakETag(response []byte) string {
+	sum := sha256.Sum256(response)
+
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func isRead(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
@@ -5,84 +5,106 @@ import (
 	"github.com/albakov/go-currency-exchange/internal/config"
 	"github.com/albakov/go-currency-exchange/internal/controller"
 	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
 	"github.com/albakov/go-currency-exchange/internal/services"
 	"github.com/albakov/go-currency-exchange/internal/storage"
 	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
 	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
 	"github.com/albakov/go-currency-exchange/internal/validation"
 	"net/http"
 )
 
+const f = "exchange.Controller"
+
 type Controller struct {
+	live                 *config.Live
 	commonController     controller.ServerResponse
 	storageExchangeRates exchangerates.StorageExchangeRates
 	storageCurrencies    currencies.StorageCurrencies
 }
 
-func New(config *config.Config, commonController controller.ServerResponse) *Controller {
+func New(
+	live *config.Live,
+	commonController controller.ServerResponse,
+	storageCurrencies currencies.StorageCurrencies,
+	storageExchangeRates exchangerates.StorageExchangeRates,
+) *Controller {
 	return &Controller{
+		live:                 live,
 		commonController:     commonController,
-		storageExchangeRates: exchangerates.New(config.PathToDB),
-		storageCurrencies:    currencies.New(config.PathToDB),
+		storageExchangeRates: storageExchangeRates,
+		storageCurrencies:    storageCurrencies,
 	}
 }
 
 func (ce Controller) Exchange(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		ce.commonController.ShowMethodNotAllowedError(w)
+		ce.commonController.ShowMethodNotAllowedError(w, r)
 
 		return
 	}
 
-	validated := validation.NewExchange(
-		r,
-		map[string]string{"from": "", "to": "", "amount": ""},
-	)
+	const op = "Exchange"
+
+	_, span := tracing.Start(r.Context(), "validation.Exchange")
+	validated := validation.NewExchange(r)
 	validated.Validate()
+	span.End()
 
 	if !validated.IsValid() {
-		ce.commonController.ShowError(w, http.StatusBadRequest, validated.ErrorMessage())
+		ce.commonController.ShowValidationError(w, r, validated)
 
 		return
 	}
 
-	baseCurrency, err := ce.storageCurrencies.ByCode(validated.Field("from"))
+	baseCurrency, err := ce.storageCurrencies.ByCode(r.Context(), validated.Field("from"))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	targetCurrency, err := ce.storageCurrencies.ByCode(validated.Field("to"))
+	targetCurrency, err := ce.storageCurrencies.ByCode(r.Context(), validated.Field("to"))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
 	exchangeService := services.New(
 		ce.storageCurrencies,
 		ce.storageExchangeRates,
+		ce.live.Current().CrossPivots,
 		baseCurrency.ID,
 		targetCurrency.ID,
-		validated.Amount(),
+		validated.Float("amount"),
 	)
-	rate, err := exchangeService.Rate()
+	rate, err := exchangeService.Rate(r.Context())
 	if err != nil {
 		if errors.Is(err, services.NotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesPairNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesPairNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
@@ -90,9 +112,9 @@ func (ce Controller) Exchange(w http.ResponseWriter, r *http.Request) {
 		BaseCurrency:    baseCurrency,
 		TargetCurrency:  targetCurrency,
 		Rate:            rate,
-		Amount:          validated.Amount(),
+		Amount:          validated.Float("amount"),
 		ConvertedAmount: exchangeService.ConvertedAmount(),
 	}
 
-	ce.commonController.ShowResponse(w, http.StatusOK, exchange)
+	ce.commonController.ShowResponse(w, r, http.StatusOK, exchange)
 }
@@ -2,16 +2,19 @@ package exchangerates
 
 import (
 	"errors"
-	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/audit"
 	"github.com/albakov/go-currency-exchange/internal/controller"
 	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
 	"github.com/albakov/go-currency-exchange/internal/storage"
 	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
 	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
-	"github.com/albakov/go-currency-exchange/internal/util"
 	"github.com/albakov/go-currency-exchange/internal/validation"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const f = "exchangerates.Controller"
@@ -20,19 +23,29 @@ type Controller struct {
 	commonController     controller.ServerResponse
 	storageExchangeRates exchangerates.StorageExchangeRates
 	storageCurrencies    currencies.StorageCurrencies
+	recorder             *audit.Recorder
+	approval             *approval.Service
 }
 
-func New(config *config.Config, commonController controller.ServerResponse) *Controller {
+func New(
+	commonController controller.ServerResponse,
+	storageCurrencies currencies.StorageCurrencies,
+	storageExchangeRates exchangerates.StorageExchangeRates,
+	recorder *audit.Recorder,
+	approval *approval.Service,
+) *Controller {
 	return &Controller{
 		commonController:     commonController,
-		storageExchangeRates: exchangerates.New(config.PathToDB),
-		storageCurrencies:    currencies.New(config.PathToDB),
+		storageExchangeRates: storageExchangeRates,
+		storageCurrencies:    storageCurrencies,
+		recorder:             recorder,
+		approval:             approval,
 	}
 }
 
 func (ce *Controller) ExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		ce.exchangeRatesGetHandler(w)
+		ce.exchangeRatesGetHandler(w, r)
 
 		return
 	}
@@ -43,7 +56,7 @@ func (ce *Controller) ExchangeRatesHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	ce.commonController.ShowMethodNotAllowedError(w)
+	ce.commonController.ShowMethodNotAllowedError(w, r)
 }
 
 func (ce *Controller) ExchangeRatesPairHandler(w http.ResponseWriter, r *http.Request) {
@@ -65,79 +78,138 @@ func (ce *Controller) ExchangeRatesPairHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	ce.commonController.ShowMethodNotAllowedError(w)
+	ce.commonController.ShowMethodNotAllowedError(w, r)
 }
 
-func (ce *Controller) exchangeRatesGetHandler(w http.ResponseWriter) {
-	ce.commonController.ShowResponse(w, http.StatusOK, ce.storageExchangeRates.All())
+func (ce *Controller) exchangeRatesGetHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "exchangeRatesGetHandler"
+
+	validated := validation.NewExchangeRatesList(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		ce.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := exchangerates.Filter{
+		Base:        validated.Field("base"),
+		Target:      validated.Field("target"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := ce.storageExchangeRates.List(r.Context(), filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	ce.commonController.ShowResponse(w, r, http.StatusOK, items)
 }
 
 func (ce *Controller) exchangeRatesAddHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "exchangeRatesAddHandler"
 
-	validated := validation.NewExchangeRates(
-		r,
-		map[string]string{"baseCurrencyCode": "", "targetCurrencyCode": "", "rate": ""},
-	)
+	validated := validation.NewExchangeRates(r)
 	validated.Validate()
 
 	if !validated.IsValid() {
-		ce.commonController.ShowError(w, http.StatusBadRequest, validated.ErrorMessage())
+		ce.commonController.ShowValidationError(w, r, validated)
 
 		return
 	}
 
-	baseCurrency, err := ce.storageCurrencies.ByCode(validated.Field("baseCurrencyCode"))
+	baseCurrency, err := ce.storageCurrencies.ByCode(r.Context(), validated.Field("baseCurrencyCode"))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	targetCurrency, err := ce.storageCurrencies.ByCode(validated.Field("targetCurrencyCode"))
+	targetCurrency, err := ce.storageCurrencies.ByCode(r.Context(), validated.Field("targetCurrencyCode"))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
 	exchangeRates := entity.ExchangeRates{
 		BaseCurrency:   baseCurrency,
 		TargetCurrency: targetCurrency,
-		Rate:           validated.Rate(),
+		Rate:           validated.Float("rate"),
+		UpdatedAt:      time.Now().UTC(),
 	}
 
-	id, err := ce.storageExchangeRates.Add(exchangeRates)
+	if ce.approval.Required(baseCurrency.Code, targetCurrency.Code) {
+		_, err = ce.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(r.Context(), baseCurrency.ID, targetCurrency.ID)
+		if err == nil {
+			ce.commonController.ShowError(w, r, http.StatusConflict, controller.CodeExchangeRatesAlreadyExists)
+
+			return
+		}
+
+		if !errors.Is(err, storage.EntitiesNotFoundError) {
+			logging.ErrorContext(r.Context(), f, op, err)
+			ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+			return
+		}
+
+		ce.propose(w, r, exchangeRates, exchangeRates.Rate)
+
+		return
+	}
+
+	id, err := ce.storageExchangeRates.Add(r.Context(), exchangeRates)
 	if err != nil {
 		if errors.Is(err, storage.EntityAlreadyExistsError) {
-			ce.commonController.ShowError(w, http.StatusConflict, controller.MessageExchangeRatesAlreadyExists)
+			ce.commonController.ShowError(w, r, http.StatusConflict, controller.CodeExchangeRatesAlreadyExists)
 
 			return
 		}
 
-		util.LogError(f, op, err)
-		ce.commonController.ShowError(w, http.StatusInternalServerError, controller.MessageServerError)
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
 
 		return
 	}
 
 	exchangeRates.ID = id
+	exchangeRates.Version = 1
+
+	err = ce.recorder.RateCreated(r.Context(), exchangeRates)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+	}
 
-	ce.commonController.ShowResponse(w, http.StatusCreated, exchangeRates)
+	w.Header().Set("ETag", ce.commonController.ExchangeRateETag(r, exchangeRates))
+	ce.commonController.ShowResponse(w, r, http.StatusCreated, exchangeRates)
 }
 
 func (ce *Controller) exchangeRatesPairGetHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "exchangeRatesPairGetHandler"
+
 	pair := r.PathValue("pair")
 	if pair == "" {
-		ce.commonController.ShowError(w, http.StatusBadRequest, controller.MessageExchangeRatesPairEmpty)
+		ce.commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeExchangeRatesPairEmpty)
 
 		return
 	}
@@ -145,53 +217,65 @@ func (ce *Controller) exchangeRatesPairGetHandler(w http.ResponseWriter, r *http
 	currenciesCodes := strings.Split(strings.ToUpper(pair), "")
 
 	if len(currenciesCodes) != 6 {
-		ce.commonController.ShowError(w, http.StatusBadRequest, controller.MessageExchangeRatesCurrencyNotFound)
+		ce.commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeExchangeRatesCurrencyNotFound)
 
 		return
 	}
 
-	baseCurrency, err := ce.storageCurrencies.ByCode(strings.Join(currenciesCodes[0:3], ""))
+	baseCurrency, err := ce.storageCurrencies.ByCode(r.Context(), strings.Join(currenciesCodes[0:3], ""))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesPairCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesPairCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	targetCurrency, err := ce.storageCurrencies.ByCode(strings.Join(currenciesCodes[3:], ""))
+	targetCurrency, err := ce.storageCurrencies.ByCode(r.Context(), strings.Join(currenciesCodes[3:], ""))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesPairCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesPairCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	exchangeRate, err := ce.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(baseCurrency.ID, targetCurrency.ID)
+	exchangeRate, err := ce.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(r.Context(), baseCurrency.ID, targetCurrency.ID)
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesPairNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesPairNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	ce.commonController.ShowResponse(w, http.StatusOK, exchangeRate)
+	w.Header().Set("ETag", ce.commonController.ExchangeRateETag(r, exchangeRate))
+	ce.commonController.ShowResponse(w, r, http.StatusOK, exchangeRate)
 }
 
+// exchangeRatesPairUpdateHandler updates the rate if the If-Match header matches its ETag, so concurrent updates
+// do not overwrite each other. The header is required by v2, v1 checks it only if it is sent.
 func (ce *Controller) exchangeRatesPairUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "exchangeRatesPairUpdateHandler"
 
 	pair := r.PathValue("pair")
 	if pair == "" {
-		ce.commonController.ShowError(w, http.StatusBadRequest, controller.MessageExchangeRatesPairEmpty)
+		ce.commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeExchangeRatesPairEmpty)
 
 		return
 	}
@@ -199,62 +283,122 @@ func (ce *Controller) exchangeRatesPairUpdateHandler(w http.ResponseWriter, r *h
 	currenciesCodes := strings.Split(strings.ToUpper(pair), "")
 
 	if len(currenciesCodes) != 6 {
-		ce.commonController.ShowError(w, http.StatusBadRequest, controller.MessageExchangeRatesCurrencyNotFound)
+		ce.commonController.ShowError(w, r, http.StatusBadRequest, controller.CodeExchangeRatesCurrencyNotFound)
 
 		return
 	}
 
-	baseCurrency, err := ce.storageCurrencies.ByCode(strings.Join(currenciesCodes[0:3], ""))
+	baseCurrency, err := ce.storageCurrencies.ByCode(r.Context(), strings.Join(currenciesCodes[0:3], ""))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	targetCurrency, err := ce.storageCurrencies.ByCode(strings.Join(currenciesCodes[3:], ""))
+	targetCurrency, err := ce.storageCurrencies.ByCode(r.Context(), strings.Join(currenciesCodes[3:], ""))
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesCurrencyNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesCurrencyNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
 		return
 	}
 
-	validated := validation.NewExchangeRates(r, map[string]string{"rate": ""})
+	validated := validation.NewExchangeRatesUpdate(r)
 	validated.Validate()
 
 	if !validated.IsValid() {
-		ce.commonController.ShowError(w, http.StatusBadRequest, validated.ErrorMessage())
+		ce.commonController.ShowValidationError(w, r, validated)
 
 		return
 	}
 
-	exchangeRate, err := ce.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(baseCurrency.ID, targetCurrency.ID)
+	exchangeRate, err := ce.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(r.Context(), baseCurrency.ID, targetCurrency.ID)
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
-			ce.commonController.ShowError(w, http.StatusNotFound, controller.MessageExchangeRatesPairNotFound)
+			ce.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeExchangeRatesPairNotFound)
 
 			return
 		}
 
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && ce.commonController.Version() >= controller.V2 {
+		ce.commonController.ShowError(w, r, http.StatusPreconditionRequired, controller.CodePreconditionRequired)
+
+		return
+	}
+
+	if ifMatch != "" && !controller.IfMatch(ifMatch, ce.commonController.ExchangeRateETag(r, exchangeRate)) {
+		ce.commonController.ShowError(w, r, http.StatusPreconditionFailed, controller.CodeExchangeRatesModified)
+
+		return
+	}
+
+	if ce.approval.Required(baseCurrency.Code, targetCurrency.Code) {
+		ce.propose(w, r, exchangeRate, validated.Float("rate"))
+
 		return
 	}
 
-	exchangeRate.Rate = validated.Rate()
+	oldRate := exchangeRate.Rate
+	exchangeRate.Rate = validated.Float("rate")
+	exchangeRate.UpdatedAt = time.Now().UTC()
+
+	err = ce.storageExchangeRates.UpdateRate(r.Context(), exchangeRate)
+	if err != nil {
+		if errors.Is(err, storage.EntityChangedError) {
+			ce.commonController.ShowError(w, r, http.StatusPreconditionFailed, controller.CodeExchangeRatesModified)
+
+			return
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	exchangeRate.Version++
+
+	err = ce.recorder.RateUpdated(r.Context(), exchangeRate, oldRate)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+	}
+
+	w.Header().Set("ETag", ce.commonController.ExchangeRateETag(r, exchangeRate))
+	ce.commonController.ShowResponse(w, r, http.StatusOK, exchangeRate)
+}
+
+// propose stores the change of the rate as a proposal awaiting approval and responds with it.
+func (ce *Controller) propose(w http.ResponseWriter, r *http.Request, exchangeRate entity.ExchangeRates, rate float64) {
+	const op = "propose"
 
-	err = ce.storageExchangeRates.UpdateRate(exchangeRate)
+	proposal, err := ce.approval.Propose(r.Context(), exchangeRate, rate)
 	if err != nil {
-		util.LogError(f, op, err)
-		ce.commonController.ShowError(w, http.StatusInternalServerError, controller.MessageServerError)
+		logging.ErrorContext(r.Context(), f, op, err)
+		ce.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
 
 		return
 	}
 
-	ce.commonController.ShowResponse(w, http.StatusOK, exchangeRate)
+	w.Header().Set("Location", "/proposals/"+strconv.FormatInt(proposal.ID, 10))
+	ce.commonController.ShowResponse(w, r, http.StatusAccepted, proposal)
 }
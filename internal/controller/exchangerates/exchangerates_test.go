@@ -0,0 +1,204 @@
+package exchangerates
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixture serves the rates of both versions of a database with the USD/EUR rate.
+type fixture struct {
+	mux *http.ServeMux
+}
+
+func setup(t *testing.T) fixture {
+	t.Helper()
+
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currencies := storageCurrencies.New(c.PathToDB, nil, nil)
+	exchangeRates := storageExchangeRates.New(c.PathToDB, nil, nil)
+	recorder := audit.New(rateaudit.New(c.PathToDB))
+	approvals := approval.New(c, rateproposals.New(c.PathToDB), currencies, exchangeRates, recorder)
+
+	stored := map[string]entity.Currency{}
+	for _, code := range []string{"USD", "EUR"} {
+		currency := entity.Currency{Code: code, FullName: code, Sign: code}
+
+		currency.ID, err = currencies.Add(context.Background(), currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored[code] = currency
+	}
+
+	_, err = exchangeRates.Add(
+		context.Background(),
+		entity.ExchangeRates{BaseCurrency: stored["USD"], TargetCurrency: stored["EUR"], Rate: 0.9},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog := i18n.MustNew("en")
+	mux := http.NewServeMux()
+
+	for prefix, version := range map[string]controller.Version{"v1": controller.V1, "v2": controller.V2} {
+		exchangeRatesController := New(controller.New(version, catalog), currencies, exchangeRates, recorder, approvals)
+		mux.HandleFunc("/"+prefix+"/exchangeRate/{pair}", exchangeRatesController.ExchangeRatesPairHandler)
+	}
+
+	return fixture{mux: mux}
+}
+
+func (fx fixture) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	fx.mux.ServeHTTP(w, r)
+
+	return w
+}
+
+// etag reads the pair in the version and returns its ETag.
+func (fx fixture) etag(t *testing.T, version string) string {
+	t.Helper()
+
+	w := fx.serve(httptest.NewRequest(http.MethodGet, "/"+version+"/exchangeRate/USDEUR", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	return w.Header().Get("ETag")
+}
+
+func patch(version, ifMatch string) *http.Request {
+	form := url.Values{"rate": {"0.91"}}
+
+	r := httptest.NewRequest(http.MethodPatch, "/"+version+"/exchangeRate/USDEUR", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+
+	return r
+}
+
+func TestPairETag(t *testing.T) {
+	fx := setup(t)
+
+	v1, v2 := fx.etag(t, "v1"), fx.etag(t, "v2")
+
+	if v1 == "" || strings.HasPrefix(v1, "W/") || v1 == v2 {
+		t.Fatalf("tags %q and %q, want strong tags differing between the versions", v1, v2)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/exchangeRate/USDEUR", nil)
+	r.Header.Set("Accept-Language", "ru")
+
+	if w := fx.serve(r); w.Header().Get("ETag") == v1 {
+		t.Errorf("tag %q of another language, want it to differ", w.Header().Get("ETag"))
+	}
+
+	tests := []struct {
+		name        string
+		version     string
+		ifNoneMatch string
+		want        int
+	}{
+		{name: "v1 current tag", version: "v1", ifNoneMatch: v1, want: http.StatusNotModified},
+		{name: "v1 weak current tag", version: "v1", ifNoneMatch: "W/" + v1, want: http.StatusNotModified},
+		{name: "v1 tag of v2", version: "v1", ifNoneMatch: v2, want: http.StatusOK},
+		{name: "v2 current tag in a list", version: "v2", ifNoneMatch: `"other", ` + v2, want: http.StatusNotModified},
+		{name: "v2 any tag", version: "v2", ifNoneMatch: "*", want: http.StatusNotModified},
+		{name: "v2 stale tag", version: "v2", ifNoneMatch: `"stale"`, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.version+"/exchangeRate/USDEUR", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			w := fx.serve(r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+
+			if tt.want == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("body %q, want none", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPairUpdatePreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		// ifMatch returns the If-Match header of the update from the tags of the versions
+		ifMatch func(v1, v2 string) string
+		want    int
+	}{
+		{name: "v1 without If-Match", version: "v1", ifMatch: func(v1, v2 string) string { return "" }, want: http.StatusOK},
+		{name: "v1 current tag", version: "v1", ifMatch: func(v1, v2 string) string { return v1 }, want: http.StatusOK},
+		{name: "v1 any tag", version: "v1", ifMatch: func(v1, v2 string) string { return "*" }, want: http.StatusOK},
+		{name: "v1 stale tag", version: "v1", ifMatch: func(v1, v2 string) string { return `"stale"` }, want: http.StatusPreconditionFailed},
+		{name: "v1 tag of v2", version: "v1", ifMatch: func(v1, v2 string) string { return v2 }, want: http.StatusPreconditionFailed},
+		{name: "v1 weak tag", version: "v1", ifMatch: func(v1, v2 string) string { return "W/" + v1 }, want: http.StatusPreconditionFailed},
+		{name: "v2 without If-Match", version: "v2", ifMatch: func(v1, v2 string) string { return "" }, want: http.StatusPreconditionRequired},
+		{name: "v2 current tag", version: "v2", ifMatch: func(v1, v2 string) string { return v2 }, want: http.StatusOK},
+		{name: "v2 current tag in a list", version: "v2", ifMatch: func(v1, v2 string) string { return `"other", ` + v2 }, want: http.StatusOK},
+		{name: "v2 stale tag", version: "v2", ifMatch: func(v1, v2 string) string { return `"stale"` }, want: http.StatusPreconditionFailed},
+		{name: "v2 tag of v1", version: "v2", ifMatch: func(v1, v2 string) string { return v1 }, want: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := setup(t)
+
+			etag := fx.etag(t, tt.version)
+
+			w := fx.serve(patch(tt.version, tt.ifMatch(fx.etag(t, "v1"), fx.etag(t, "v2"))))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+
+			if tt.want != http.StatusOK {
+				if fx.etag(t, tt.version) != etag {
+					t.Error("rate updated by a failed precondition")
+				}
+
+				return
+			}
+
+			// the response carries the tag of the new version, the old one no longer matches
+			if w.Header().Get("ETag") == etag || w.Header().Get("ETag") != fx.etag(t, tt.version) {
+				t.Errorf("tag %q, want the one of the updated rate", w.Header().Get("ETag"))
+			}
+
+			if w = fx.serve(patch(tt.version, etag)); w.Code != http.StatusPreconditionFailed {
+				t.Errorf("status %d with the old tag, want %d", w.Code, http.StatusPreconditionFailed)
+			}
+		})
+	}
+}
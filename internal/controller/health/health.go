@@ -0,0 +1,108 @@
+package health
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/buildinfo"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/health"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"net/http"
+)
+
+const f = "health.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	checker          *health.Checker
+}
+
+func New(commonController controller.ServerResponse, checker *health.Checker) *Controller {
+	return &Controller{
+		commonController: commonController,
+		checker:          checker,
+	}
+}
+
+type status struct {
+	Status string `json:"status"`
+}
+
+type version struct {
+	buildinfo.Info
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+type maintenance struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// HealthzHandler answers as long as the process is alive.
+func (ch *Controller) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		ch.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	ch.commonController.ShowResponse(w, r, http.StatusOK, status{Status: health.StatusOK})
+}
+
+// ReadyzHandler shows the readiness checks, with 503 if the service should not receive traffic.
+func (ch *Controller) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		ch.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	report := ch.checker.Ready(r.Context())
+	if !report.Ready {
+		ch.commonController.ShowResponse(w, r, http.StatusServiceUnavailable, report)
+
+		return
+	}
+
+	ch.commonController.ShowResponse(w, r, http.StatusOK, report)
+}
+
+// VersionHandler shows the build of the binary and the version of the schema of the database.
+func (ch *Controller) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "VersionHandler"
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		ch.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	schemaVersion, err := ch.checker.SchemaVersion(r.Context())
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		ch.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	ch.commonController.ShowResponse(w, r, http.StatusOK, version{
+		Info:          buildinfo.Get(),
+		SchemaVersion: schemaVersion,
+	})
+}
+
+// MaintenanceHandler shows the maintenance mode, PUT switches it on and DELETE off.
+func (ch *Controller) MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		ch.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		ch.checker.SetMaintenance(true)
+	}
+
+	if r.Method == http.MethodDelete {
+		ch.checker.SetMaintenance(false)
+	}
+
+	ch.commonController.ShowResponse(w, r, http.StatusOK, maintenance{Maintenance: ch.checker.Maintenance()})
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"encoding/json"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/health"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestHandlers(t *testing.T) {
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := New(controller.New(controller.V2, i18n.MustNew("en")), health.New(c, exchangerates.New(c.PathToDB, nil, nil)))
+
+	// the cases run in order, the maintenance mode switched on by one is seen by the next ones
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		status  int
+		// field of the body and its value, encoded as JSON
+		field string
+		value string
+	}{
+		{name: "alive", handler: ch.HealthzHandler, method: http.MethodGet, status: http.StatusOK, field: "status", value: `"ok"`},
+		{name: "ready", handler: ch.ReadyzHandler, method: http.MethodGet, status: http.StatusOK, field: "ready", value: "true"},
+		{name: "version", handler: ch.VersionHandler, method: http.MethodGet, status: http.StatusOK, field: "schemaVersion", value: strconv.Itoa(dbinit.SchemaVersion())},
+		{name: "maintenance off", handler: ch.MaintenanceHandler, method: http.MethodGet, status: http.StatusOK, field: "maintenance", value: "false"},
+		{name: "maintenance on", handler: ch.MaintenanceHandler, method: http.MethodPut, status: http.StatusOK, field: "maintenance", value: "true"},
+		{name: "not ready", handler: ch.ReadyzHandler, method: http.MethodGet, status: http.StatusServiceUnavailable, field: "ready", value: "false"},
+		{name: "alive in maintenance", handler: ch.HealthzHandler, method: http.MethodGet, status: http.StatusOK, field: "status", value: `"ok"`},
+		{name: "maintenance switched off", handler: ch.MaintenanceHandler, method: http.MethodDelete, status: http.StatusOK, field: "maintenance", value: "false"},
+		{name: "ready again", handler: ch.ReadyzHandler, method: http.MethodGet, status: http.StatusOK, field: "ready", value: "true"},
+		{name: "probe written to", handler: ch.ReadyzHandler, method: http.MethodPost, status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(tt.method, "/", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+
+			if tt.field == "" {
+				return
+			}
+
+			body := map[string]json.RawMessage{}
+
+			err := json.Unmarshal(w.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, ok := body[tt.field]
+			if !ok || (tt.value != "" && string(value) != tt.value) {
+				t.Errorf("%s %s, want %s", tt.field, value, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+)
+
+// localizeEntities translates the currency names in msg if the client asked for a language.
+// Without an explicit language the names are shown as stored.
+func localizeEntities(localizer *i18n.Localizer, msg interface{}) interface{} {
+	if !localizer.Requested() {
+		return msg
+	}
+
+	switch v := msg.(type) {
+	case entity.Currency:
+		return localizeCurrency(localizer, v)
+	case []entity.Currency:
+		currencies := make([]entity.Currency, len(v))
+		for i, currency := range v {
+			currencies[i] = localizeCurrency(localizer, currency)
+		}
+
+		return currencies
+	case entity.ExchangeRates:
+		return localizeExchangeRates(localizer, v)
+	case []entity.ExchangeRates:
+		exchangeRates := make([]entity.ExchangeRates, len(v))
+		for i, exchangeRate := range v {
+			exchangeRates[i] = localizeExchangeRates(localizer, exchangeRate)
+		}
+
+		return exchangeRates
+	case entity.Exchange:
+		v.BaseCurrency = localizeCurrency(localizer, v.BaseCurrency)
+		v.TargetCurrency = localizeCurrency(localizer, v.TargetCurrency)
+
+		return v
+	}
+
+	return msg
+}
+
+func localizeCurrency(localizer *i18n.Localizer, currency entity.Currency) entity.Currency {
+	currency.FullName = localizer.CurrencyName(currency.Code, currency.FullName)
+
+	return currency
+}
+
+func localizeExchangeRates(localizer *i18n.Localizer, exchangeRates entity.ExchangeRates) entity.ExchangeRates {
+	exchangeRates.BaseCurrency = localizeCurrency(localizer, exchangeRates.BaseCurrency)
+	exchangeRates.TargetCurrency = localizeCurrency(localizer, exchangeRates.TargetCurrency)
+
+	return exchangeRates
+}
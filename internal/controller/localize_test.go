@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShowResponseLocalizesCurrencyNames(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		language       string
+		fullName       string
+	}{
+		{name: "stored name without a language", language: "en", fullName: "United States dollar"},
+		{name: "translated name", acceptLanguage: "ru", language: "ru", fullName: "Доллар США"},
+		{name: "name of the fallback language asked for", acceptLanguage: "en", language: "en", fullName: "US Dollar"},
+	}
+
+	c := New(V2, i18n.MustNew("en"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v2/currency/USD", nil)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+
+			c.ShowResponse(w, r, http.StatusOK, entity.Currency{ID: 1, Code: "USD", FullName: "United States dollar", Sign: "$"})
+
+			if w.Header().Get("Content-Language") != tt.language || w.Header().Get("Vary") != "Accept-Language" {
+				t.Errorf(
+					"Content-Language %q and Vary %q, want %q and Accept-Language",
+					w.Header().Get("Content-Language"), w.Header().Get("Vary"), tt.language,
+				)
+			}
+
+			var currency struct {
+				FullName string `json:"fullName"`
+			}
+
+			err := json.Unmarshal(w.Body.Bytes(), &currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if currency.FullName != tt.fullName {
+				t.Errorf("full name %q, want %q", currency.FullName, tt.fullName)
+			}
+		})
+	}
+}
@@ -1,16 +1,13 @@
 package controller
 
+// Message keys of the validation errors, the texts are kept in the i18n catalog.
+// Errors of other kinds use their code as the message key.
 const (
-	MessageServerError                       = "Ошибка на сервере"
-	MessageMethodNotAllowed                  = "Метод не доступен"
-	MessageFieldEmpty                        = "Отсутствует нужное поле: %s"
-	MessageFieldIncorrectError               = "Некорректно указано поле %s"
-	MessageCurrencyCodeEmpty                 = "Код валюты отсутствует в адресе"
-	MessageCurrencyAlreadyExists             = "Валюта с таким кодом уже существует"
-	MessageCurrencyNotFound                  = "Валюта не найдена"
-	MessageExchangeRatesAlreadyExists        = "Валютная пара с таким кодом уже существует"
-	MessageExchangeRatesCurrencyNotFound     = "Одна (или обе) валюты из валютной пары не существует в БД"
-	MessageExchangeRatesPairEmpty            = "Коды валют пары отсутствуют в адресе"
-	MessageExchangeRatesPairCurrencyNotFound = "Обменный курс для пары не найден"
-	MessageExchangeRatesPairNotFound         = "Валютная пара не найдена"
+	MessageFieldEmpty          = "field-empty"
+	MessageFieldIncorrectError = "field-incorrect"
+	MessageFieldCurrencyCode   = "field-currency-code"
+	MessageFieldLength         = "field-length"
+	MessageFieldRange          = "field-range"
+	MessageFieldEnum           = "field-enum"
+	MessageFieldUnknown        = "field-unknown"
 )
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"strconv"
+	"time"
+)
+
+// exchangeRatesV1 is the rate as rendered before versioning.
+type exchangeRatesV1 struct {
+	ID             int64           `json:"id"`
+	BaseCurrency   entity.Currency `json:"baseCurrency"`
+	TargetCurrency entity.Currency `json:"targetCurrency"`
+	Rate           float64         `json:"rate"`
+}
+
+type currencyV2 struct {
+	ID       int64  `json:"id"`
+	Code     string `json:"code"`
+	FullName string `json:"fullName"`
+	Sign     string `json:"sign"`
+}
+
+type exchangeRatesV2 struct {
+	ID             int64      `json:"id"`
+	BaseCurrency   currencyV2 `json:"baseCurrency"`
+	TargetCurrency currencyV2 `json:"targetCurrency"`
+	Rate           string     `json:"rate"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+type exchangeV2 struct {
+	BaseCurrency    currencyV2 `json:"baseCurrency"`
+	TargetCurrency  currencyV2 `json:"targetCurrency"`
+	Rate            string     `json:"rate"`
+	Amount          string     `json:"amount"`
+	ConvertedAmount string     `json:"convertedAmount"`
+}
+
+// Present converts the entities in msg into the version contract without translating them,
+// for messages sent outside of a request, e.g. webhooks.
+func Present(version Version, msg interface{}) interface{} {
+	if version == V2 {
+		return presentV2(msg)
+	}
+
+	return presentV1(msg)
+}
+
+// presentV1 converts the rates in msg into the V1 contract, the currencies and conversions are rendered as they are.
+func presentV1(msg interface{}) interface{} {
+	switch v := msg.(type) {
+	case entity.ExchangeRates:
+		return exchangeRatesToV1(v)
+	case []entity.ExchangeRates:
+		exchangeRates := make([]exchangeRatesV1, len(v))
+		for i, exchangeRate := range v {
+			exchangeRates[i] = exchangeRatesToV1(exchangeRate)
+		}
+
+		return exchangeRates
+	}
+
+	return msg
+}
+
+// presentV2 converts the entities in msg into the V2 contract, other values are left as they are.
+func presentV2(msg interface{}) interface{} {
+	switch v := msg.(type) {
+	case entity.Currency:
+		return currencyToV2(v)
+	case []entity.Currency:
+		currencies := make([]currencyV2, len(v))
+		for i, currency := range v {
+			currencies[i] = currencyToV2(currency)
+		}
+
+		return currencies
+	case entity.ExchangeRates:
+		return exchangeRatesToV2(v)
+	case []entity.ExchangeRates:
+		exchangeRates := make([]exchangeRatesV2, len(v))
+		for i, exchangeRate := range v {
+			exchangeRates[i] = exchangeRatesToV2(exchangeRate)
+		}
+
+		return exchangeRates
+	case entity.Exchange:
+		return exchangeV2{
+			BaseCurrency:    currencyToV2(v.BaseCurrency),
+			TargetCurrency:  currencyToV2(v.TargetCurrency),
+			Rate:            Decimal(v.Rate),
+			Amount:          Decimal(v.Amount),
+			ConvertedAmount: Decimal(v.ConvertedAmount),
+		}
+	}
+
+	return msg
+}
+
+func currencyToV2(currency entity.Currency) currencyV2 {
+	return currencyV2{
+		ID:       currency.ID,
+		Code:     currency.Code,
+		FullName: currency.FullName,
+		Sign:     currency.Sign,
+	}
+}
+
+func exchangeRatesToV1(exchangeRates entity.ExchangeRates) exchangeRatesV1 {
+	return exchangeRatesV1{
+		ID:             exchangeRates.ID,
+		BaseCurrency:   exchangeRates.BaseCurrency,
+		TargetCurrency: exchangeRates.TargetCurrency,
+		Rate:           exchangeRates.Rate,
+	}
+}
+
+func exchangeRatesToV2(exchangeRates entity.ExchangeRates) exchangeRatesV2 {
+	return exchangeRatesV2{
+		ID:             exchangeRates.ID,
+		BaseCurrency:   currencyToV2(exchangeRates.BaseCurrency),
+		TargetCurrency: currencyToV2(exchangeRates.TargetCurrency),
+		Rate:           Decimal(exchangeRates.Rate),
+		UpdatedAt:      exchangeRates.UpdatedAt,
+	}
+}
+
+// Decimal formats the number without exponent and with as few digits as needed, as decimals are rendered by V2.
+func Decimal(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
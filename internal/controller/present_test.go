@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestPresent(t *testing.T) {
+	usd := entity.Currency{ID: 1, Code: "USD", FullName: "US Dollar", Sign: "$"}
+	eur := entity.Currency{ID: 2, Code: "EUR", FullName: "Euro", Sign: "€"}
+	rate := entity.ExchangeRates{
+		ID:             3,
+		BaseCurrency:   usd,
+		TargetCurrency: eur,
+		Rate:           0.0000001,
+		UpdatedAt:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Version:        7,
+	}
+
+	tests := []struct {
+		name    string
+		version Version
+		msg     interface{}
+		want    string
+	}{
+		{name: "currency of v1", version: V1, msg: usd, want: `{"id":1,"code":"USD","name":"US Dollar","sign":"$"}`},
+		{name: "currency of v2", version: V2, msg: usd, want: `{"id":1,"code":"USD","fullName":"US Dollar","sign":"$"}`},
+		{
+			name:    "rate of v1",
+			version: V1,
+			msg:     []entity.ExchangeRates{rate},
+			want: `[{"id":3,"baseCurrency":{"id":1,"code":"USD","name":"US Dollar","sign":"$"},` +
+				`"targetCurrency":{"id":2,"code":"EUR","name":"Euro","sign":"€"},"rate":1e-7}]`,
+		},
+		{
+			name:    "rate of v2",
+			version: V2,
+			msg:     []entity.ExchangeRates{rate},
+			want: `[{"id":3,"baseCurrency":{"id":1,"code":"USD","fullName":"US Dollar","sign":"$"},` +
+				`"targetCurrency":{"id":2,"code":"EUR","fullName":"Euro","sign":"€"},"rate":"0.0000001",` +
+				`"updatedAt":"2026-01-02T03:04:05Z"}]`,
+		},
+		{
+			name:    "exchange of v2",
+			version: V2,
+			msg:     entity.Exchange{BaseCurrency: usd, TargetCurrency: eur, Rate: 0.9, Amount: 10, ConvertedAmount: 9},
+			want: `{"baseCurrency":{"id":1,"code":"USD","fullName":"US Dollar","sign":"$"},` +
+				`"targetCurrency":{"id":2,"code":"EUR","fullName":"Euro","sign":"€"},"rate":"0.9","amount":"10","convertedAmount":"9"}`,
+		},
+		{name: "other values", version: V2, msg: map[string]int{"count": 1}, want: `{"count":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(Present(tt.version, tt.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("%s, want %s", got, tt.want)
+			}
+		})
+	}
+}
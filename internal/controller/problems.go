@@ -0,0 +1,51 @@
+package controller
+
+// Error codes are stable identifiers of error kinds, clients may rely on them instead of messages.
+const (
+	CodeServerError                       = "server-error"
+	CodeMethodNotAllowed                  = "method-not-allowed"
+	CodeValidationFailed                  = "validation-failed"
+	CodeBodyInvalid                       = "body-invalid"
+	CodeBodyTooLarge                      = "body-too-large"
+	CodeCurrencyCodeEmpty                 = "currency-code-missing"
+	CodeCurrencyAlreadyExists             = "currency-already-exists"
+	CodeCurrencyNotFound                  = "currency-not-found"
+	CodeExchangeRatesAlreadyExists        = "exchange-rate-already-exists"
+	CodeExchangeRatesCurrencyNotFound     = "exchange-rate-currency-not-found"
+	CodeExchangeRatesPairEmpty            = "exchange-rate-pair-missing"
+	CodeExchangeRatesPairCurrencyNotFound = "exchange-rate-pair-currency-not-found"
+	CodeExchangeRatesPairNotFound         = "exchange-rate-not-found"
+	CodeWebhookNotFound                   = "webhook-not-found"
+	CodeWebhookDeliveryNotFound           = "webhook-delivery-not-found"
+	CodeAPIKeyMissing                     = "api-key-missing"
+	CodeAPIKeyInvalid                     = "api-key-invalid"
+	CodeAPIKeyForbidden                   = "api-key-forbidden"
+	CodeAPIKeyNotFound                    = "api-key-not-found"
+	CodeProposalNotFound                  = "proposal-not-found"
+	CodeProposalNotPending                = "proposal-not-pending"
+	CodeProposalSelfDecision              = "proposal-self-decision"
+	CodeRateApprovalRequired              = "rate-approval-required"
+	CodeRateLimitExceeded                 = "rate-limit-exceeded"
+	CodeQuotaExceeded                     = "quota-exceeded"
+	CodeIdempotencyKeyInvalid             = "idempotency-key-invalid"
+	CodeIdempotencyKeyReused              = "idempotency-key-reused"
+	CodeIdempotencyKeyInProgress          = "idempotency-key-in-progress"
+	CodePreconditionRequired              = "precondition-required"
+	CodeExchangeRatesModified             = "exchange-rate-modified"
+	CodeClientCertificateRequired         = "client-certificate-required"
+)
+
+// problemTypePrefix prefixes the error code in the type of problem details.
+const problemTypePrefix = "urn:currency-exchange:problem:"
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type      string            `json:"type"`
+	Code      string            `json:"code"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail"`
+	Instance  string            `json:"instance,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+}
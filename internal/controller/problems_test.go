@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/requestid"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// validationError is a failed validation of the code and rate fields.
+type validationError struct{}
+
+func (validationError) StatusCode() int { return http.StatusBadRequest }
+
+func (validationError) Code() string { return CodeValidationFailed }
+
+func (validationError) ErrorMessage() i18n.Message {
+	return i18n.NewMessage(MessageFieldEmpty, "code")
+}
+
+func (validationError) Fields() map[string]i18n.Message {
+	return map[string]i18n.Message{
+		"code": i18n.NewMessage(MessageFieldEmpty, "code"),
+		"rate": i18n.NewMessage(MessageFieldIncorrectError, "rate"),
+	}
+}
+
+func TestShowError(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     Version
+		show        func(c *Controller, w http.ResponseWriter, r *http.Request)
+		status      int
+		contentType string
+		want        map[string]interface{}
+	}{
+		{
+			name:    "problem",
+			version: V2,
+			show: func(c *Controller, w http.ResponseWriter, r *http.Request) {
+				c.ShowError(w, r, http.StatusNotFound, CodeCurrencyNotFound)
+			},
+			status:      http.StatusNotFound,
+			contentType: "application/problem+json",
+			want: map[string]interface{}{
+				"type":      "urn:currency-exchange:problem:currency-not-found",
+				"code":      "currency-not-found",
+				"title":     "Currency not found",
+				"status":    float64(http.StatusNotFound),
+				"detail":    "Currency not found",
+				"instance":  "/v2/currency/XXX",
+				"requestId": "r-1",
+			},
+		},
+		{
+			name:    "problem of invalid fields",
+			version: V2,
+			show: func(c *Controller, w http.ResponseWriter, r *http.Request) {
+				c.ShowValidationError(w, r, validationError{})
+			},
+			status:      http.StatusBadRequest,
+			contentType: "application/problem+json",
+			want: map[string]interface{}{
+				"type":     "urn:currency-exchange:problem:validation-failed",
+				"code":     "validation-failed",
+				"title":    "Validation failed",
+				"status":   float64(http.StatusBadRequest),
+				"detail":   "Required field is missing: code",
+				"instance": "/v2/currency/XXX",
+				"errors": map[string]interface{}{
+					"code": "Required field is missing: code",
+					"rate": "Field rate is incorrect",
+				},
+				"requestId": "r-1",
+			},
+		},
+		{
+			name:    "message of v1",
+			version: V1,
+			show: func(c *Controller, w http.ResponseWriter, r *http.Request) {
+				c.ShowError(w, r, http.StatusNotFound, CodeCurrencyNotFound)
+			},
+			status:      http.StatusNotFound,
+			contentType: "application/json",
+			want:        map[string]interface{}{"message": "Currency not found"},
+		},
+		{
+			name:    "first invalid field in v1",
+			version: V1,
+			show: func(c *Controller, w http.ResponseWriter, r *http.Request) {
+				c.ShowValidationError(w, r, validationError{})
+			},
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			want:        map[string]interface{}{"message": "Required field is missing: code"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v2/currency/XXX", nil)
+			r = r.WithContext(requestid.NewContext(r.Context(), "r-1"))
+			w := httptest.NewRecorder()
+
+			tt.show(New(tt.version, i18n.MustNew("en")), w, r)
+
+			if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("status %d of %q, want %d of %q", w.Code, w.Header().Get("Content-Type"), tt.status, tt.contentType)
+			}
+
+			got := map[string]interface{}{}
+
+			err := json.Unmarshal(w.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package proposals
+
+import (
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"net/http"
+	"strconv"
+)
+
+const f = "proposals.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	approval         *approval.Service
+}
+
+func New(commonController controller.ServerResponse, approval *approval.Service) *Controller {
+	return &Controller{
+		commonController: commonController,
+		approval:         approval,
+	}
+}
+
+// ProposalsHandler shows the rate proposals, the newest first.
+func (cp *Controller) ProposalsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "ProposalsHandler"
+
+	if r.Method != http.MethodGet {
+		cp.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	validated := validation.NewProposals(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cp.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := rateproposals.Filter{
+		Status:      validated.Field("status"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := cp.approval.List(filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cp.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	cp.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
+
+func (cp *Controller) ProposalHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "ProposalHandler"
+
+	if r.Method != http.MethodGet {
+		cp.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	id, ok := cp.id(w, r)
+	if !ok {
+		return
+	}
+
+	proposal, err := cp.approval.ById(id)
+	if err != nil {
+		cp.showError(w, r, op, err)
+
+		return
+	}
+
+	cp.commonController.ShowResponse(w, r, http.StatusOK, proposal)
+}
+
+// ApproveHandler applies the proposal, it must be approved by a user other than its author.
+func (cp *Controller) ApproveHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "ApproveHandler"
+
+	if r.Method != http.MethodPost {
+		cp.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	id, ok := cp.id(w, r)
+	if !ok {
+		return
+	}
+
+	proposal, err := cp.approval.Approve(r.Context(), id)
+	if err != nil {
+		cp.showError(w, r, op, err)
+
+		return
+	}
+
+	cp.commonController.ShowResponse(w, r, http.StatusOK, proposal)
+}
+
+// RejectHandler rejects the proposal with the optional reason of the body.
+func (cp *Controller) RejectHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "RejectHandler"
+
+	if r.Method != http.MethodPost {
+		cp.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	id, ok := cp.id(w, r)
+	if !ok {
+		return
+	}
+
+	validated := validation.NewProposalRejection(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cp.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	proposal, err := cp.approval.Reject(r.Context(), id, validated.Field("reason"))
+	if err != nil {
+		cp.showError(w, r, op, err)
+
+		return
+	}
+
+	cp.commonController.ShowResponse(w, r, http.StatusOK, proposal)
+}
+
+// id returns the proposal id of the path, or responds with the error.
+func (cp *Controller) id(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		cp.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeProposalNotFound)
+
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (cp *Controller) showError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	if errors.Is(err, storage.EntitiesNotFoundError) {
+		cp.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeProposalNotFound)
+
+		return
+	}
+
+	if errors.Is(err, approval.NotPendingError) {
+		cp.commonController.ShowError(w, r, http.StatusConflict, controller.CodeProposalNotPending)
+
+		return
+	}
+
+	if errors.Is(err, approval.SelfDecisionError) {
+		cp.commonController.ShowError(w, r, http.StatusForbidden, controller.CodeProposalSelfDecision)
+
+		return
+	}
+
+	if errors.Is(err, storage.EntityChangedError) {
+		cp.commonController.ShowError(w, r, http.StatusConflict, controller.CodeExchangeRatesModified)
+
+		return
+	}
+
+	if errors.Is(err, storage.EntityAlreadyExistsError) {
+		cp.commonController.ShowError(w, r, http.StatusConflict, controller.CodeExchangeRatesAlreadyExists)
+
+		return
+	}
+
+	logging.ErrorContext(r.Context(), f, op, err)
+	cp.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+}
@@ -0,0 +1,406 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/cors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const f = "stream.Controller"
+
+const (
+	messageSnapshot = "snapshot"
+	messageOverflow = "overflow"
+	messageError    = "error"
+)
+
+const (
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+)
+
+// Controller streams the changes of exchange rates. Every stream starts with the snapshot of the current
+// rates of its pairs, then sends the events published by the storage and a heartbeat when idle.
+// A client falling behind by more than the buffer of events is sent the overflow message and disconnected.
+type Controller struct {
+	commonController     controller.ServerResponse
+	hub                  *events.Hub
+	storageExchangeRates exchangerates.StorageExchangeRates
+	live                 *config.Live
+	upgrader             websocket.Upgrader
+}
+
+// message is sent to the clients of both streams.
+type message struct {
+	Type  string      `json:"type"`
+	ID    uint64      `json:"id,omitempty"`
+	Rates interface{} `json:"rates,omitempty"`
+	Rate  interface{} `json:"rate,omitempty"`
+	Code  string      `json:"code,omitempty"`
+}
+
+// action is sent by WebSocket clients to change the pairs they are subscribed to.
+type action struct {
+	Action string   `json:"action"`
+	Pairs  []string `json:"pairs"`
+}
+
+func New(
+	live *config.Live,
+	commonController controller.ServerResponse,
+	hub *events.Hub,
+	storageExchangeRates exchangerates.StorageExchangeRates,
+) *Controller {
+	return &Controller{
+		commonController:     commonController,
+		hub:                  hub,
+		storageExchangeRates: storageExchangeRates,
+		live:                 live,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+
+				return origin == "" || cors.New(live.Current().CORS).Allowed(origin)
+			},
+		},
+	}
+}
+
+// settings returns the heartbeat interval and the event buffer of a new stream, as configured at the time.
+func (c *Controller) settings() (time.Duration, int) {
+	current := c.live.Current()
+
+	return time.Duration(current.StreamHeartbeat) * time.Second, current.StreamBuffer
+}
+
+// RatesHandler streams the rates as Server-Sent Events named by the message type.
+func (c *Controller) RatesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "RatesHandler"
+
+	if r.Method != http.MethodGet {
+		c.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	validated := validation.NewRatesStream(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		c.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	heartbeat, buffer := c.settings()
+
+	subscription := c.hub.Subscribe(buffer, validated.Pairs()...)
+	defer c.hub.Unsubscribe(subscription)
+
+	snapshot, err := c.snapshot(r, subscription.Matches)
+	if err != nil {
+		c.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	// the stream outlives the read and write timeouts of the server
+	err = rc.SetReadDeadline(time.Time{})
+	if err == nil {
+		err = rc.SetWriteDeadline(time.Time{})
+	}
+
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+
+		return
+	}
+
+	send := func(msg message) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+
+		if msg.ID != 0 {
+			_, err = fmt.Fprintf(w, "id: %d\n", msg.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	err = send(snapshot)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+
+		return
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if subscription.Overflowed() {
+					_ = send(message{Type: messageOverflow})
+				}
+
+				return
+			}
+
+			err = send(c.eventMessage(r, event))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// RatesWebSocketHandler streams the rates over WebSocket. Clients may change their pairs
+// with {"action": "subscribe"|"unsubscribe", "pairs": ["USDEUR"]}, subscribing is followed by
+// the snapshot of the added pairs. Heartbeats are ping frames, a client missing two of them is disconnected.
+func (c *Controller) RatesWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "RatesWebSocketHandler"
+
+	validated := validation.NewRatesStream(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		c.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has responded with the error already
+		return
+	}
+	defer func(conn *websocket.Conn) {
+		err := conn.Close()
+		if err != nil {
+			logging.ErrorContext(r.Context(), f, op, err)
+		}
+	}(conn)
+
+	heartbeat, buffer := c.settings()
+
+	subscription := c.hub.Subscribe(buffer, validated.Pairs()...)
+	defer c.hub.Unsubscribe(subscription)
+
+	send := func(msg message) error {
+		err := conn.SetWriteDeadline(time.Now().Add(heartbeat))
+		if err != nil {
+			return err
+		}
+
+		return conn.WriteJSON(msg)
+	}
+
+	snapshot, err := c.snapshot(r, subscription.Matches)
+	if err != nil {
+		_ = send(message{Type: messageError, Code: controller.CodeServerError})
+
+		return
+	}
+
+	err = send(snapshot)
+	if err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	actions := c.readActions(conn, done, heartbeat)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeat))
+		case a, ok := <-actions:
+			if !ok {
+				return
+			}
+
+			err = c.apply(r, subscription, a, send)
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if subscription.Overflowed() {
+					_ = send(message{Type: messageOverflow})
+					_ = conn.WriteControl(
+						websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, messageOverflow),
+						time.Now().Add(heartbeat),
+					)
+
+					return
+				}
+
+				// the hub is closed on shutdown
+				_ = conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(heartbeat),
+				)
+
+				return
+			}
+
+			err = send(c.eventMessage(r, event))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readActions reads the actions of the client until the connection fails, the channel is closed then.
+// Malformed actions are passed on with an empty name. Reading stops once done is closed.
+func (c *Controller) readActions(conn *websocket.Conn, done <-chan struct{}, heartbeat time.Duration) <-chan action {
+	actions := make(chan action)
+
+	timeout := 2 * heartbeat
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	go func() {
+		defer close(actions)
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			a := action{}
+			if json.Unmarshal(data, &a) != nil {
+				a = action{}
+			}
+
+			select {
+			case actions <- a:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return actions
+}
+
+func (c *Controller) apply(
+	r *http.Request,
+	subscription *events.Subscription,
+	a action,
+	send func(msg message) error,
+) error {
+	if a.Action != actionSubscribe && a.Action != actionUnsubscribe {
+		return send(message{Type: messageError, Code: controller.CodeBodyInvalid})
+	}
+
+	validated := validation.NewRatesStreamValues(map[string]string{"pairs": strings.Join(a.Pairs, ",")})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return send(message{Type: messageError, Code: validated.Code()})
+	}
+
+	pairs := validated.Pairs()
+
+	if a.Action == actionUnsubscribe {
+		subscription.Remove(pairs...)
+
+		return nil
+	}
+
+	subscription.Add(pairs...)
+
+	snapshot, err := c.snapshot(r, func(pair string) bool {
+		for _, p := range pairs {
+			if p == pair {
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return send(message{Type: messageError, Code: controller.CodeServerError})
+	}
+
+	return send(snapshot)
+}
+
+// snapshot returns the message with the current rates of the pairs.
+func (c *Controller) snapshot(r *http.Request, matches func(pair string) bool) (message, error) {
+	const op = "snapshot"
+
+	items, _, err := c.storageExchangeRates.List(r.Context(), exchangerates.Filter{})
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+
+		return message{}, err
+	}
+
+	rates := []entity.ExchangeRates{}
+
+	for _, exchangeRate := range items {
+		if matches(exchangeRate.BaseCurrency.Code + exchangeRate.TargetCurrency.Code) {
+			rates = append(rates, exchangeRate)
+		}
+	}
+
+	return message{Type: messageSnapshot, Rates: c.commonController.Present(r, rates)}, nil
+}
+
+func (c *Controller) eventMessage(r *http.Request, event events.Event) message {
+	return message{
+		Type: string(event.Type),
+		ID:   event.ID,
+		Rate: c.commonController.Present(r, event.ExchangeRate),
+	}
+}
@@ -0,0 +1,312 @@
+package stream
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixture is a server of the streams of a database with the USD/EUR and EUR/RUB rates.
+type fixture struct {
+	hub    *events.Hub
+	server *httptest.Server
+	rates  map[string]entity.ExchangeRates
+}
+
+func setup(t *testing.T, buffer int) fixture {
+	t.Helper()
+
+	c := &config.Config{
+		PathToDB:        filepath.Join(t.TempDir(), "sqlite.db"),
+		StreamHeartbeat: 30,
+		StreamBuffer:    buffer,
+	}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := events.NewHub()
+	currencies := storageCurrencies.New(c.PathToDB, nil, nil)
+	exchangeRates := storageExchangeRates.New(c.PathToDB, nil, nil)
+
+	stored := map[string]entity.Currency{}
+	for _, code := range []string{"USD", "EUR", "RUB"} {
+		currency := entity.Currency{Code: code, FullName: code, Sign: code}
+
+		currency.ID, err = currencies.Add(context.Background(), currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored[code] = currency
+	}
+
+	rates := map[string]entity.ExchangeRates{}
+	for _, pair := range [][2]string{{"USD", "EUR"}, {"EUR", "RUB"}} {
+		exchangeRate := entity.ExchangeRates{BaseCurrency: stored[pair[0]], TargetCurrency: stored[pair[1]], Rate: 0.9}
+
+		exchangeRate.ID, err = exchangeRates.Add(context.Background(), exchangeRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rates[pair[0]+pair[1]] = exchangeRate
+	}
+
+	streams := New(config.NewLive(c), controller.New(controller.V2, i18n.MustNew("en")), hub, exchangeRates)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream/rates", streams.RatesHandler)
+	mux.HandleFunc("/stream/rates/ws", streams.RatesWebSocketHandler)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	t.Cleanup(hub.Close)
+
+	return fixture{hub: hub, server: server, rates: rates}
+}
+
+// dial connects to the WebSocket stream of the pairs and reads its snapshot.
+func (fx fixture) dial(t *testing.T, pairs string) (*websocket.Conn, received) {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(fx.server.URL, "http") + "/stream/rates/ws?pairs=" + pairs
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, read(t, conn)
+}
+
+func (fx fixture) publish(pair string) {
+	fx.hub.Publish(events.Event{Type: events.RateUpdated, ExchangeRate: fx.rates[pair]})
+}
+
+// received is a message read by a client, with its rates decoded loosely.
+type received struct {
+	Type  string                   `json:"type"`
+	Rates []map[string]interface{} `json:"rates"`
+	Rate  map[string]interface{}   `json:"rate"`
+	Code  string                   `json:"code"`
+}
+
+func read(t *testing.T, conn *websocket.Conn) received {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	msg := received{}
+
+	err := conn.ReadJSON(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+// pairOf returns the pair of a rate of a message.
+func pairOf(rate map[string]interface{}) string {
+	base, _ := rate["baseCurrency"].(map[string]interface{})
+	target, _ := rate["targetCurrency"].(map[string]interface{})
+
+	return base["code"].(string) + target["code"].(string)
+}
+
+// settle sends a malformed action and reads up to its error, so the actions sent before it are applied.
+func settle(t *testing.T, conn *websocket.Conn) []received {
+	t.Helper()
+
+	err := conn.WriteJSON(action{Action: "sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before []received
+
+	for {
+		msg := read(t, conn)
+		if msg.Type == messageError && msg.Code == controller.CodeBodyInvalid {
+			return before
+		}
+
+		before = append(before, msg)
+	}
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	fx := setup(t, 16)
+
+	conn, snapshot := fx.dial(t, "USDEUR")
+
+	if snapshot.Type != messageSnapshot || len(snapshot.Rates) != 1 || pairOf(snapshot.Rates[0]) != "USDEUR" {
+		t.Fatalf("snapshot %+v, want the rate of USDEUR", snapshot)
+	}
+
+	fx.publish("EURRUB")
+
+	if got := settle(t, conn); len(got) != 0 {
+		t.Fatalf("messages %+v, want none of other pairs", got)
+	}
+
+	err := conn.WriteJSON(action{Action: actionSubscribe, Pairs: []string{"EURRUB"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot = read(t, conn)
+	if snapshot.Type != messageSnapshot || len(snapshot.Rates) != 1 || pairOf(snapshot.Rates[0]) != "EURRUB" {
+		t.Fatalf("snapshot %+v, want the rate of the added pair", snapshot)
+	}
+
+	fx.publish("EURRUB")
+
+	event := read(t, conn)
+	if event.Type != string(events.RateUpdated) || pairOf(event.Rate) != "EURRUB" {
+		t.Errorf("message %+v, want the update of EURRUB", event)
+	}
+
+	err = conn.WriteJSON(action{Action: actionSubscribe, Pairs: []string{"USD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := read(t, conn); msg.Type != messageError || msg.Code != controller.CodeValidationFailed {
+		t.Errorf("message %+v, want the error of the invalid pair", msg)
+	}
+}
+
+func TestWebSocketUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		pairs  string
+		remove []string
+		want   []string
+	}{
+		{name: "from the last pair", pairs: "USDEUR", remove: []string{"USDEUR"}},
+		{name: "from one of the pairs", pairs: "USDEUR,EURRUB", remove: []string{"USDEUR"}, want: []string{"EURRUB"}},
+		{name: "from all pairs", remove: []string{"USDEUR"}, want: []string{"EURRUB"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := setup(t, 16)
+			conn, _ := fx.dial(t, tt.pairs)
+
+			err := conn.WriteJSON(action{Action: actionUnsubscribe, Pairs: tt.remove})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			settle(t, conn)
+
+			fx.publish("USDEUR")
+			fx.publish("EURRUB")
+
+			var got []string
+			for _, msg := range settle(t, conn) {
+				got = append(got, pairOf(msg.Rate))
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("events of %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketOverflow(t *testing.T) {
+	fx := setup(t, 1)
+	conn, _ := fx.dial(t, "")
+
+	// the events are published faster than they are sent
+	for i := 0; i < 10000; i++ {
+		fx.publish("USDEUR")
+	}
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		msg := received{}
+
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			t.Fatalf("connection closed without the overflow message: %v", err)
+		}
+
+		if msg.Type == messageOverflow {
+			break
+		}
+	}
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("error %v, want the connection closed to be tried again later", err)
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	fx := setup(t, 16)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, fx.server.URL+"/stream/rates?pairs=EURRUB", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("content type %q", response.Header.Get("Content-Type"))
+	}
+
+	buf := make([]byte, 4096)
+	stream := ""
+
+	// the events published once the snapshot was sent are streamed with their ids
+	published := false
+
+	for !strings.Contains(stream, "event: rate.updated") {
+		n, err := response.Body.Read(buf)
+		if err != nil {
+			t.Fatalf("stream %q: %v", stream, err)
+		}
+
+		stream += string(buf[:n])
+
+		if strings.Contains(stream, "event: snapshot") && !published {
+			fx.publish("USDEUR")
+			fx.publish("EURRUB")
+
+			published = true
+		}
+	}
+
+	if !strings.Contains(stream, `"code":"RUB"`) || strings.Count(stream, "event: rate.updated") != 1 {
+		t.Errorf("stream %q, want the snapshot and the update of EURRUB only", stream)
+	}
+}
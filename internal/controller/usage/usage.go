@@ -0,0 +1,61 @@
+package usage
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage/usage"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"net/http"
+)
+
+const f = "usage.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	storageUsage     usage.StorageUsage
+}
+
+func New(commonController controller.ServerResponse, storageUsage usage.StorageUsage) *Controller {
+	return &Controller{
+		commonController: commonController,
+		storageUsage:     storageUsage,
+	}
+}
+
+// UsageHandler shows the daily numbers of requests of the clients to the limited route groups, for billing.
+func (cu *Controller) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "UsageHandler"
+
+	if r.Method != http.MethodGet {
+		cu.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	validated := validation.NewUsage(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cu.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := usage.Filter{
+		Client:      validated.Field("client"),
+		From:        validated.Field("from"),
+		To:          validated.Field("to"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := cu.storageUsage.List(filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cu.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	cu.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
@@ -0,0 +1,257 @@
+package webhooks
+
+import (
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/webhooks"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	dispatcher "github.com/albakov/go-currency-exchange/internal/webhooks"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const f = "webhooks.Controller"
+
+type Controller struct {
+	commonController controller.ServerResponse
+	storageWebhooks  webhooks.StorageWebhooks
+}
+
+func New(commonController controller.ServerResponse, storageWebhooks webhooks.StorageWebhooks) *Controller {
+	return &Controller{
+		commonController: commonController,
+		storageWebhooks:  storageWebhooks,
+	}
+}
+
+func (cw *Controller) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		cw.webhooksGetHandler(w, r)
+
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		cw.webhooksAddHandler(w, r)
+
+		return
+	}
+
+	cw.commonController.ShowMethodNotAllowedError(w, r)
+}
+
+func (cw *Controller) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		cw.webhookGetHandler(w, r)
+
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		cw.webhookDeleteHandler(w, r)
+
+		return
+	}
+
+	cw.commonController.ShowMethodNotAllowedError(w, r)
+}
+
+// DeliveriesHandler shows the delivery log of the webhook, the newest deliveries first.
+// The dead-letter list is the log filtered by status=dead.
+func (cw *Controller) DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "DeliveriesHandler"
+
+	if r.Method != http.MethodGet {
+		cw.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	webhook, ok := cw.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	validated := validation.NewWebhookDeliveries(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cw.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	filter := webhooks.DeliveriesFilter{
+		Status:      validated.Field("status"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := cw.storageWebhooks.Deliveries(webhook.ID, filter)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	controller.SetPageHeaders(w, total, filter.NextOffset(len(items), total))
+	cw.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
+
+// RedeliverHandler queues a delivery from the dead-letter list again.
+func (cw *Controller) RedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "RedeliverHandler"
+
+	if r.Method != http.MethodPost {
+		cw.commonController.ShowMethodNotAllowedError(w, r)
+
+		return
+	}
+
+	webhook, ok := cw.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(r.PathValue("deliveryId"), 10, 64)
+	if err != nil {
+		cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookDeliveryNotFound)
+
+		return
+	}
+
+	err = cw.storageWebhooks.Redeliver(webhook.ID, deliveryId)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookDeliveryNotFound)
+
+			return
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cw *Controller) webhooksGetHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooksGetHandler"
+
+	items, err := cw.storageWebhooks.All()
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	cw.commonController.ShowResponse(w, r, http.StatusOK, items)
+}
+
+// webhooksAddHandler registers the webhook, the response is the only one showing its secret.
+func (cw *Controller) webhooksAddHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "webhooksAddHandler"
+
+	validated := validation.NewWebhooks(r)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		cw.commonController.ShowValidationError(w, r, validated)
+
+		return
+	}
+
+	webhook := entity.Webhook{
+		URL:       validated.Field("url"),
+		Events:    validated.Split("events"),
+		Secret:    validated.Field("secret"),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = dispatcher.NewSecret()
+	}
+
+	id, err := cw.storageWebhooks.Add(webhook)
+	if err != nil {
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	webhook.ID = id
+
+	cw.commonController.ShowResponse(w, r, http.StatusCreated, webhook)
+}
+
+func (cw *Controller) webhookGetHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := cw.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	cw.commonController.ShowResponse(w, r, http.StatusOK, webhook)
+}
+
+func (cw *Controller) webhookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "webhookDeleteHandler"
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookNotFound)
+
+		return
+	}
+
+	err = cw.storageWebhooks.Delete(id)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookNotFound)
+
+			return
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhook returns the webhook of the id path value, or responds with the error.
+func (cw *Controller) webhook(w http.ResponseWriter, r *http.Request) (entity.Webhook, bool) {
+	const op = "webhook"
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookNotFound)
+
+		return entity.Webhook{}, false
+	}
+
+	webhook, err := cw.storageWebhooks.ById(id)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			cw.commonController.ShowError(w, r, http.StatusNotFound, controller.CodeWebhookNotFound)
+
+			return entity.Webhook{}, false
+		}
+
+		logging.ErrorContext(r.Context(), f, op, err)
+		cw.commonController.ShowError(w, r, http.StatusInternalServerError, controller.CodeServerError)
+
+		return entity.Webhook{}, false
+	}
+
+	return webhook, true
+}
@@ -0,0 +1,222 @@
+package cors
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// requestHeaders are the request headers read by the API, always allowed besides the configured ones.
+var requestHeaders = []string{
+	"Accept-Language",
+	"Authorization",
+	"Content-Type",
+	"Idempotency-Key",
+	"If-Match",
+	"If-None-Match",
+	"X-API-Key",
+	"X-Request-ID",
+	"traceparent",
+	"tracestate",
+}
+
+// exposedHeaders are the response headers of the API scripts of other origins may read.
+var exposedHeaders = strings.Join([]string{
+	"Deprecation",
+	"ETag",
+	"Idempotent-Replayed",
+	"Link",
+	"Location",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+	"Sunset",
+	"X-Next-Cursor",
+	"X-Request-ID",
+	"X-Total-Count",
+}, ", ")
+
+// Policy decides which origins may make cross-origin requests and answers their preflight requests.
+type Policy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	// subdomains are the patterns as https://*.example.com, split into the scheme part and the domain suffix
+	subdomains []subdomain
+	// methods and headers are echoed from the preflight request if empty
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+type subdomain struct {
+	scheme, suffix string
+}
+
+// New builds the policy of the CORS settings, it allows no origin if none is set.
+func New(c config.CORS) *Policy {
+	p := &Policy{
+		origins:     map[string]bool{},
+		credentials: c.AccessControlAllowCredentials,
+	}
+
+	for _, origin := range split(c.AccessControlAllowOrigin) {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+
+		if origin == "*" {
+			p.anyOrigin = true
+
+			continue
+		}
+
+		scheme, host, found := strings.Cut(origin, "://*.")
+		if found {
+			p.subdomains = append(p.subdomains, subdomain{scheme: scheme + "://", suffix: "." + host})
+
+			continue
+		}
+
+		p.origins[origin] = true
+	}
+
+	if c.AccessControlAllowMethods != "*" {
+		p.methods = strings.Join(split(c.AccessControlAllowMethods), ", ")
+	}
+
+	if c.AccessControlAllowHeaders != "*" {
+		p.headers = strings.Join(merge(split(c.AccessControlAllowHeaders), requestHeaders), ", ")
+	}
+
+	if c.AccessControlMaxAge > 0 {
+		p.maxAge = strconv.FormatInt(c.AccessControlMaxAge, 10)
+	}
+
+	return p
+}
+
+// Allowed reports whether the origin may make cross-origin requests.
+func (p *Policy) Allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+
+	for _, s := range p.subdomains {
+		host, found := strings.CutPrefix(origin, s.scheme)
+		if found && len(host) > len(s.suffix) && strings.HasSuffix(host, s.suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply sets the CORS headers of the response to r. It answers r if it is a preflight request and reports whether
+// it did, other requests are left to be served.
+func (p *Policy) Apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
+
+	// caches must not serve a response to an origin it was not meant for
+	if !p.anyOrigin || p.credentials {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if preflight {
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if origin == "" {
+		return false
+	}
+
+	if !p.Allowed(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return preflight
+	}
+
+	if p.anyOrigin && !p.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+		return false
+	}
+
+	methods := p.methods
+	if methods == "" {
+		methods = r.Header.Get("Access-Control-Request-Method")
+	}
+
+	headers := p.headers
+	if headers == "" {
+		headers = r.Header.Get("Access-Control-Request-Headers")
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+
+	if headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+
+	if p.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// merge appends the items of b missing in a, compared case-insensitively.
+func merge(a, b []string) []string {
+	items := append([]string{}, a...)
+
+	for _, item := range b {
+		found := false
+		for _, existing := range a {
+			if strings.EqualFold(existing, item) {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// split splits a comma separated list, dropping empty items.
+func split(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,195 @@
+package cors
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins string
+		origin  string
+		want    bool
+	}{
+		{name: "none configured", origin: "https://example.com"},
+		{name: "any origin", origins: "*", origin: "https://example.com", want: true},
+		{name: "listed origin", origins: "https://a.com, https://example.com", origin: "https://example.com", want: true},
+		{name: "listed with a trailing slash", origins: "https://example.com/", origin: "https://example.com", want: true},
+		{name: "case of the origin", origins: "https://Example.com", origin: "https://EXAMPLE.com", want: true},
+		{name: "other scheme", origins: "https://example.com", origin: "http://example.com"},
+		{name: "other port", origins: "https://example.com", origin: "https://example.com:8443"},
+		{name: "unlisted origin", origins: "https://example.com", origin: "https://example.org"},
+		{name: "subdomain", origins: "https://*.example.com", origin: "https://app.example.com", want: true},
+		{name: "nested subdomain", origins: "https://*.example.com", origin: "https://a.b.example.com", want: true},
+		{name: "domain of the subdomains", origins: "https://*.example.com", origin: "https://example.com"},
+		{name: "subdomain of another scheme", origins: "https://*.example.com", origin: "http://app.example.com"},
+		{name: "suffix of another domain", origins: "https://*.example.com", origin: "https://app.notexample.com"},
+		{name: "domain continued", origins: "https://*.example.com", origin: "https://app.example.com.evil.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(config.CORS{AccessControlAllowOrigin: tt.origins}).Allowed(tt.origin)
+			if got != tt.want {
+				t.Errorf("allowed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	listed := config.CORS{
+		AccessControlAllowOrigin:  "https://example.com",
+		AccessControlAllowMethods: "GET, POST, PATCH",
+		AccessControlAllowHeaders: "X-Custom",
+		AccessControlMaxAge:       600,
+	}
+
+	tests := []struct {
+		name   string
+		cors   config.CORS
+		method string
+		// headers of the request
+		headers map[string]string
+		// answered tells whether Apply answers the request as a preflight one
+		answered bool
+		// want are the response headers, an empty value stands for a header not sent
+		want map[string]string
+	}{
+		{
+			name:   "not a CORS request",
+			cors:   listed,
+			method: http.MethodGet,
+			want:   map[string]string{"Vary": "Origin", "Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "allowed origin",
+			cors:    listed,
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.com"},
+			want: map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Allow-Methods":     "",
+			},
+		},
+		{
+			name:    "other origin",
+			cors:    listed,
+			method:  http.MethodPost,
+			headers: map[string]string{"Origin": "https://example.org"},
+			want:    map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Expose-Headers": ""},
+		},
+		{
+			name:     "preflight",
+			cors:     listed,
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "PATCH"},
+			answered: true,
+			want: map[string]string{
+				"Vary":                          "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":   "https://example.com",
+				"Access-Control-Allow-Methods":  "GET, POST, PATCH",
+				"Access-Control-Max-Age":        "600",
+				"Access-Control-Expose-Headers": "",
+			},
+		},
+		{
+			name:     "preflight of another origin",
+			cors:     listed,
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://example.org", "Access-Control-Request-Method": "PATCH"},
+			answered: true,
+			want:     map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "OPTIONS without a requested method",
+			cors:    listed,
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://example.com"},
+			want:    map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "any origin",
+			cors:    config.CORS{AccessControlAllowOrigin: "*"},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.org"},
+			want:    map[string]string{"Vary": "", "Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:    "credentials",
+			cors:    config.CORS{AccessControlAllowOrigin: "https://*.example.com", AccessControlAllowCredentials: true},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			want: map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:   "requested methods and headers echoed",
+			cors:   config.CORS{AccessControlAllowOrigin: "*", AccessControlAllowMethods: "*", AccessControlAllowHeaders: "*"},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://example.org",
+				"Access-Control-Request-Method":  "DELETE",
+				"Access-Control-Request-Headers": "x-trace",
+			},
+			answered: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "DELETE",
+				"Access-Control-Allow-Headers": "x-trace",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v2/currencies", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+
+			answered := New(tt.cors).Apply(w, r)
+			if answered != tt.answered {
+				t.Fatalf("answered %v, want %v", answered, tt.answered)
+			}
+
+			if answered && w.Code != http.StatusNoContent {
+				t.Errorf("status %d, want 204", w.Code)
+			}
+
+			for name, want := range tt.want {
+				got := strings.Join(w.Header().Values(name), ", ")
+				if got != want {
+					t.Errorf("%s %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyAllowsConfiguredAndReadHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/v2/currencies", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+
+	w := httptest.NewRecorder()
+	New(config.CORS{AccessControlAllowOrigin: "https://example.com", AccessControlAllowHeaders: "X-Custom, authorization"}).Apply(w, r)
+
+	headers := strings.Split(w.Header().Get("Access-Control-Allow-Headers"), ", ")
+
+	if len(headers) != len(requestHeaders)+1 || headers[0] != "X-Custom" || headers[1] != "authorization" {
+		t.Errorf("allowed headers %v, want the configured ones and the ones the API reads, without duplicates", headers)
+	}
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// APIKey authenticates a client, it is stored only as a hash.
+type APIKey struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// Key is the plain key, it is shown only when the key is issued
+	Key       string     `json:"key,omitempty"`
+	Hash      string     `json:"-"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
@@ -1,8 +1,13 @@
 package entity
 
+import "time"
+
 type ExchangeRates struct {
-	ID             int64    `json:"id"`
-	BaseCurrency   Currency `json:"baseCurrency"`
-	TargetCurrency Currency `json:"targetCurrency"`
-	Rate           float64  `json:"rate"`
+	ID             int64     `json:"id"`
+	BaseCurrency   Currency  `json:"baseCurrency"`
+	TargetCurrency Currency  `json:"targetCurrency"`
+	Rate           float64   `json:"rate"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	// Version is incremented by every update, it is shown as the ETag
+	Version int64 `json:"-"`
 }
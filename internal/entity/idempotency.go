@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// IdempotencyRecord is the response to the first request of a client with an Idempotency-Key,
+// replayed on the repeats of the request.
+type IdempotencyRecord struct {
+	Client string
+	Key    string
+	// RequestHash identifies the method, URI and body of the request
+	RequestHash string
+	// Status is zero while the first request is in progress
+	Status    int
+	Headers   map[string]string
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
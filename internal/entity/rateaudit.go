@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// Actions of rate audit entries.
+const (
+	RateAuditCreated = "created"
+	RateAuditUpdated = "updated"
+)
+
+// RateAudit records a change of an exchange rate and who made it.
+type RateAudit struct {
+	ID                 int64  `json:"id"`
+	ExchangeRateID     int64  `json:"exchangeRateId"`
+	BaseCurrencyCode   string `json:"baseCurrencyCode"`
+	TargetCurrencyCode string `json:"targetCurrencyCode"`
+	Action             string `json:"action"`
+	// OldRate is nil if the rate was created
+	OldRate *float64 `json:"oldRate"`
+	NewRate float64  `json:"newRate"`
+	// Subject is the subject of the token or apikey:<prefix> of the API key the change was authenticated with
+	Subject string `json:"subject"`
+	// ProposalID and ProposedBy are set if the change was approved by Subject on proposal
+	ProposalID int64     `json:"proposalId,omitempty"`
+	ProposedBy string    `json:"proposedBy,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
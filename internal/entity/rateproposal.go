@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// Actions of rate proposals.
+const (
+	ProposalCreate = "create"
+	ProposalUpdate = "update"
+)
+
+// Statuses of rate proposals.
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+	// ProposalExpired is the status of proposals not decided on before ExpiresAt
+	ProposalExpired = "expired"
+)
+
+// RateProposal is a rate change waiting for the approval of a user other than the one proposing it.
+type RateProposal struct {
+	ID     int64  `json:"id"`
+	Action string `json:"action"`
+	// ExchangeRateID is zero until a proposal to create a rate is approved
+	ExchangeRateID     int64  `json:"exchangeRateId"`
+	BaseCurrencyCode   string `json:"baseCurrencyCode"`
+	TargetCurrencyCode string `json:"targetCurrencyCode"`
+	// OldRate is the rate at the time of the proposal, nil if the rate is to be created
+	OldRate *float64 `json:"oldRate"`
+	// ExchangeRateVersion is the version of the rate at the time of the proposal, it is applied only to that version
+	ExchangeRateVersion int64   `json:"-"`
+	Rate                float64 `json:"rate"`
+	Status              string  `json:"status"`
+	// ProposedBy and DecidedBy are the owners of the credentials, see auth.Principal
+	ProposedBy string `json:"proposedBy"`
+	DecidedBy  string `json:"decidedBy,omitempty"`
+	// Reason is the reason of a rejection
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	DecidedAt *time.Time `json:"decidedAt"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+// Usage is the number of requests of a client to a route group on a day.
+type Usage struct {
+	Client string `json:"client"`
+	Route  string `json:"route"`
+	// Day is the UTC date, as 2006-01-02
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+}
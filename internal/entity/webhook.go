@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook receives the events of the listed types, or of all types if Events is empty.
+type Webhook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs the deliveries, it is shown only when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead is the status of deliveries that failed all attempts, they form the dead-letter list
+	DeliveryDead = "dead"
+)
+
+// WebhookDelivery is a queued event payload for a webhook along with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int64           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	// URL and Secret of the webhook, set for the deliveries due to be sent
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
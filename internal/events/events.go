@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"time"
+)
+
+// Type is the kind of change an event reports.
+type Type string
+
+const (
+	CurrencyCreated Type = "currency.created"
+	RateCreated     Type = "rate.created"
+	RateUpdated     Type = "rate.updated"
+)
+
+// Types lists all event types.
+var Types = []Type{CurrencyCreated, RateCreated, RateUpdated}
+
+// Event is a change of stored data. Currency events hold the Currency, rate events the ExchangeRate.
+type Event struct {
+	// ID is assigned by the hub, it grows with every published event
+	ID           uint64
+	Type         Type
+	Currency     entity.Currency
+	ExchangeRate entity.ExchangeRates
+	OccurredAt   time.Time
+}
+
+// Pair returns the codes of the base and the target currency of the exchange rate, e.g. USDEUR.
+func (e Event) Pair() string {
+	return e.ExchangeRate.BaseCurrency.Code + e.ExchangeRate.TargetCurrency.Code
+}
+
+// Publisher is notified of the changes made by the storages.
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Outbox stores the events in the transaction writing the changes they report, so an event is kept if and only if
+// its change is. The storages publish the event once the transaction is committed.
+type Outbox interface {
+	Enqueue(ctx context.Context, tx *sql.Tx, event Event) error
+}
+
+// Publishers passes events on to each of its publishers in order.
+type Publishers []Publisher
+
+func (p Publishers) Publish(event Event) {
+	for _, publisher := range p {
+		publisher.Publish(event)
+	}
+}
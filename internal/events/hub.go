@@ -0,0 +1,158 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Hub fans published events out to its subscriptions. Publishing never blocks: a subscription
+// whose buffer is full is closed and marked as overflowed, its consumer is expected to reconnect.
+type Hub struct {
+	mu            sync.Mutex
+	lastID        uint64
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+
+	for s := range h.subscriptions {
+		if !s.Matches(event.Pair()) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			s.overflowed.Store(true)
+			h.remove(s)
+		}
+	}
+}
+
+// Subscribe returns the subscription to events of the pairs, or of all pairs if none are given,
+// buffering up to buffer events.
+func (h *Hub) Subscribe(buffer int, pairs ...string) *Subscription {
+	s := &Subscription{
+		events:   make(chan Event, buffer),
+		all:      len(pairs) == 0,
+		pairs:    map[string]struct{}{},
+		excluded: map[string]struct{}{},
+	}
+	s.Add(pairs...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(s.events)
+
+		return s
+	}
+
+	h.subscriptions[s] = struct{}{}
+
+	return s
+}
+
+// Unsubscribe closes the subscription, it is safe to call it for a closed subscription.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(s)
+}
+
+// Close closes all subscriptions, and the ones made afterwards, so their consumers stop on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	for s := range h.subscriptions {
+		h.remove(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subscriptions[s]; !ok {
+		return
+	}
+
+	delete(h.subscriptions, s)
+	close(s.events)
+}
+
+// Subscription receives the events of the pairs it is subscribed to.
+type Subscription struct {
+	events     chan Event
+	overflowed atomic.Bool
+	mu         sync.RWMutex
+	// all is set for the subscriptions made to no pairs, they receive the events of the pairs not excluded
+	all      bool
+	pairs    map[string]struct{}
+	excluded map[string]struct{}
+}
+
+// Events returns the channel of events, it is closed once the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Overflowed reports whether the subscription was closed because its consumer fell behind.
+func (s *Subscription) Overflowed() bool {
+	return s.overflowed.Load()
+}
+
+// Add subscribes to the pairs in addition to the current ones.
+func (s *Subscription) Add(pairs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range pairs {
+		s.pairs[pair] = struct{}{}
+		delete(s.excluded, pair)
+	}
+}
+
+// Remove unsubscribes from the pairs, a subscription left with no pairs receives no events.
+func (s *Subscription) Remove(pairs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range pairs {
+		delete(s.pairs, pair)
+
+		if s.all {
+			s.excluded[pair] = struct{}{}
+		}
+	}
+}
+
+// Matches reports whether the subscription receives the events of the pair.
+func (s *Subscription) Matches(pair string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.all {
+		_, excluded := s.excluded[pair]
+
+		return !excluded
+	}
+
+	_, ok := s.pairs[pair]
+
+	return ok
+}
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"testing"
+)
+
+// rateEvent returns the update of the rate of the pair of currency codes.
+func rateEvent(base, target string) Event {
+	return Event{
+		Type: RateUpdated,
+		ExchangeRate: entity.ExchangeRates{
+			BaseCurrency:   entity.Currency{Code: base},
+			TargetCurrency: entity.Currency{Code: target},
+		},
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		pairs      []string
+		add        []string
+		remove     []string
+		matches    []string
+		mismatches []string
+	}{
+		{
+			name:    "all pairs",
+			matches: []string{"USDEUR", "EURRUB"},
+		},
+		{
+			name:       "subscribed pairs",
+			pairs:      []string{"USDEUR"},
+			matches:    []string{"USDEUR"},
+			mismatches: []string{"EURUSD", "EURRUB"},
+		},
+		{
+			name:       "added pairs",
+			pairs:      []string{"USDEUR"},
+			add:        []string{"EURRUB"},
+			matches:    []string{"USDEUR", "EURRUB"},
+			mismatches: []string{"USDRUB"},
+		},
+		{
+			name:       "unsubscribed from the last pair",
+			pairs:      []string{"USDEUR"},
+			remove:     []string{"USDEUR"},
+			mismatches: []string{"USDEUR", "EURRUB"},
+		},
+		{
+			name:       "all pairs but the unsubscribed ones",
+			remove:     []string{"USDEUR"},
+			matches:    []string{"EURRUB"},
+			mismatches: []string{"USDEUR"},
+		},
+		{
+			name:    "all pairs with an unsubscribed pair subscribed again",
+			remove:  []string{"USDEUR"},
+			add:     []string{"USDEUR"},
+			matches: []string{"USDEUR", "EURRUB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewHub().Subscribe(1, tt.pairs...)
+			s.Remove(tt.remove...)
+			s.Add(tt.add...)
+
+			for _, pair := range tt.matches {
+				if !s.Matches(pair) {
+					t.Errorf("%s does not match", pair)
+				}
+			}
+
+			for _, pair := range tt.mismatches {
+				if s.Matches(pair) {
+					t.Errorf("%s matches", pair)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishDeliversMatchingEvents(t *testing.T) {
+	hub := NewHub()
+	usdEur := hub.Subscribe(4, "USDEUR")
+	all := hub.Subscribe(4)
+
+	hub.Publish(rateEvent("EUR", "RUB"))
+	hub.Publish(rateEvent("USD", "EUR"))
+
+	event := <-usdEur.Events()
+	if event.Pair() != "USDEUR" || event.ID != 2 {
+		t.Errorf("event %+v, want the second one, of USDEUR", event)
+	}
+
+	if len(usdEur.Events()) != 0 {
+		t.Errorf("%d more events, want none of other pairs", len(usdEur.Events()))
+	}
+
+	if len(all.Events()) != 2 {
+		t.Errorf("%d events, want both", len(all.Events()))
+	}
+
+	usdEur.Remove("USDEUR")
+	hub.Publish(rateEvent("USD", "EUR"))
+
+	if len(usdEur.Events()) != 0 {
+		t.Error("event delivered after unsubscribing from the last pair")
+	}
+}
+
+func TestPublishClosesOverflowedSubscriptions(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe(1)
+	fast := hub.Subscribe(3)
+
+	for i := 0; i < 2; i++ {
+		hub.Publish(rateEvent("USD", "EUR"))
+	}
+
+	if !slow.Overflowed() {
+		t.Fatal("subscription falling behind not overflowed")
+	}
+
+	// the buffered event is still received before the channel is closed
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+
+	if received != 1 {
+		t.Errorf("%d events received, want the buffered one", received)
+	}
+
+	if fast.Overflowed() || len(fast.Events()) != 2 {
+		t.Errorf("subscription within its buffer overflowed %t with %d events", fast.Overflowed(), len(fast.Events()))
+	}
+
+	// unsubscribing a closed subscription is safe
+	hub.Unsubscribe(slow)
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	hub := NewHub()
+	before := hub.Subscribe(1)
+
+	hub.Close()
+	after := hub.Subscribe(1)
+
+	for _, s := range []*Subscription{before, after} {
+		if _, ok := <-s.Events(); ok {
+			t.Error("subscription open after the hub was closed")
+		}
+
+		if s.Overflowed() {
+			t.Error("closed subscription reported as overflowed")
+		}
+	}
+}
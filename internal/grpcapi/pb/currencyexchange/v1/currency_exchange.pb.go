@@ -0,0 +1,1045 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: currencyexchange/v1/currency_exchange.proto
+
+package currencyexchangev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Currency struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	FullName      string                 `protobuf:"bytes,3,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	Sign          string                 `protobuf:"bytes,4,opt,name=sign,proto3" json:"sign,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Currency) Reset() {
+	*x = Currency{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Currency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Currency) ProtoMessage() {}
+
+func (x *Currency) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Currency.ProtoReflect.Descriptor instead.
+func (*Currency) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Currency) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Currency) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Currency) GetFullName() string {
+	if x != nil {
+		return x.FullName
+	}
+	return ""
+}
+
+func (x *Currency) GetSign() string {
+	if x != nil {
+		return x.Sign
+	}
+	return ""
+}
+
+type ExchangeRate struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	BaseCurrency   *Currency              `protobuf:"bytes,2,opt,name=base_currency,json=baseCurrency,proto3" json:"base_currency,omitempty"`
+	TargetCurrency *Currency              `protobuf:"bytes,3,opt,name=target_currency,json=targetCurrency,proto3" json:"target_currency,omitempty"`
+	Rate           string                 `protobuf:"bytes,4,opt,name=rate,proto3" json:"rate,omitempty"`
+	// RFC 3339 time of the last change
+	UpdatedAt     string `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExchangeRate) Reset() {
+	*x = ExchangeRate{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExchangeRate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExchangeRate) ProtoMessage() {}
+
+func (x *ExchangeRate) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExchangeRate.ProtoReflect.Descriptor instead.
+func (*ExchangeRate) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExchangeRate) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ExchangeRate) GetBaseCurrency() *Currency {
+	if x != nil {
+		return x.BaseCurrency
+	}
+	return nil
+}
+
+func (x *ExchangeRate) GetTargetCurrency() *Currency {
+	if x != nil {
+		return x.TargetCurrency
+	}
+	return nil
+}
+
+func (x *ExchangeRate) GetRate() string {
+	if x != nil {
+		return x.Rate
+	}
+	return ""
+}
+
+func (x *ExchangeRate) GetUpdatedAt() string {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return ""
+}
+
+type ListCurrenciesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// page size, 0 returns all currencies
+	Limit int64 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	// next_cursor of the previous page
+	Cursor string `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// id, code or name, prefixed with - for descending order
+	Sort string `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	// beginning of the currency code
+	Code string `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`
+	// part of the currency name
+	Name          string `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCurrenciesRequest) Reset() {
+	*x = ListCurrenciesRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCurrenciesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCurrenciesRequest) ProtoMessage() {}
+
+func (x *ListCurrenciesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCurrenciesRequest.ProtoReflect.Descriptor instead.
+func (*ListCurrenciesRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListCurrenciesRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListCurrenciesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListCurrenciesRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListCurrenciesRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ListCurrenciesRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListCurrenciesResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Currencies []*Currency            `protobuf:"bytes,1,rep,name=currencies,proto3" json:"currencies,omitempty"`
+	TotalCount int64                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	// empty on the last page
+	NextCursor    string `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCurrenciesResponse) Reset() {
+	*x = ListCurrenciesResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCurrenciesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCurrenciesResponse) ProtoMessage() {}
+
+func (x *ListCurrenciesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCurrenciesResponse.ProtoReflect.Descriptor instead.
+func (*ListCurrenciesResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListCurrenciesResponse) GetCurrencies() []*Currency {
+	if x != nil {
+		return x.Currencies
+	}
+	return nil
+}
+
+func (x *ListCurrenciesResponse) GetTotalCount() int64 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *ListCurrenciesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type GetCurrencyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrencyRequest) Reset() {
+	*x = GetCurrencyRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrencyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrencyRequest) ProtoMessage() {}
+
+func (x *GetCurrencyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrencyRequest.ProtoReflect.Descriptor instead.
+func (*GetCurrencyRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetCurrencyRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type GetCurrencyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Currency      *Currency              `protobuf:"bytes,1,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrencyResponse) Reset() {
+	*x = GetCurrencyResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrencyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrencyResponse) ProtoMessage() {}
+
+func (x *GetCurrencyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrencyResponse.ProtoReflect.Descriptor instead.
+func (*GetCurrencyResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetCurrencyResponse) GetCurrency() *Currency {
+	if x != nil {
+		return x.Currency
+	}
+	return nil
+}
+
+type ListExchangeRatesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// page size, 0 returns all rates
+	Limit int64 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	// next_cursor of the previous page
+	Cursor string `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// id, code, rate or updated, prefixed with - for descending order
+	Sort string `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	// code of the base currency
+	Base string `protobuf:"bytes,4,opt,name=base,proto3" json:"base,omitempty"`
+	// code of the target currency
+	Target        string `protobuf:"bytes,5,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExchangeRatesRequest) Reset() {
+	*x = ListExchangeRatesRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExchangeRatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExchangeRatesRequest) ProtoMessage() {}
+
+func (x *ListExchangeRatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExchangeRatesRequest.ProtoReflect.Descriptor instead.
+func (*ListExchangeRatesRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListExchangeRatesRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListExchangeRatesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListExchangeRatesRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListExchangeRatesRequest) GetBase() string {
+	if x != nil {
+		return x.Base
+	}
+	return ""
+}
+
+func (x *ListExchangeRatesRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type ListExchangeRatesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExchangeRates []*ExchangeRate        `protobuf:"bytes,1,rep,name=exchange_rates,json=exchangeRates,proto3" json:"exchange_rates,omitempty"`
+	TotalCount    int64                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	// empty on the last page
+	NextCursor    string `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExchangeRatesResponse) Reset() {
+	*x = ListExchangeRatesResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExchangeRatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExchangeRatesResponse) ProtoMessage() {}
+
+func (x *ListExchangeRatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExchangeRatesResponse.ProtoReflect.Descriptor instead.
+func (*ListExchangeRatesResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListExchangeRatesResponse) GetExchangeRates() []*ExchangeRate {
+	if x != nil {
+		return x.ExchangeRates
+	}
+	return nil
+}
+
+func (x *ListExchangeRatesResponse) GetTotalCount() int64 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *ListExchangeRatesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type GetExchangeRateRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrencyCode   string                 `protobuf:"bytes,1,opt,name=base_currency_code,json=baseCurrencyCode,proto3" json:"base_currency_code,omitempty"`
+	TargetCurrencyCode string                 `protobuf:"bytes,2,opt,name=target_currency_code,json=targetCurrencyCode,proto3" json:"target_currency_code,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *GetExchangeRateRequest) Reset() {
+	*x = GetExchangeRateRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExchangeRateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExchangeRateRequest) ProtoMessage() {}
+
+func (x *GetExchangeRateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExchangeRateRequest.ProtoReflect.Descriptor instead.
+func (*GetExchangeRateRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetExchangeRateRequest) GetBaseCurrencyCode() string {
+	if x != nil {
+		return x.BaseCurrencyCode
+	}
+	return ""
+}
+
+func (x *GetExchangeRateRequest) GetTargetCurrencyCode() string {
+	if x != nil {
+		return x.TargetCurrencyCode
+	}
+	return ""
+}
+
+type GetExchangeRateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExchangeRate  *ExchangeRate          `protobuf:"bytes,1,opt,name=exchange_rate,json=exchangeRate,proto3" json:"exchange_rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExchangeRateResponse) Reset() {
+	*x = GetExchangeRateResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExchangeRateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExchangeRateResponse) ProtoMessage() {}
+
+func (x *GetExchangeRateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExchangeRateResponse.ProtoReflect.Descriptor instead.
+func (*GetExchangeRateResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetExchangeRateResponse) GetExchangeRate() *ExchangeRate {
+	if x != nil {
+		return x.ExchangeRate
+	}
+	return nil
+}
+
+type UpsertExchangeRateRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrencyCode   string                 `protobuf:"bytes,1,opt,name=base_currency_code,json=baseCurrencyCode,proto3" json:"base_currency_code,omitempty"`
+	TargetCurrencyCode string                 `protobuf:"bytes,2,opt,name=target_currency_code,json=targetCurrencyCode,proto3" json:"target_currency_code,omitempty"`
+	Rate               string                 `protobuf:"bytes,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *UpsertExchangeRateRequest) Reset() {
+	*x = UpsertExchangeRateRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertExchangeRateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertExchangeRateRequest) ProtoMessage() {}
+
+func (x *UpsertExchangeRateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertExchangeRateRequest.ProtoReflect.Descriptor instead.
+func (*UpsertExchangeRateRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UpsertExchangeRateRequest) GetBaseCurrencyCode() string {
+	if x != nil {
+		return x.BaseCurrencyCode
+	}
+	return ""
+}
+
+func (x *UpsertExchangeRateRequest) GetTargetCurrencyCode() string {
+	if x != nil {
+		return x.TargetCurrencyCode
+	}
+	return ""
+}
+
+func (x *UpsertExchangeRateRequest) GetRate() string {
+	if x != nil {
+		return x.Rate
+	}
+	return ""
+}
+
+type UpsertExchangeRateResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	ExchangeRate *ExchangeRate          `protobuf:"bytes,1,opt,name=exchange_rate,json=exchangeRate,proto3" json:"exchange_rate,omitempty"`
+	// true if the pair did not exist before
+	Created       bool `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertExchangeRateResponse) Reset() {
+	*x = UpsertExchangeRateResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertExchangeRateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertExchangeRateResponse) ProtoMessage() {}
+
+func (x *UpsertExchangeRateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertExchangeRateResponse.ProtoReflect.Descriptor instead.
+func (*UpsertExchangeRateResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UpsertExchangeRateResponse) GetExchangeRate() *ExchangeRate {
+	if x != nil {
+		return x.ExchangeRate
+	}
+	return nil
+}
+
+func (x *UpsertExchangeRateResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
+type ConvertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Amount        string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertRequest) Reset() {
+	*x = ConvertRequest{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertRequest) ProtoMessage() {}
+
+func (x *ConvertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertRequest.ProtoReflect.Descriptor instead.
+func (*ConvertRequest) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ConvertRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ConvertRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ConvertRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+type ConvertResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrency    *Currency              `protobuf:"bytes,1,opt,name=base_currency,json=baseCurrency,proto3" json:"base_currency,omitempty"`
+	TargetCurrency  *Currency              `protobuf:"bytes,2,opt,name=target_currency,json=targetCurrency,proto3" json:"target_currency,omitempty"`
+	Rate            string                 `protobuf:"bytes,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	Amount          string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	ConvertedAmount string                 `protobuf:"bytes,5,opt,name=converted_amount,json=convertedAmount,proto3" json:"converted_amount,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ConvertResponse) Reset() {
+	*x = ConvertResponse{}
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertResponse) ProtoMessage() {}
+
+func (x *ConvertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_currencyexchange_v1_currency_exchange_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertResponse.ProtoReflect.Descriptor instead.
+func (*ConvertResponse) Descriptor() ([]byte, []int) {
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ConvertResponse) GetBaseCurrency() *Currency {
+	if x != nil {
+		return x.BaseCurrency
+	}
+	return nil
+}
+
+func (x *ConvertResponse) GetTargetCurrency() *Currency {
+	if x != nil {
+		return x.TargetCurrency
+	}
+	return nil
+}
+
+func (x *ConvertResponse) GetRate() string {
+	if x != nil {
+		return x.Rate
+	}
+	return ""
+}
+
+func (x *ConvertResponse) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *ConvertResponse) GetConvertedAmount() string {
+	if x != nil {
+		return x.ConvertedAmount
+	}
+	return ""
+}
+
+var File_currencyexchange_v1_currency_exchange_proto protoreflect.FileDescriptor
+
+const file_currencyexchange_v1_currency_exchange_proto_rawDesc = "" +
+	"\n" +
+	"+currencyexchange/v1/currency_exchange.proto\x12\x13currencyexchange.v1\"_\n" +
+	"\bCurrency\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x1b\n" +
+	"\tfull_name\x18\x03 \x01(\tR\bfullName\x12\x12\n" +
+	"\x04sign\x18\x04 \x01(\tR\x04sign\"\xdd\x01\n" +
+	"\fExchangeRate\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12B\n" +
+	"\rbase_currency\x18\x02 \x01(\v2\x1d.currencyexchange.v1.CurrencyR\fbaseCurrency\x12F\n" +
+	"\x0ftarget_currency\x18\x03 \x01(\v2\x1d.currencyexchange.v1.CurrencyR\x0etargetCurrency\x12\x12\n" +
+	"\x04rate\x18\x04 \x01(\tR\x04rate\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\tR\tupdatedAt\"\x81\x01\n" +
+	"\x15ListCurrenciesRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x03R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\x12\x12\n" +
+	"\x04sort\x18\x03 \x01(\tR\x04sort\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\"\x99\x01\n" +
+	"\x16ListCurrenciesResponse\x12=\n" +
+	"\n" +
+	"currencies\x18\x01 \x03(\v2\x1d.currencyexchange.v1.CurrencyR\n" +
+	"currencies\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x03R\n" +
+	"totalCount\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"(\n" +
+	"\x12GetCurrencyRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"P\n" +
+	"\x13GetCurrencyResponse\x129\n" +
+	"\bcurrency\x18\x01 \x01(\v2\x1d.currencyexchange.v1.CurrencyR\bcurrency\"\x88\x01\n" +
+	"\x18ListExchangeRatesRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x03R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\x12\x12\n" +
+	"\x04sort\x18\x03 \x01(\tR\x04sort\x12\x12\n" +
+	"\x04base\x18\x04 \x01(\tR\x04base\x12\x16\n" +
+	"\x06target\x18\x05 \x01(\tR\x06target\"\xa7\x01\n" +
+	"\x19ListExchangeRatesResponse\x12H\n" +
+	"\x0eexchange_rates\x18\x01 \x03(\v2!.currencyexchange.v1.ExchangeRateR\rexchangeRates\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x03R\n" +
+	"totalCount\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"x\n" +
+	"\x16GetExchangeRateRequest\x12,\n" +
+	"\x12base_currency_code\x18\x01 \x01(\tR\x10baseCurrencyCode\x120\n" +
+	"\x14target_currency_code\x18\x02 \x01(\tR\x12targetCurrencyCode\"a\n" +
+	"\x17GetExchangeRateResponse\x12F\n" +
+	"\rexchange_rate\x18\x01 \x01(\v2!.currencyexchange.v1.ExchangeRateR\fexchangeRate\"\x8f\x01\n" +
+	"\x19UpsertExchangeRateRequest\x12,\n" +
+	"\x12base_currency_code\x18\x01 \x01(\tR\x10baseCurrencyCode\x120\n" +
+	"\x14target_currency_code\x18\x02 \x01(\tR\x12targetCurrencyCode\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\tR\x04rate\"~\n" +
+	"\x1aUpsertExchangeRateResponse\x12F\n" +
+	"\rexchange_rate\x18\x01 \x01(\v2!.currencyexchange.v1.ExchangeRateR\fexchangeRate\x12\x18\n" +
+	"\acreated\x18\x02 \x01(\bR\acreated\"L\n" +
+	"\x0eConvertRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\"\xf4\x01\n" +
+	"\x0fConvertResponse\x12B\n" +
+	"\rbase_currency\x18\x01 \x01(\v2\x1d.currencyexchange.v1.CurrencyR\fbaseCurrency\x12F\n" +
+	"\x0ftarget_currency\x18\x02 \x01(\v2\x1d.currencyexchange.v1.CurrencyR\x0etargetCurrency\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\tR\x04rate\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12)\n" +
+	"\x10converted_amount\x18\x05 \x01(\tR\x0fconvertedAmount2\xde\x01\n" +
+	"\x0fCurrencyService\x12i\n" +
+	"\x0eListCurrencies\x12*.currencyexchange.v1.ListCurrenciesRequest\x1a+.currencyexchange.v1.ListCurrenciesResponse\x12`\n" +
+	"\vGetCurrency\x12'.currencyexchange.v1.GetCurrencyRequest\x1a(.currencyexchange.v1.GetCurrencyResponse2\xee\x02\n" +
+	"\x13ExchangeRateService\x12r\n" +
+	"\x11ListExchangeRates\x12-.currencyexchange.v1.ListExchangeRatesRequest\x1a..currencyexchange.v1.ListExchangeRatesResponse\x12l\n" +
+	"\x0fGetExchangeRate\x12+.currencyexchange.v1.GetExchangeRateRequest\x1a,.currencyexchange.v1.GetExchangeRateResponse\x12u\n" +
+	"\x12UpsertExchangeRate\x12..currencyexchange.v1.UpsertExchangeRateRequest\x1a/.currencyexchange.v1.UpsertExchangeRateResponse2g\n" +
+	"\x0fExchangeService\x12T\n" +
+	"\aConvert\x12#.currencyexchange.v1.ConvertRequest\x1a$.currencyexchange.v1.ConvertResponseBdZbgithub.com/albakov/go-currency-exchange/internal/grpcapi/pb/currencyexchange/v1;currencyexchangev1b\x06proto3"
+
+var (
+	file_currencyexchange_v1_currency_exchange_proto_rawDescOnce sync.Once
+	file_currencyexchange_v1_currency_exchange_proto_rawDescData []byte
+)
+
+func file_currencyexchange_v1_currency_exchange_proto_rawDescGZIP() []byte {
+	file_currencyexchange_v1_currency_exchange_proto_rawDescOnce.Do(func() {
+		file_currencyexchange_v1_currency_exchange_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_currencyexchange_v1_currency_exchange_proto_rawDesc), len(file_currencyexchange_v1_currency_exchange_proto_rawDesc)))
+	})
+	return file_currencyexchange_v1_currency_exchange_proto_rawDescData
+}
+
+var file_currencyexchange_v1_currency_exchange_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_currencyexchange_v1_currency_exchange_proto_goTypes = []any{
+	(*Currency)(nil),                   // 0: currencyexchange.v1.Currency
+	(*ExchangeRate)(nil),               // 1: currencyexchange.v1.ExchangeRate
+	(*ListCurrenciesRequest)(nil),      // 2: currencyexchange.v1.ListCurrenciesRequest
+	(*ListCurrenciesResponse)(nil),     // 3: currencyexchange.v1.ListCurrenciesResponse
+	(*GetCurrencyRequest)(nil),         // 4: currencyexchange.v1.GetCurrencyRequest
+	(*GetCurrencyResponse)(nil),        // 5: currencyexchange.v1.GetCurrencyResponse
+	(*ListExchangeRatesRequest)(nil),   // 6: currencyexchange.v1.ListExchangeRatesRequest
+	(*ListExchangeRatesResponse)(nil),  // 7: currencyexchange.v1.ListExchangeRatesResponse
+	(*GetExchangeRateRequest)(nil),     // 8: currencyexchange.v1.GetExchangeRateRequest
+	(*GetExchangeRateResponse)(nil),    // 9: currencyexchange.v1.GetExchangeRateResponse
+	(*UpsertExchangeRateRequest)(nil),  // 10: currencyexchange.v1.UpsertExchangeRateRequest
+	(*UpsertExchangeRateResponse)(nil), // 11: currencyexchange.v1.UpsertExchangeRateResponse
+	(*ConvertRequest)(nil),             // 12: currencyexchange.v1.ConvertRequest
+	(*ConvertResponse)(nil),            // 13: currencyexchange.v1.ConvertResponse
+}
+var file_currencyexchange_v1_currency_exchange_proto_depIdxs = []int32{
+	0,  // 0: currencyexchange.v1.ExchangeRate.base_currency:type_name -> currencyexchange.v1.Currency
+	0,  // 1: currencyexchange.v1.ExchangeRate.target_currency:type_name -> currencyexchange.v1.Currency
+	0,  // 2: currencyexchange.v1.ListCurrenciesResponse.currencies:type_name -> currencyexchange.v1.Currency
+	0,  // 3: currencyexchange.v1.GetCurrencyResponse.currency:type_name -> currencyexchange.v1.Currency
+	1,  // 4: currencyexchange.v1.ListExchangeRatesResponse.exchange_rates:type_name -> currencyexchange.v1.ExchangeRate
+	1,  // 5: currencyexchange.v1.GetExchangeRateResponse.exchange_rate:type_name -> currencyexchange.v1.ExchangeRate
+	1,  // 6: currencyexchange.v1.UpsertExchangeRateResponse.exchange_rate:type_name -> currencyexchange.v1.ExchangeRate
+	0,  // 7: currencyexchange.v1.ConvertResponse.base_currency:type_name -> currencyexchange.v1.Currency
+	0,  // 8: currencyexchange.v1.ConvertResponse.target_currency:type_name -> currencyexchange.v1.Currency
+	2,  // 9: currencyexchange.v1.CurrencyService.ListCurrencies:input_type -> currencyexchange.v1.ListCurrenciesRequest
+	4,  // 10: currencyexchange.v1.CurrencyService.GetCurrency:input_type -> currencyexchange.v1.GetCurrencyRequest
+	6,  // 11: currencyexchange.v1.ExchangeRateService.ListExchangeRates:input_type -> currencyexchange.v1.ListExchangeRatesRequest
+	8,  // 12: currencyexchange.v1.ExchangeRateService.GetExchangeRate:input_type -> currencyexchange.v1.GetExchangeRateRequest
+	10, // 13: currencyexchange.v1.ExchangeRateService.UpsertExchangeRate:input_type -> currencyexchange.v1.UpsertExchangeRateRequest
+	12, // 14: currencyexchange.v1.ExchangeService.Convert:input_type -> currencyexchange.v1.ConvertRequest
+	3,  // 15: currencyexchange.v1.CurrencyService.ListCurrencies:output_type -> currencyexchange.v1.ListCurrenciesResponse
+	5,  // 16: currencyexchange.v1.CurrencyService.GetCurrency:output_type -> currencyexchange.v1.GetCurrencyResponse
+	7,  // 17: currencyexchange.v1.ExchangeRateService.ListExchangeRates:output_type -> currencyexchange.v1.ListExchangeRatesResponse
+	9,  // 18: currencyexchange.v1.ExchangeRateService.GetExchangeRate:output_type -> currencyexchange.v1.GetExchangeRateResponse
+	11, // 19: currencyexchange.v1.ExchangeRateService.UpsertExchangeRate:output_type -> currencyexchange.v1.UpsertExchangeRateResponse
+	13, // 20: currencyexchange.v1.ExchangeService.Convert:output_type -> currencyexchange.v1.ConvertResponse
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_currencyexchange_v1_currency_exchange_proto_init() }
+func file_currencyexchange_v1_currency_exchange_proto_init() {
+	if File_currencyexchange_v1_currency_exchange_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_currencyexchange_v1_currency_exchange_proto_rawDesc), len(file_currencyexchange_v1_currency_exchange_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_currencyexchange_v1_currency_exchange_proto_goTypes,
+		DependencyIndexes: file_currencyexchange_v1_currency_exchange_proto_depIdxs,
+		MessageInfos:      file_currencyexchange_v1_currency_exchange_proto_msgTypes,
+	}.Build()
+	File_currencyexchange_v1_currency_exchange_proto = out.File
+	file_currencyexchange_v1_currency_exchange_proto_goTypes = nil
+	file_currencyexchange_v1_currency_exchange_proto_depIdxs = nil
+}
@@ -0,0 +1,686 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	pb "github.com/albakov/go-currency-exchange/internal/grpcapi/pb/currencyexchange/v1"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/requestid"
+	"github.com/albakov/go-currency-exchange/internal/services"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
+	"github.com/albakov/go-currency-exchange/internal/validation"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const f = "grpcapi.Server"
+
+// errorDomain is the domain of the ErrorInfo details, their reason is the error code of the HTTP API.
+const errorDomain = "currency-exchange"
+
+// Server implements the gRPC services on top of the same storages and services as the HTTP controllers.
+type Server struct {
+	pb.UnimplementedCurrencyServiceServer
+	pb.UnimplementedExchangeRateServiceServer
+	pb.UnimplementedExchangeServiceServer
+	storageCurrencies    currencies.StorageCurrencies
+	storageExchangeRates exchangerates.StorageExchangeRates
+	authenticator        *auth.Authenticator
+	limiter              Limiter
+	recorder             *audit.Recorder
+	approval             *approval.Service
+	live                 *config.Live
+	keyedReads           bool
+	catalog              *i18n.Catalog
+}
+
+// Limiter applies the rate limits and daily quotas of the route groups of config.RateLimits.
+type Limiter interface {
+	// Allow returns the code of the error of the limit the call of the client exceeds, empty if it is allowed,
+	// and the time until the client may call again
+	Allow(ctx context.Context, client, group string) (string, time.Duration)
+}
+
+// Route groups of config.RateLimits, limitGroups maps the methods to the groups of their HTTP counterparts,
+// other methods are reads.
+const (
+	limitExchange = "exchange"
+	limitRead     = "read"
+	limitWrite    = "write"
+)
+
+var limitGroups = map[string]string{
+	pb.ExchangeService_Convert_FullMethodName:                limitExchange,
+	pb.ExchangeRateService_UpsertExchangeRate_FullMethodName: limitWrite,
+}
+
+// writeScopes are the scopes of the methods changing data, other methods only read.
+var writeScopes = map[string]string{
+	pb.ExchangeRateService_UpsertExchangeRate_FullMethodName: auth.ScopeRatesWrite,
+}
+
+func New(
+	live *config.Live,
+	storageCurrencies currencies.StorageCurrencies,
+	storageExchangeRates exchangerates.StorageExchangeRates,
+	authenticator *auth.Authenticator,
+	limiter Limiter,
+	recorder *audit.Recorder,
+	approval *approval.Service,
+	catalog *i18n.Catalog,
+) *Server {
+	return &Server{
+		storageCurrencies:    storageCurrencies,
+		storageExchangeRates: storageExchangeRates,
+		authenticator:        authenticator,
+		limiter:              limiter,
+		recorder:             recorder,
+		approval:             approval,
+		live:                 live,
+		keyedReads:           live.Current().KeyedReads,
+		catalog:              catalog,
+	}
+}
+
+// Authorize is the unary interceptor authenticating the API key or the token of the authorization metadata,
+// as "Bearer <credential>", or the API key of the x-api-key metadata. The scopes are required as by the HTTP API.
+func (s *Server) Authorize(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	const op = "Authorize"
+
+	scope, write := writeScopes[info.FullMethod]
+	if !write {
+		scope = auth.ScopeRatesRead
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	credential := auth.Credential(first(md.Get("authorization")), first(md.Get(strings.ToLower(auth.KeyHeader))))
+
+	if credential == "" {
+		if !write && !s.keyedReads {
+			return handler(ctx, req)
+		}
+
+		return nil, s.fail(ctx, codes.Unauthenticated, controller.CodeAPIKeyMissing)
+	}
+
+	principal, err := s.authenticator.Authenticate(credential)
+	if err != nil {
+		if errors.Is(err, auth.InvalidCredentialsError) {
+			return nil, s.fail(ctx, codes.Unauthenticated, controller.CodeAPIKeyInvalid)
+		}
+
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	if !principal.HasScope(scope) {
+		return nil, s.fail(ctx, codes.PermissionDenied, controller.CodeAPIKeyForbidden)
+	}
+
+	return handler(auth.NewContext(ctx, principal, nil), req)
+}
+
+// Limit is the unary interceptor applying the rate limits and daily quotas of the route groups to the client, as
+// the HTTP API does. Calls exceeding them fail with ResourceExhausted and the retry-after metadata in seconds.
+func (s *Server) Limit(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	group, ok := limitGroups[info.FullMethod]
+	if !ok {
+		group = limitRead
+	}
+
+	exceeded, retryAfter := s.limiter.Allow(ctx, clientOf(ctx), group)
+	if exceeded != "" {
+		retryAfterSeconds := strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10)
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfterSeconds))
+
+		return nil, s.fail(ctx, codes.ResourceExhausted, exceeded)
+	}
+
+	return handler(ctx, req)
+}
+
+// clientOf returns the principal subject of the call, or the IP of the peer if it is not authenticated.
+func clientOf(ctx context.Context) string {
+	principal, err := auth.FromContext(ctx)
+	if err == nil {
+		return principal.Subject
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	return "ip:" + host
+}
+
+// Log is the unary interceptor propagating the x-request-id metadata, or generating it, adding a logger carrying it
+// to the context and logging the method, code and latency of the call.
+func (s *Server) Log(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := first(md.Get(strings.ToLower(requestid.Header)))
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestid.Header), id))
+
+	logger := slog.Default().With("requestId", id)
+	ctx = logging.NewContext(requestid.NewContext(ctx, id), logger)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	ctx, span := tracing.StartServer(ctx, info.FullMethod, semconv.RPCSystemGRPC)
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+
+	logger.Log(
+		ctx,
+		level,
+		"call",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latencyMs", float64(time.Since(start).Microseconds())/1000,
+	)
+
+	return resp, err
+}
+
+// metadataCarrier reads the trace context propagated in the metadata of a call.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return first(metadata.MD(c).Get(key))
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// first returns the first of the metadata values, if any.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// Register registers all services of the server on g.
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterCurrencyServiceServer(g, s)
+	pb.RegisterExchangeRateServiceServer(g, s)
+	pb.RegisterExchangeServiceServer(g, s)
+}
+
+func (s *Server) ListCurrencies(ctx context.Context, in *pb.ListCurrenciesRequest) (*pb.ListCurrenciesResponse, error) {
+	const op = "ListCurrencies"
+
+	validated := validation.NewCurrenciesListValues(map[string]string{
+		"limit":  limit(in.GetLimit()),
+		"cursor": in.GetCursor(),
+		"sort":   in.GetSort(),
+		"code":   in.GetCode(),
+		"name":   in.GetName(),
+	})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	filter := currencies.Filter{
+		CodePrefix:  validated.Field("code"),
+		Name:        validated.Field("name"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := s.storageCurrencies.List(ctx, filter)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	localizer := s.localizer(ctx)
+	response := &pb.ListCurrenciesResponse{
+		Currencies: make([]*pb.Currency, len(items)),
+		TotalCount: total,
+		NextCursor: nextCursor(filter.ListOptions, len(items), total),
+	}
+
+	for i, currency := range items {
+		response.Currencies[i] = currencyToPb(localizer, currency)
+	}
+
+	return response, nil
+}
+
+func (s *Server) GetCurrency(ctx context.Context, in *pb.GetCurrencyRequest) (*pb.GetCurrencyResponse, error) {
+	validated := validation.NewValues(
+		map[string]string{"code": in.GetCode()},
+		validation.Field{Name: "code", Rules: []validation.Rule{validation.Required(), validation.ISOCode()}, Upper: true},
+	)
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	currency, err := s.currencyByCode(ctx, validated.Field("code"), controller.CodeCurrencyNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetCurrencyResponse{Currency: currencyToPb(s.localizer(ctx), currency)}, nil
+}
+
+func (s *Server) ListExchangeRates(
+	ctx context.Context,
+	in *pb.ListExchangeRatesRequest,
+) (*pb.ListExchangeRatesResponse, error) {
+	const op = "ListExchangeRates"
+
+	validated := validation.NewExchangeRatesListValues(map[string]string{
+		"limit":  limit(in.GetLimit()),
+		"cursor": in.GetCursor(),
+		"sort":   in.GetSort(),
+		"base":   in.GetBase(),
+		"target": in.GetTarget(),
+	})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	filter := exchangerates.Filter{
+		Base:        validated.Field("base"),
+		Target:      validated.Field("target"),
+		ListOptions: validated.ListOptions(),
+	}
+
+	items, total, err := s.storageExchangeRates.List(ctx, filter)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	localizer := s.localizer(ctx)
+	response := &pb.ListExchangeRatesResponse{
+		ExchangeRates: make([]*pb.ExchangeRate, len(items)),
+		TotalCount:    total,
+		NextCursor:    nextCursor(filter.ListOptions, len(items), total),
+	}
+
+	for i, exchangeRate := range items {
+		response.ExchangeRates[i] = exchangeRateToPb(localizer, exchangeRate)
+	}
+
+	return response, nil
+}
+
+func (s *Server) GetExchangeRate(
+	ctx context.Context,
+	in *pb.GetExchangeRateRequest,
+) (*pb.GetExchangeRateResponse, error) {
+	validated := validation.NewPairValues(map[string]string{
+		"baseCurrencyCode":   in.GetBaseCurrencyCode(),
+		"targetCurrencyCode": in.GetTargetCurrencyCode(),
+	})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	exchangeRate, err := s.exchangeRateByCodes(
+		ctx,
+		validated.Field("baseCurrencyCode"),
+		validated.Field("targetCurrencyCode"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetExchangeRateResponse{ExchangeRate: exchangeRateToPb(s.localizer(ctx), exchangeRate)}, nil
+}
+
+func (s *Server) UpsertExchangeRate(
+	ctx context.Context,
+	in *pb.UpsertExchangeRateRequest,
+) (*pb.UpsertExchangeRateResponse, error) {
+	const op = "UpsertExchangeRate"
+
+	validated := validation.NewExchangeRatesValues(map[string]string{
+		"baseCurrencyCode":   in.GetBaseCurrencyCode(),
+		"targetCurrencyCode": in.GetTargetCurrencyCode(),
+		"rate":               in.GetRate(),
+	})
+	validated.Validate()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	// proposals have no gRPC counterpart, they are made over HTTP
+	if s.approval.Required(validated.Field("baseCurrencyCode"), validated.Field("targetCurrencyCode")) {
+		return nil, s.fail(ctx, codes.FailedPrecondition, controller.CodeRateApprovalRequired)
+	}
+
+	exchangeRate, err := s.exchangeRateByCodes(
+		ctx,
+		validated.Field("baseCurrencyCode"),
+		validated.Field("targetCurrencyCode"),
+	)
+	if err == nil {
+		oldRate := exchangeRate.Rate
+		exchangeRate.Rate = validated.Float("rate")
+		exchangeRate.UpdatedAt = time.Now().UTC()
+
+		err = s.storageExchangeRates.UpdateRate(ctx, exchangeRate)
+		if err != nil {
+			if errors.Is(err, storage.EntityChangedError) {
+				return nil, s.fail(ctx, codes.Aborted, controller.CodeExchangeRatesModified)
+			}
+
+			logging.ErrorContext(ctx, f, op, err)
+
+			return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+		}
+
+		err = s.recorder.RateUpdated(ctx, exchangeRate, oldRate)
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+		}
+
+		return &pb.UpsertExchangeRateResponse{ExchangeRate: exchangeRateToPb(s.localizer(ctx), exchangeRate)}, nil
+	}
+
+	if status.Code(err) != codes.NotFound || exchangeRate.BaseCurrency.ID == 0 {
+		return nil, err
+	}
+
+	// the pair is missing, but both of its currencies were found
+	exchangeRate.Rate = validated.Float("rate")
+	exchangeRate.UpdatedAt = time.Now().UTC()
+
+	exchangeRate.ID, err = s.storageExchangeRates.Add(ctx, exchangeRate)
+	if err != nil {
+		if errors.Is(err, storage.EntityAlreadyExistsError) {
+			return nil, s.fail(ctx, codes.Aborted, controller.CodeExchangeRatesAlreadyExists)
+		}
+
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	err = s.recorder.RateCreated(ctx, exchangeRate)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+	}
+
+	return &pb.UpsertExchangeRateResponse{ExchangeRate: exchangeRateToPb(s.localizer(ctx), exchangeRate), Created: true}, nil
+}
+
+func (s *Server) Convert(ctx context.Context, in *pb.ConvertRequest) (*pb.ConvertResponse, error) {
+	const op = "Convert"
+
+	_, span := tracing.Start(ctx, "validation.Exchange")
+	validated := validation.NewExchangeValues(map[string]string{
+		"from":   in.GetFrom(),
+		"to":     in.GetTo(),
+		"amount": in.GetAmount(),
+	})
+	validated.Validate()
+	span.End()
+
+	if !validated.IsValid() {
+		return nil, s.invalid(ctx, validated)
+	}
+
+	baseCurrency, err := s.currencyByCode(ctx, validated.Field("from"), controller.CodeExchangeRatesCurrencyNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	targetCurrency, err := s.currencyByCode(ctx, validated.Field("to"), controller.CodeExchangeRatesCurrencyNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	exchangeService := services.New(
+		s.storageCurrencies,
+		s.storageExchangeRates,
+		s.live.Current().CrossPivots,
+		baseCurrency.ID,
+		targetCurrency.ID,
+		validated.Float("amount"),
+	)
+
+	rate, err := exchangeService.Rate(ctx)
+	if err != nil {
+		if errors.Is(err, services.NotFoundError) {
+			return nil, s.fail(ctx, codes.NotFound, controller.CodeExchangeRatesPairNotFound)
+		}
+
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	localizer := s.localizer(ctx)
+
+	return &pb.ConvertResponse{
+		BaseCurrency:    currencyToPb(localizer, baseCurrency),
+		TargetCurrency:  currencyToPb(localizer, targetCurrency),
+		Rate:            controller.Decimal(rate),
+		Amount:          controller.Decimal(validated.Float("amount")),
+		ConvertedAmount: controller.Decimal(exchangeService.ConvertedAmount()),
+	}, nil
+}
+
+// currencyByCode returns the currency or a NotFound error with the notFound error code.
+func (s *Server) currencyByCode(ctx context.Context, code, notFound string) (entity.Currency, error) {
+	const op = "currencyByCode"
+
+	currency, err := s.storageCurrencies.ByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			return entity.Currency{}, s.fail(ctx, codes.NotFound, notFound)
+		}
+
+		logging.ErrorContext(ctx, f, op, err)
+
+		return entity.Currency{}, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	return currency, nil
+}
+
+// exchangeRateByCodes returns the exchange rate of the pair. If only the pair is missing, the returned
+// exchange rate holds both currencies along with the NotFound error.
+func (s *Server) exchangeRateByCodes(ctx context.Context, base, target string) (entity.ExchangeRates, error) {
+	const op = "exchangeRateByCodes"
+
+	baseCurrency, err := s.currencyByCode(ctx, base, controller.CodeExchangeRatesCurrencyNotFound)
+	if err != nil {
+		return entity.ExchangeRates{}, err
+	}
+
+	targetCurrency, err := s.currencyByCode(ctx, target, controller.CodeExchangeRatesCurrencyNotFound)
+	if err != nil {
+		return entity.ExchangeRates{}, err
+	}
+
+	exchangeRate, err := s.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(ctx, baseCurrency.ID, targetCurrency.ID)
+	if err != nil {
+		if errors.Is(err, storage.EntitiesNotFoundError) {
+			pair := entity.ExchangeRates{BaseCurrency: baseCurrency, TargetCurrency: targetCurrency}
+
+			return pair, s.fail(ctx, codes.NotFound, controller.CodeExchangeRatesPairNotFound)
+		}
+
+		logging.ErrorContext(ctx, f, op, err)
+
+		return entity.ExchangeRates{}, s.fail(ctx, codes.Internal, controller.CodeServerError)
+	}
+
+	return exchangeRate, nil
+}
+
+// fail returns the gRPC error carrying the localized message of the error code.
+func (s *Server) fail(ctx context.Context, c codes.Code, code string) error {
+	st := status.New(c, s.localizer(ctx).Message(i18n.NewMessage(code)))
+
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: errorDomain})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// invalid returns the InvalidArgument error listing the errors of all fields.
+func (s *Server) invalid(ctx context.Context, validated *validation.Validator) error {
+	localizer := s.localizer(ctx)
+	st := status.New(codes.InvalidArgument, localizer.Message(validated.ErrorMessage()))
+
+	badRequest := &errdetails.BadRequest{}
+
+	for _, fe := range validated.Errors() {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: localizer.Message(fe.Message),
+		})
+	}
+
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: validated.Code(), Domain: errorDomain}, badRequest)
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// localizer returns the localizer of the language asked for in the accept-language metadata.
+func (s *Server) localizer(ctx context.Context) *i18n.Localizer {
+	tags := []string{}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("accept-language") {
+		tags = append(tags, i18n.ParseAcceptLanguage(value)...)
+	}
+
+	return s.catalog.ForLanguages(tags...)
+}
+
+// currencyToPb converts the currency, its name is translated only if the client asked for a language.
+func currencyToPb(localizer *i18n.Localizer, currency entity.Currency) *pb.Currency {
+	fullName := currency.FullName
+	if localizer.Requested() {
+		fullName = localizer.CurrencyName(currency.Code, currency.FullName)
+	}
+
+	return &pb.Currency{
+		Id:       currency.ID,
+		Code:     currency.Code,
+		FullName: fullName,
+		Sign:     currency.Sign,
+	}
+}
+
+func exchangeRateToPb(localizer *i18n.Localizer, exchangeRate entity.ExchangeRates) *pb.ExchangeRate {
+	return &pb.ExchangeRate{
+		Id:             exchangeRate.ID,
+		BaseCurrency:   currencyToPb(localizer, exchangeRate.BaseCurrency),
+		TargetCurrency: currencyToPb(localizer, exchangeRate.TargetCurrency),
+		Rate:           controller.Decimal(exchangeRate.Rate),
+		UpdatedAt:      exchangeRate.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+func nextCursor(options storage.ListOptions, count int, total int64) string {
+	next := options.NextOffset(count, total)
+	if next < 0 {
+		return ""
+	}
+
+	return storage.EncodeCursor(next)
+}
+
+// limit converts the page size into a validated value, 0 stands for no limit.
+func limit(value int64) string {
+	if value == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,421 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/approval"
+	"github.com/albakov/go-currency-exchange/internal/audit"
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	pb "github.com/albakov/go-currency-exchange/internal/grpcapi/pb/currencyexchange/v1"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"github.com/albakov/go-currency-exchange/internal/storage/apikeys"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateaudit"
+	"github.com/albakov/go-currency-exchange/internal/storage/rateproposals"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// limiter is a Limiter stub rejecting the calls of the groups set.
+type limiter struct {
+	mu       sync.Mutex
+	exceeded map[string]string
+}
+
+func (l *limiter) Allow(_ context.Context, _, group string) (string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.exceeded[group], 1500 * time.Millisecond
+}
+
+func (l *limiter) exceed(group, code string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.exceeded[group] = code
+}
+
+// fixture is a client of a server over an in-memory connection, with USD, EUR and GBP and the USD/EUR rate stored.
+type fixture struct {
+	conn     *grpc.ClientConn
+	limiter  *limiter
+	readKey  string
+	writeKey string
+}
+
+func setup(t *testing.T, c *config.Config) fixture {
+	t.Helper()
+
+	c.PathToDB = filepath.Join(t.TempDir(), "sqlite.db")
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currencies := storageCurrencies.New(c.PathToDB, nil, nil)
+	exchangeRates := storageExchangeRates.New(c.PathToDB, nil, nil)
+	authenticator := auth.New(apikeys.New(c.PathToDB), nil)
+	recorder := audit.New(rateaudit.New(c.PathToDB))
+
+	ids := map[string]entity.Currency{}
+	for _, currency := range []entity.Currency{
+		{Code: "USD", FullName: "US Dollar", Sign: "$"},
+		{Code: "EUR", FullName: "Euro", Sign: "€"},
+		{Code: "GBP", FullName: "Pound Sterling", Sign: "£"},
+	} {
+		currency.ID, err = currencies.Add(context.Background(), currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[currency.Code] = currency
+	}
+
+	_, err = exchangeRates.Add(context.Background(), entity.ExchangeRates{
+		BaseCurrency:   ids["USD"],
+		TargetCurrency: ids["EUR"],
+		Rate:           0.9,
+		UpdatedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readKey, err := authenticator.Issue("reader", []string{auth.ScopeRatesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKey, err := authenticator.Issue("writer", []string{auth.ScopeRatesRead, auth.ScopeRatesWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &limiter{exceeded: map[string]string{}}
+	s := New(
+		config.NewLive(c),
+		currencies,
+		exchangeRates,
+		authenticator,
+		stub,
+		recorder,
+		approval.New(c, rateproposals.New(c.PathToDB), currencies, exchangeRates, recorder),
+		i18n.MustNew("en"),
+	)
+
+	listener := bufconn.Listen(1 << 20)
+	g := grpc.NewServer(grpc.ChainUnaryInterceptor(s.Log, s.Authorize, s.Limit))
+	s.Register(g)
+
+	go func() {
+		_ = g.Serve(listener)
+	}()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return fixture{conn: conn, limiter: stub, readKey: readKey.Key, writeKey: writeKey.Key}
+}
+
+// withKey returns the context of a call authenticated by the key.
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+// reason returns the code and the error code of the ErrorInfo details of err.
+func reason(t *testing.T, err error) (codes.Code, string) {
+	t.Helper()
+
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.Domain != errorDomain {
+				t.Errorf("error domain %q, want %q", info.Domain, errorDomain)
+			}
+
+			return st.Code(), info.Reason
+		}
+	}
+
+	t.Errorf("error %v has no ErrorInfo details", err)
+
+	return st.Code(), ""
+}
+
+func TestConvertReturnsDecimals(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	client := pb.NewExchangeServiceClient(fx.conn)
+
+	var header metadata.MD
+
+	response, err := client.Convert(
+		context.Background(),
+		&pb.ConvertRequest{From: "usd", To: "EUR", Amount: "10000000"},
+		grpc.Header(&header),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Rate != "0.9" || response.Amount != "10000000" || response.ConvertedAmount != "9000000" {
+		t.Errorf("rate %s, amount %s, converted %s, want decimals without exponent",
+			response.Rate, response.Amount, response.ConvertedAmount)
+	}
+
+	if response.BaseCurrency.GetCode() != "USD" || response.TargetCurrency.GetCode() != "EUR" {
+		t.Errorf("currencies %v and %v, want USD and EUR", response.BaseCurrency, response.TargetCurrency)
+	}
+
+	if len(header.Get("x-request-id")) != 1 {
+		t.Errorf("header %v, want the request id", header)
+	}
+
+	// the inverse rate is derived from the stored one
+	response, err = client.Convert(context.Background(), &pb.ConvertRequest{From: "EUR", To: "USD", Amount: "9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ConvertedAmount != "10" {
+		t.Errorf("converted %s, want 10", response.ConvertedAmount)
+	}
+
+	_, err = client.Convert(context.Background(), &pb.ConvertRequest{From: "USD", To: "GBP", Amount: "1"})
+	if c, code := reason(t, err); c != codes.NotFound || code != controller.CodeExchangeRatesPairNotFound {
+		t.Errorf("pair without rate: %s %s, want NotFound %s", c, code, controller.CodeExchangeRatesPairNotFound)
+	}
+
+	_, err = client.Convert(context.Background(), &pb.ConvertRequest{From: "USD", To: "XXX", Amount: "1"})
+	if c, code := reason(t, err); c != codes.NotFound || code != controller.CodeExchangeRatesCurrencyNotFound {
+		t.Errorf("unknown currency: %s %s, want NotFound %s", c, code, controller.CodeExchangeRatesCurrencyNotFound)
+	}
+}
+
+func TestConvertRejectsInvalidArguments(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	client := pb.NewExchangeServiceClient(fx.conn)
+
+	_, err := client.Convert(context.Background(), &pb.ConvertRequest{From: "US", To: "EUR", Amount: "-1"})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code %s, want InvalidArgument", st.Code())
+	}
+
+	fields := map[string]bool{}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields[violation.Field] = violation.Description != ""
+			}
+		}
+	}
+
+	if !fields["from"] || !fields["amount"] || len(fields) != 2 {
+		t.Errorf("field violations %v, want from and amount described", fields)
+	}
+}
+
+func TestGetExchangeRate(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	client := pb.NewExchangeRateServiceClient(fx.conn)
+
+	response, err := client.GetExchangeRate(
+		metadata.AppendToOutgoingContext(context.Background(), "accept-language", "ru"),
+		&pb.GetExchangeRateRequest{BaseCurrencyCode: "usd", TargetCurrencyCode: "eur"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rate := response.ExchangeRate
+	if rate.Rate != "0.9" || rate.BaseCurrency.GetCode() != "USD" || rate.TargetCurrency.GetCode() != "EUR" {
+		t.Errorf("exchange rate %v, want USD/EUR at 0.9", rate)
+	}
+
+	if rate.BaseCurrency.GetFullName() == "US Dollar" {
+		t.Error("name of the currency not translated to the language asked for")
+	}
+
+	_, err = time.Parse(time.RFC3339Nano, rate.UpdatedAt)
+	if err != nil {
+		t.Errorf("updated at %q: %v", rate.UpdatedAt, err)
+	}
+
+	_, err = client.GetExchangeRate(
+		context.Background(),
+		&pb.GetExchangeRateRequest{BaseCurrencyCode: "USD", TargetCurrencyCode: "GBP"},
+	)
+	if c, code := reason(t, err); c != codes.NotFound || code != controller.CodeExchangeRatesPairNotFound {
+		t.Errorf("missing pair: %s %s, want NotFound %s", c, code, controller.CodeExchangeRatesPairNotFound)
+	}
+}
+
+func TestUpsertExchangeRateRequiresWriteScope(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	client := pb.NewExchangeRateServiceClient(fx.conn)
+	request := &pb.UpsertExchangeRateRequest{BaseCurrencyCode: "USD", TargetCurrencyCode: "GBP", Rate: "0.79"}
+
+	_, err := client.UpsertExchangeRate(context.Background(), request)
+	if c, code := reason(t, err); c != codes.Unauthenticated || code != controller.CodeAPIKeyMissing {
+		t.Errorf("without key: %s %s, want Unauthenticated %s", c, code, controller.CodeAPIKeyMissing)
+	}
+
+	_, err = client.UpsertExchangeRate(withKey("ce_unknown"), request)
+	if c, code := reason(t, err); c != codes.Unauthenticated || code != controller.CodeAPIKeyInvalid {
+		t.Errorf("unknown key: %s %s, want Unauthenticated %s", c, code, controller.CodeAPIKeyInvalid)
+	}
+
+	_, err = client.UpsertExchangeRate(withKey(fx.readKey), request)
+	if c, code := reason(t, err); c != codes.PermissionDenied || code != controller.CodeAPIKeyForbidden {
+		t.Errorf("read key: %s %s, want PermissionDenied %s", c, code, controller.CodeAPIKeyForbidden)
+	}
+
+	response, err := client.UpsertExchangeRate(withKey(fx.writeKey), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Created || response.ExchangeRate.Rate != "0.79" {
+		t.Errorf("response %v, want the rate created", response)
+	}
+
+	request.Rate = "0.8"
+
+	response, err = client.UpsertExchangeRate(withKey(fx.writeKey), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Created || response.ExchangeRate.Rate != "0.8" {
+		t.Errorf("response %v, want the rate updated", response)
+	}
+}
+
+func TestUpsertExchangeRateOfApprovedPairFails(t *testing.T) {
+	fx := setup(t, &config.Config{Approval: config.Approval{RateApprovalPairs: []string{"usdeur"}}})
+	client := pb.NewExchangeRateServiceClient(fx.conn)
+
+	_, err := client.UpsertExchangeRate(
+		withKey(fx.writeKey),
+		&pb.UpsertExchangeRateRequest{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: "0.9"},
+	)
+	if c, code := reason(t, err); c != codes.FailedPrecondition || code != controller.CodeRateApprovalRequired {
+		t.Errorf("%s %s, want FailedPrecondition %s", c, code, controller.CodeRateApprovalRequired)
+	}
+
+	// reads of the pair are not affected
+	_, err = client.GetExchangeRate(
+		context.Background(),
+		&pb.GetExchangeRateRequest{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR"},
+	)
+	if err != nil {
+		t.Errorf("read of a pair needing approval: %v", err)
+	}
+}
+
+func TestKeyedReadsRequireKey(t *testing.T) {
+	fx := setup(t, &config.Config{KeyedReads: true})
+	client := pb.NewCurrencyServiceClient(fx.conn)
+
+	_, err := client.GetCurrency(context.Background(), &pb.GetCurrencyRequest{Code: "USD"})
+	if c, code := reason(t, err); c != codes.Unauthenticated || code != controller.CodeAPIKeyMissing {
+		t.Errorf("without key: %s %s, want Unauthenticated %s", c, code, controller.CodeAPIKeyMissing)
+	}
+
+	response, err := client.GetCurrency(withKey(fx.readKey), &pb.GetCurrencyRequest{Code: "usd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Currency.GetCode() != "USD" || response.Currency.GetSign() != "$" {
+		t.Errorf("currency %v, want USD", response.Currency)
+	}
+
+	_, err = client.GetCurrency(withKey(fx.readKey), &pb.GetCurrencyRequest{Code: "XXX"})
+	if c, code := reason(t, err); c != codes.NotFound || code != controller.CodeCurrencyNotFound {
+		t.Errorf("unknown currency: %s %s, want NotFound %s", c, code, controller.CodeCurrencyNotFound)
+	}
+}
+
+func TestLimitExceededFails(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	fx.limiter.exceed(limitExchange, controller.CodeQuotaExceeded)
+
+	var header metadata.MD
+
+	_, err := pb.NewExchangeServiceClient(fx.conn).Convert(
+		context.Background(),
+		&pb.ConvertRequest{From: "USD", To: "EUR", Amount: "1"},
+		grpc.Header(&header),
+	)
+	if c, code := reason(t, err); c != codes.ResourceExhausted || code != controller.CodeQuotaExceeded {
+		t.Errorf("%s %s, want ResourceExhausted %s", c, code, controller.CodeQuotaExceeded)
+	}
+
+	if retryAfter := header.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] != "2" {
+		t.Errorf("retry-after %v, want the seconds rounded up", retryAfter)
+	}
+
+	// the limits of other groups are not affected
+	_, err = pb.NewCurrencyServiceClient(fx.conn).ListCurrencies(context.Background(), &pb.ListCurrenciesRequest{})
+	if err != nil {
+		t.Errorf("call of another group: %v", err)
+	}
+}
+
+func TestListCurrenciesPages(t *testing.T) {
+	fx := setup(t, &config.Config{})
+	client := pb.NewCurrencyServiceClient(fx.conn)
+
+	response, err := client.ListCurrencies(context.Background(), &pb.ListCurrenciesRequest{Limit: 2, Sort: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Currencies) != 2 || response.TotalCount != 3 || response.NextCursor == "" {
+		t.Fatalf("response %v, want the first page of 3 currencies", response)
+	}
+
+	if response.Currencies[0].Code != "EUR" || response.Currencies[1].Code != "GBP" {
+		t.Errorf("currencies %v, want sorted by code", response.Currencies)
+	}
+
+	response, err = client.ListCurrencies(
+		context.Background(),
+		&pb.ListCurrenciesRequest{Limit: 2, Sort: "code", Cursor: response.NextCursor},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Currencies) != 1 || response.Currencies[0].Code != "USD" || response.NextCursor != "" {
+		t.Errorf("response %v, want the last page", response)
+	}
+}
@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of the checks of the readiness.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker tells whether the service is ready to receive traffic. It is not while it shuts down or while it is in
+// maintenance mode, or if the database is unreachable, not migrated or holds stale rates.
+type Checker struct {
+	pathToDb             string
+	maxRateAge           time.Duration
+	storageExchangeRates exchangerates.StorageExchangeRates
+	maintenance          atomic.Bool
+	shuttingDown         atomic.Bool
+}
+
+// Report is the result of the readiness checks, Checks maps the names of the checks to their status or failure.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func New(config *config.Config, storageExchangeRates exchangerates.StorageExchangeRates) *Checker {
+	c := &Checker{
+		pathToDb:             config.PathToDB,
+		maxRateAge:           time.Duration(config.ReadinessMaxRateAge) * time.Second,
+		storageExchangeRates: storageExchangeRates,
+	}
+	c.maintenance.Store(config.Maintenance)
+
+	return c
+}
+
+// SetMaintenance switches the maintenance mode on or off.
+func (c *Checker) SetMaintenance(on bool) {
+	c.maintenance.Store(on)
+}
+
+// Maintenance reports whether the maintenance mode is on.
+func (c *Checker) Maintenance() bool {
+	return c.maintenance.Load()
+}
+
+// ShutDown makes the service not ready for good, so no new traffic is routed to it while it drains.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// SchemaVersion returns the version of the schema of the database.
+func (c *Checker) SchemaVersion(ctx context.Context) (int, error) {
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		return 0, err
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	return dbinit.Version(ctx, db)
+}
+
+// Ready runs the checks of the readiness.
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{
+		Ready:  true,
+		Checks: map[string]string{},
+	}
+
+	check := func(name string, err error) {
+		if err != nil {
+			report.Ready = false
+			report.Checks[name] = StatusFail + ": " + err.Error()
+
+			return
+		}
+
+		report.Checks[name] = StatusOK
+	}
+
+	check("serving", c.serving())
+	check("database", c.database(ctx))
+	check("rates", c.rates(ctx))
+
+	return report
+}
+
+func (c *Checker) serving() error {
+	if c.shuttingDown.Load() {
+		return errors.New("shutting down")
+	}
+
+	if c.maintenance.Load() {
+		return errors.New("maintenance mode")
+	}
+
+	return nil
+}
+
+// database checks that the file of the database is reachable and all migrations are applied to it.
+func (c *Checker) database(ctx context.Context) error {
+	_, err := os.Stat(c.pathToDb)
+	if err != nil {
+		return err
+	}
+
+	version, err := c.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if version < dbinit.SchemaVersion() {
+		return fmt.Errorf("schema version %d, %d expected", version, dbinit.SchemaVersion())
+	}
+
+	return nil
+}
+
+// rates checks that a rate was updated within the configured age, if any, unless there are no rates yet.
+func (c *Checker) rates(ctx context.Context) error {
+	if c.maxRateAge <= 0 {
+		return nil
+	}
+
+	latest, _, err := c.storageExchangeRates.List(ctx, exchangerates.Filter{
+		ListOptions: storage.ListOptions{Limit: 1, Sort: "updated", Desc: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(latest) == 0 {
+		return nil
+	}
+
+	age := time.Since(latest[0].UpdatedAt)
+	if age > c.maxRateAge {
+		return fmt.Errorf("last rate update %s ago", age.Truncate(time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addRate stores a rate updated age ago.
+func addRate(t *testing.T, pathToDb string, age time.Duration) {
+	t.Helper()
+
+	storageCurrencies := currencies.New(pathToDb, nil, nil)
+	ids := []int64{}
+
+	for _, code := range []string{"USD", "EUR"} {
+		id, err := storageCurrencies.Add(context.Background(), entity.Currency{Code: code, FullName: code, Sign: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	_, err := exchangerates.New(pathToDb, nil, nil).Add(context.Background(), entity.ExchangeRates{
+		BaseCurrency:   entity.Currency{ID: ids[0]},
+		TargetCurrency: entity.Currency{ID: ids[1]},
+		Rate:           0.9,
+		UpdatedAt:      time.Now().Add(-age),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRateAge int64
+		prepare    func(t *testing.T, pathToDb string, c *Checker)
+		// want maps the checks to their status, without the reason of a failure
+		want map[string]string
+	}{
+		{
+			name: "ready",
+			want: map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name:    "maintenance",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) { c.SetMaintenance(true) },
+			want:    map[string]string{"serving": StatusFail, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name: "maintenance over",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) {
+				c.SetMaintenance(true)
+				c.SetMaintenance(false)
+			},
+			want: map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name:    "shutting down",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) { c.ShutDown() },
+			want:    map[string]string{"serving": StatusFail, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name: "database missing",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) {
+				err := os.Remove(pathToDb)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"serving": StatusOK, "database": StatusFail, "rates": StatusOK},
+		},
+		{
+			name: "migration missing",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) {
+				db, err := sql.Open("sqlite3", pathToDb)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer db.Close()
+
+				_, err = db.Exec("PRAGMA user_version = 1")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"serving": StatusOK, "database": StatusFail, "rates": StatusOK},
+		},
+		{
+			name:       "no rates yet",
+			maxRateAge: 60,
+			want:       map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name:       "fresh rates",
+			maxRateAge: 60,
+			prepare:    func(t *testing.T, pathToDb string, c *Checker) { addRate(t, pathToDb, time.Second) },
+			want:       map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusOK},
+		},
+		{
+			name:       "stale rates",
+			maxRateAge: 60,
+			prepare:    func(t *testing.T, pathToDb string, c *Checker) { addRate(t, pathToDb, time.Hour) },
+			want:       map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusFail},
+		},
+		{
+			name:    "stale rates not checked",
+			prepare: func(t *testing.T, pathToDb string, c *Checker) { addRate(t, pathToDb, time.Hour) },
+			want:    map[string]string{"serving": StatusOK, "database": StatusOK, "rates": StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db"), ReadinessMaxRateAge: tt.maxRateAge}
+
+			err := dbinit.New(cfg).CreateDatabaseIfNotExists()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := New(cfg, exchangerates.New(cfg.PathToDB, nil, nil))
+			if tt.prepare != nil {
+				tt.prepare(t, cfg.PathToDB, c)
+			}
+
+			report := c.Ready(context.Background())
+
+			ready := true
+
+			for name, status := range tt.want {
+				got, _, _ := strings.Cut(report.Checks[name], ":")
+				if got != status {
+					t.Errorf("check %s %q, want %s", name, report.Checks[name], status)
+				}
+
+				ready = ready && status == StatusOK
+			}
+
+			if report.Ready != ready {
+				t.Errorf("ready %v, want %v", report.Ready, ready)
+			}
+		})
+	}
+}
+
+func TestNewMaintenance(t *testing.T) {
+	c := New(&config.Config{Maintenance: true}, nil)
+
+	if !c.Maintenance() {
+		t.Error("service started out of the configured maintenance mode")
+	}
+}
@@ -0,0 +1,215 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// locales holds one bundle per language, named by its language tag, e.g. en.toml.
+//
+//go:embed locales/*.toml
+var locales embed.FS
+
+// Message is a catalog key with the arguments of its template.
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// NewMessage returns the message with the given key and template arguments.
+func NewMessage(key string, args ...interface{}) Message {
+	return Message{Key: key, Args: args}
+}
+
+// bundle holds the strings of a single language.
+type bundle struct {
+	Name       string            `toml:"name"`
+	Titles     map[string]string `toml:"titles"`
+	Messages   map[string]string `toml:"messages"`
+	Currencies map[string]string `toml:"currencies"`
+}
+
+// Catalog holds the bundles of all supported languages.
+type Catalog struct {
+	bundles  map[string]*bundle
+	fallback string
+}
+
+// New loads the embedded bundles, fallback is the language used when the client asks for none of them.
+func New(fallback string) (*Catalog, error) {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{bundles: map[string]*bundle{}, fallback: fallback}
+
+	for _, file := range files {
+		b := &bundle{}
+
+		_, err := toml.DecodeFS(locales, path.Join("locales", file.Name()), b)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %v", file.Name(), err)
+		}
+
+		c.bundles[strings.TrimSuffix(file.Name(), ".toml")] = b
+	}
+
+	if _, ok := c.bundles[fallback]; !ok {
+		return nil, fmt.Errorf("fallback language %q is not supported", fallback)
+	}
+
+	return c, nil
+}
+
+func MustNew(fallback string) *Catalog {
+	c, err := New(fallback)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Localizer returns the localizer for the language chosen by the lang query parameter
+// or, if there is none, by the Accept-Language header.
+func (c *Catalog) Localizer(r *http.Request) *Localizer {
+	tags := append([]string{r.URL.Query().Get("lang")}, parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+
+	return c.ForLanguages(tags...)
+}
+
+// ForLanguages returns the localizer for the first supported language of the tags,
+// ordered by preference, or for the fallback language.
+func (c *Catalog) ForLanguages(tags ...string) *Localizer {
+	for _, tag := range tags {
+		if language := c.match(tag); language != "" {
+			return &Localizer{catalog: c, language: language, requested: true}
+		}
+	}
+
+	return &Localizer{catalog: c, language: c.fallback}
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language value ordered by their quality.
+func ParseAcceptLanguage(header string) []string {
+	return parseAcceptLanguage(header)
+}
+
+// match returns the supported language matching tag by its primary subtag.
+func (c *Catalog) match(tag string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	if _, ok := c.bundles[primary]; ok {
+		return primary
+	}
+
+	return ""
+}
+
+// Localizer translates strings into a single language.
+type Localizer struct {
+	catalog   *Catalog
+	language  string
+	requested bool
+}
+
+// Language returns the language tag of the localizer.
+func (l *Localizer) Language() string {
+	return l.language
+}
+
+// Requested reports whether the language was asked for by the client rather than being the fallback.
+func (l *Localizer) Requested() bool {
+	return l.requested
+}
+
+// Message returns the formatted message, falling back to the fallback language and then to the key itself.
+func (l *Localizer) Message(m Message) string {
+	template, ok := l.lookup(func(b *bundle) map[string]string { return b.Messages }, m.Key)
+	if !ok {
+		template = m.Key
+	}
+
+	if len(m.Args) == 0 {
+		return template
+	}
+
+	return fmt.Sprintf(template, m.Args...)
+}
+
+// Title returns the title of the error code.
+func (l *Localizer) Title(code string) string {
+	title, _ := l.lookup(func(b *bundle) map[string]string { return b.Titles }, code)
+
+	return title
+}
+
+// CurrencyName returns the name of the currency in the localizer language, or name if there is no translation.
+func (l *Localizer) CurrencyName(code, name string) string {
+	translated, ok := l.catalog.bundles[l.language].Currencies[code]
+	if !ok {
+		return name
+	}
+
+	return translated
+}
+
+func (l *Localizer) lookup(section func(b *bundle) map[string]string, key string) (string, bool) {
+	for _, language := range []string{l.language, l.catalog.fallback} {
+		value, ok := section(l.catalog.bundles[language])[key]
+		if ok {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// parseAcceptLanguage returns the language tags of the header ordered by their quality.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	tags := []weighted{}
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalizer(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		language       string
+		requested      bool
+	}{
+		{name: "nothing asked", language: "en"},
+		{name: "header", acceptLanguage: "ru", language: "ru", requested: true},
+		{name: "region of the language", acceptLanguage: "ru-RU", language: "ru", requested: true},
+		{name: "quality order", acceptLanguage: "en;q=0.5, ru;q=0.9", language: "ru", requested: true},
+		{name: "first supported language", acceptLanguage: "de, fr;q=0.9, ru;q=0.8", language: "ru", requested: true},
+		{name: "excluded language", acceptLanguage: "ru;q=0, en;q=0.1", language: "en", requested: true},
+		{name: "unsupported languages", acceptLanguage: "de, *", language: "en"},
+		{name: "parameter", query: "lang=ru", language: "ru", requested: true},
+		{name: "parameter over header", query: "lang=en", acceptLanguage: "ru", language: "en", requested: true},
+		{name: "unsupported parameter", query: "lang=de", acceptLanguage: "ru", language: "ru", requested: true},
+	}
+
+	catalog := MustNew("en")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/currencies?"+tt.query, nil)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			localizer := catalog.Localizer(r)
+			if localizer.Language() != tt.language || localizer.Requested() != tt.requested {
+				t.Errorf(
+					"language %q, requested %v, want %q, %v",
+					localizer.Language(), localizer.Requested(), tt.language, tt.requested,
+				)
+			}
+		})
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	catalog := MustNew("en")
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "message", got: catalog.ForLanguages("ru").Message(NewMessage("currency-not-found")), want: "Валюта не найдена"},
+		{name: "arguments", got: catalog.ForLanguages("ru").Message(NewMessage("field-empty", "code")), want: "Отсутствует нужное поле: code"},
+		{name: "unknown key", got: catalog.ForLanguages("ru").Message(NewMessage("no-such-message")), want: "no-such-message"},
+		{name: "title", got: catalog.ForLanguages("en").Title("currency-not-found"), want: "Currency not found"},
+		{name: "currency name", got: catalog.ForLanguages("ru").CurrencyName("USD", "US Dollar"), want: "Доллар США"},
+		{name: "currency without translation", got: catalog.ForLanguages("ru").CurrencyName("XTS", "Test"), want: "Test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundlesHaveSameKeys(t *testing.T) {
+	catalog := MustNew("en")
+
+	sections := map[string]func(b *bundle) map[string]string{
+		"titles":     func(b *bundle) map[string]string { return b.Titles },
+		"messages":   func(b *bundle) map[string]string { return b.Messages },
+		"currencies": func(b *bundle) map[string]string { return b.Currencies },
+	}
+
+	for name, section := range sections {
+		want := keys(section(catalog.bundles["en"]))
+
+		for language, b := range catalog.bundles {
+			if got := keys(section(b)); got != want {
+				t.Errorf("%s of %s: %s, want %s", name, language, got, want)
+			}
+		}
+	}
+}
+
+func TestNewUnsupportedFallback(t *testing.T) {
+	_, err := New("de")
+	if err == nil {
+		t.Error("catalog without the fallback language loaded")
+	}
+}
+
+func keys(m map[string]string) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"os"
+)
+
+// level is the level of the default logger, it may be changed while the application runs.
+var level = new(slog.LevelVar)
+
+type contextKey struct{}
+
+// Setup makes the default logger write JSON lines to stderr from the level named as debug, info, warn or error.
+func Setup(name string) error {
+	err := SetLevel(name)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	return nil
+}
+
+// SetLevel changes the level of the default logger.
+func SetLevel(name string) error {
+	var l slog.Level
+
+	err := l.UnmarshalText([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	level.Set(l)
+
+	return nil
+}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(contextKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// Error logs the failure of the operation op of the component.
+func Error(component, op string, err error) {
+	ErrorContext(context.Background(), component, op, err)
+}
+
+// ErrorContext logs the failure of the operation op of the component with the logger carried by ctx, and records
+// it on the span of ctx.
+func ErrorContext(ctx context.Context, component, op string, err error) {
+	FromContext(ctx).ErrorContext(ctx, "operation failed", "component", component, "op", op, "error", err)
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
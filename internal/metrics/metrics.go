@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const f = "metrics"
+
+const namespace = "currency_exchange"
+
+// Resolution paths of a conversion, the rate of the pair, the inverse of the reverse pair or the cross rate
+// via a configured pivot.
+const (
+	PathDirect   = "direct"
+	PathReverse  = "reverse"
+	PathCross    = "cross"
+	PathNotFound = "not-found"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route and status.",
+	}, []string{"route", "method", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_query_duration_seconds",
+		Help:      "Latency of storage methods.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"storage", "method"})
+	conversions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "conversions_total",
+		Help:      "Number of conversions by the path the rate was resolved by.",
+	}, []string{"path"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests,
+		requestDuration,
+		queryDuration,
+		conversions,
+	)
+
+	for _, path := range []string{PathDirect, PathReverse, PathCross, PathNotFound} {
+		conversions.WithLabelValues(path)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest counts the HTTP request served by the route since start.
+func ObserveRequest(route, method string, status int, start time.Time) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+
+	requests.With(labels).Inc()
+	requestDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQuery records the latency of the method of the storage called at start, it is meant to be deferred.
+func ObserveQuery(storage, method string, start time.Time) {
+	queryDuration.WithLabelValues(storage, method).Observe(time.Since(start).Seconds())
+}
+
+// CountConversion counts a conversion resolved by the path.
+func CountConversion(path string) {
+	conversions.WithLabelValues(path).Inc()
+}
+
+// Inventory holds the figures of the stored data, OldestRate is the zero time if there are no rates.
+type Inventory struct {
+	Currencies int64
+	Pairs      int64
+	OldestRate time.Time
+}
+
+// RegisterInventory adds the gauges of the number of currencies and pairs and of the age of the oldest rate,
+// read is called at every scrape.
+func RegisterInventory(read func() (Inventory, error)) {
+	registry.MustRegister(&inventoryCollector{read: read})
+}
+
+var (
+	currenciesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "currencies"),
+		"Number of currencies.",
+		nil,
+		nil,
+	)
+	pairsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "exchange_rates"),
+		"Number of currency pairs with an exchange rate.",
+		nil,
+		nil,
+	)
+	oldestRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "oldest_rate_age_seconds"),
+		"Seconds since the least recently updated exchange rate was updated, 0 if there are none.",
+		nil,
+		nil,
+	)
+)
+
+// inventoryCollector collects the gauges describing the stored data.
+type inventoryCollector struct {
+	read func() (Inventory, error)
+}
+
+func (c *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- currenciesDesc
+	ch <- pairsDesc
+	ch <- oldestRateDesc
+}
+
+func (c *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	const op = "Collect"
+
+	inventory, err := c.read()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return
+	}
+
+	age := 0.0
+	if !inventory.OldestRate.IsZero() {
+		age = time.Since(inventory.OldestRate).Seconds()
+	}
+
+	ch <- prometheus.MustNewConstMetric(currenciesDesc, prometheus.GaugeValue, float64(inventory.Currencies))
+	ch <- prometheus.MustNewConstMetric(pairsDesc, prometheus.GaugeValue, float64(inventory.Pairs))
+	ch <- prometheus.MustNewConstMetric(oldestRateDesc, prometheus.GaugeValue, age)
+}
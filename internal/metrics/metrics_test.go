@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	RegisterInventory(func() (Inventory, error) {
+		return Inventory{Currencies: 3, Pairs: 2, OldestRate: time.Now().Add(-time.Hour)}, nil
+	})
+
+	ObserveRequest("/v2/currencies", http.MethodGet, http.StatusOK, time.Now())
+	ObserveRequest("/v2/currencies", http.MethodGet, http.StatusOK, time.Now())
+	ObserveRequest("/v2/currency/{code}", http.MethodGet, http.StatusNotFound, time.Now())
+	ObserveQuery("StorageCurrencies", "List", time.Now())
+	CountConversion(PathCross)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		series string
+	}{
+		{name: "requests of a route", series: `currency_exchange_http_requests_total{method="GET",route="/v2/currencies",status="200"} 2`},
+		{name: "requests by status", series: `currency_exchange_http_requests_total{method="GET",route="/v2/currency/{code}",status="404"} 1`},
+		{name: "request latency", series: `currency_exchange_http_request_duration_seconds_count{method="GET",route="/v2/currencies",status="200"} 2`},
+		{name: "query latency", series: `currency_exchange_storage_query_duration_seconds_count{method="List",storage="StorageCurrencies"} 1`},
+		{name: "conversions of a path", series: `currency_exchange_conversions_total{path="cross"} 1`},
+		{name: "path without conversions", series: `currency_exchange_conversions_total{path="not-found"} 0`},
+		{name: "currencies", series: "currency_exchange_currencies 3"},
+		{name: "pairs", series: "currency_exchange_exchange_rates 2"},
+		{name: "oldest rate age", series: "currency_exchange_oldest_rate_age_seconds 3600"},
+		{name: "runtime", series: "go_goroutines "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(string(body), "\n"+tt.series) {
+				t.Errorf("series %s not exported", tt.series)
+			}
+		})
+	}
+}
+
+func TestInventoryCollector(t *testing.T) {
+	tests := []struct {
+		name      string
+		inventory Inventory
+		err       error
+		want      int
+	}{
+		{name: "inventory", inventory: Inventory{Currencies: 1, Pairs: 1, OldestRate: time.Now()}, want: 3},
+		{name: "no rates", inventory: Inventory{Currencies: 1}, want: 3},
+		{name: "inventory not read", err: errors.New("database locked"), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			r.MustRegister(&inventoryCollector{read: func() (Inventory, error) { return tt.inventory, tt.err }})
+
+			families, err := r.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(families) != tt.want {
+				t.Errorf("%d gauges, want %d", len(families), tt.want)
+			}
+		})
+	}
+}
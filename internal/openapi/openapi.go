@@ -0,0 +1,52 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The OpenAPI documents of the API versions mounted in app.SetRoutes, keep them in sync with the controllers.
+var (
+	//go:embed v1.json
+	specV1 []byte
+	//go:embed v2.json
+	specV2 []byte
+)
+
+//go:embed docs.html
+var docs []byte
+
+const docsPolicy = "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'"
+
+// SpecV1Handler serves the OpenAPI document of API v1.
+func SpecV1Handler(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, "application/json", specV1)
+}
+
+// SpecV2Handler serves the OpenAPI document of API v2.
+func SpecV2Handler(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, "application/json", specV2)
+}
+
+// DocsHandler serves the page rendering the openapi.json document next to it. The page is self-contained, its
+// policy lets it load nothing but the document.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	serve(w, r, "text/html; charset=utf-8", docs)
+}
+
+func serve(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval of the removal of the buckets of idle clients.
+const sweepInterval = time.Minute
+
+// Limiter limits the requests of every client with a token bucket, refilled at the rate up to the burst.
+type Limiter struct {
+	limit   config.RateLimit
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Result is the state of the bucket of a client after a request.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int64
+	// Remaining is the number of requests the client may make right away
+	Remaining int64
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if this one was
+	RetryAfter time.Duration
+}
+
+func New(limit config.RateLimit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		rate:    limit.Rate,
+		burst:   math.Max(float64(limit.Burst), 1),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Limit returns the limit the limiter was created with.
+func (l *Limiter) Limit() config.RateLimit {
+	return l.limit
+}
+
+// Limited reports whether the rate of the limiter is limited at all.
+func (l *Limiter) Limited() bool {
+	return l.rate > 0
+}
+
+// Take takes a token from the bucket of the client if there is one.
+func (l *Limiter) Take(client string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	result := Result{Limit: int64(l.burst)}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+
+	result.Remaining = int64(b.tokens)
+	result.Reset = l.duration(l.burst - b.tokens)
+
+	return result
+}
+
+// duration returns the time the bucket takes to be refilled with the tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep removes the buckets that are full by now, they are the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+
+	l.swept = now
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// a bucket of 2 tokens refilled with one every 2 seconds
+	limiter := New(config.RateLimit{Rate: 0.5, Burst: 2})
+
+	tests := []struct {
+		name       string
+		client     string
+		at         time.Duration
+		allowed    bool
+		remaining  int64
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{name: "full bucket", client: "a", allowed: true, remaining: 1, reset: 2 * time.Second},
+		{name: "last token", client: "a", allowed: true, remaining: 0, reset: 4 * time.Second},
+		{name: "empty bucket", client: "a", remaining: 0, reset: 4 * time.Second, retryAfter: 2 * time.Second},
+		{name: "other client", client: "b", allowed: true, remaining: 1, reset: 2 * time.Second},
+		{name: "half a token refilled", client: "a", at: time.Second, remaining: 0, reset: 3 * time.Second, retryAfter: time.Second},
+		{name: "token refilled", client: "a", at: 2 * time.Second, allowed: true, remaining: 0, reset: 4 * time.Second},
+		{name: "refilled up to the burst", client: "a", at: time.Hour, allowed: true, remaining: 1, reset: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := limiter.Take(tt.client, start.Add(tt.at))
+
+			want := Result{Allowed: tt.allowed, Limit: 2, Remaining: tt.remaining, Reset: tt.reset, RetryAfter: tt.retryAfter}
+			if got != want {
+				t.Errorf("%+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   config.RateLimit
+		burst   int64
+		limited bool
+	}{
+		{name: "burst", limit: config.RateLimit{Rate: 1, Burst: 5}, burst: 5, limited: true},
+		{name: "no burst", limit: config.RateLimit{Rate: 1}, burst: 1, limited: true},
+		{name: "quota only", limit: config.RateLimit{DailyQuota: 100}, burst: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(tt.limit)
+
+			if limiter.Limited() != tt.limited {
+				t.Errorf("limited %v, want %v", limiter.Limited(), tt.limited)
+			}
+
+			if tt.limited && limiter.Take("a", time.Now()).Limit != tt.burst {
+				t.Errorf("bucket of %d, want %d", limiter.Take("a", time.Now()).Limit, tt.burst)
+			}
+		})
+	}
+}
+
+func TestSweepRemovesFullBuckets(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// a bucket of 10 tokens refilled with one every 10 seconds
+	limiter := New(config.RateLimit{Rate: 0.1, Burst: 10})
+
+	limiter.Take("idle", start)
+
+	for i := 0; i < 10; i++ {
+		limiter.Take("busy", start.Add(sweepInterval/2))
+	}
+
+	// the idle bucket is full again by now, the busy one has 3 tokens
+	limiter.Take("other", start.Add(sweepInterval))
+
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("bucket of the idle client kept")
+	}
+
+	if b, ok := limiter.buckets["busy"]; !ok || b.tokens != 0 {
+		t.Error("bucket of the busy client removed")
+	}
+}
@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
 	"github.com/albakov/go-currency-exchange/internal/storage"
 	"github.com/albakov/go-currency-exchange/internal/storage/currencies"
 	"github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
-	"github.com/albakov/go-currency-exchange/internal/util"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"math"
 )
 
@@ -17,6 +21,7 @@ type Exchange struct {
 	baseCurrencyId, targetCurrencyId int64
 	isReversed                       bool
 	rate, amount                     float64
+	pivots                           []string
 	storageCurrencies                currencies.StorageCurrencies
 	storageExchangeRates             exchangerates.StorageExchangeRates
 }
@@ -24,6 +29,7 @@ type Exchange struct {
 func New(
 	storageCurrencies currencies.StorageCurrencies,
 	storageExchangeRates exchangerates.StorageExchangeRates,
+	pivots []string,
 	baseCurrencyId,
 	targetCurrencyId int64,
 	amount float64,
@@ -31,14 +37,15 @@ func New(
 	return &Exchange{
 		storageCurrencies:    storageCurrencies,
 		storageExchangeRates: storageExchangeRates,
+		pivots:               pivots,
 		baseCurrencyId:       baseCurrencyId,
 		targetCurrencyId:     targetCurrencyId,
 		amount:               amount,
 	}
 }
 
-func (e *Exchange) Rate() (float64, error) {
-	err := e.calculate()
+func (e *Exchange) Rate(ctx context.Context) (float64, error) {
+	err := e.calculate(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -63,9 +70,13 @@ func (e *Exchange) ConvertedAmount() float64 {
 	return e.round(convertedAmount)
 }
 
-func (e *Exchange) calculate() error {
-	err := e.direct()
+// calculate resolves the rate by the pair, the reverse pair or the cross rate via a pivot, in that order, and counts
+// the path it was resolved by.
+func (e *Exchange) calculate(ctx context.Context) error {
+	err := e.direct(ctx)
 	if err == nil {
+		metrics.CountConversion(metrics.PathDirect)
+
 		return nil
 	}
 
@@ -73,9 +84,10 @@ func (e *Exchange) calculate() error {
 		return err
 	}
 
-	err = e.reverse()
+	err = e.reverse(ctx)
 	if err == nil {
 		e.isReversed = true
+		metrics.CountConversion(metrics.PathReverse)
 
 		return nil
 	}
@@ -84,18 +96,28 @@ func (e *Exchange) calculate() error {
 		return err
 	}
 
-	err = e.cross()
+	err = e.cross(ctx)
 	if err == nil {
+		metrics.CountConversion(metrics.PathCross)
+
 		return nil
 	}
 
+	if errors.Is(err, NotFoundError) {
+		metrics.CountConversion(metrics.PathNotFound)
+	}
+
 	return err
 }
 
-func (e *Exchange) direct() error {
+func (e *Exchange) direct(ctx context.Context) error {
 	const op = "direct"
 
+	ctx, span := tracing.Start(ctx, "Exchange.direct")
+	defer span.End()
+
 	exchangeRate, err := e.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		ctx,
 		e.baseCurrencyId,
 		e.targetCurrencyId,
 	)
@@ -105,7 +127,7 @@ func (e *Exchange) direct() error {
 			return NotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
@@ -115,10 +137,14 @@ func (e *Exchange) direct() error {
 	return nil
 }
 
-func (e *Exchange) reverse() error {
+func (e *Exchange) reverse(ctx context.Context) error {
 	const op = "reverse"
 
+	ctx, span := tracing.Start(ctx, "Exchange.reverse")
+	defer span.End()
+
 	exchangeRate, err := e.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
+		ctx,
 		e.targetCurrencyId,
 		e.baseCurrencyId,
 	)
@@ -128,7 +154,7 @@ func (e *Exchange) reverse() error {
 			return NotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
@@ -138,22 +164,38 @@ func (e *Exchange) reverse() error {
 	return nil
 }
 
-func (e *Exchange) cross() error {
-	const op = "cross"
+// cross resolves the cross rate via the first of the pivots both currencies of the pair have rates from.
+func (e *Exchange) cross(ctx context.Context) error {
+	for _, pivot := range e.pivots {
+		err := e.crossVia(ctx, pivot)
+		if !errors.Is(err, NotFoundError) {
+			return err
+		}
+	}
+
+	return NotFoundError
+}
+
+func (e *Exchange) crossVia(ctx context.Context, pivot string) error {
+	const op = "crossVia"
+
+	ctx, span := tracing.Start(ctx, "Exchange.cross", attribute.String("pivot", pivot))
+	defer span.End()
 
-	usdCurrency, err := e.storageCurrencies.ByCode("USD")
+	pivotCurrency, err := e.storageCurrencies.ByCode(ctx, pivot)
 	if err != nil {
 		if errors.Is(err, storage.EntitiesNotFoundError) {
 			return NotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
 
 	exchangeRateA, err := e.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
-		usdCurrency.ID,
+		ctx,
+		pivotCurrency.ID,
 		e.baseCurrencyId,
 	)
 	if err != nil {
@@ -161,13 +203,14 @@ func (e *Exchange) cross() error {
 			return NotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
 
 	exchangeRateB, err := e.storageExchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(
-		usdCurrency.ID,
+		ctx,
+		pivotCurrency.ID,
 		e.targetCurrencyId,
 	)
 	if err != nil {
@@ -175,7 +218,7 @@ func (e *Exchange) cross() error {
 			return NotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is a database with the rates from USD and GBP to EUR and RUB, CHF has no rates.
+type fixture struct {
+	currencies    *storageCurrencies.Currencies
+	exchangeRates *storageExchangeRates.ExchangeRates
+	ids           map[string]int64
+}
+
+func setup(t *testing.T) fixture {
+	t.Helper()
+
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fx := fixture{
+		currencies:    storageCurrencies.New(c.PathToDB, nil, nil),
+		exchangeRates: storageExchangeRates.New(c.PathToDB, nil, nil),
+		ids:           map[string]int64{},
+	}
+
+	for _, code := range []string{"USD", "GBP", "EUR", "RUB", "CHF"} {
+		fx.ids[code], err = fx.currencies.Add(context.Background(), entity.Currency{Code: code, FullName: code, Sign: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pair, rate := range map[[2]string]float64{
+		{"USD", "EUR"}: 0.9,
+		{"USD", "RUB"}: 90,
+		{"GBP", "EUR"}: 1.2,
+		{"GBP", "RUB"}: 110,
+	} {
+		_, err = fx.exchangeRates.Add(context.Background(), entity.ExchangeRates{
+			BaseCurrency:   entity.Currency{ID: fx.ids[pair[0]]},
+			TargetCurrency: entity.Currency{ID: fx.ids[pair[1]]},
+			Rate:           rate,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fx
+}
+
+func TestExchangeResolution(t *testing.T) {
+	fx := setup(t)
+
+	tests := []struct {
+		name     string
+		pivots   []string
+		from, to string
+		rate     float64
+		amount   float64
+		err      error
+	}{
+		{name: "direct", pivots: []string{"USD"}, from: "USD", to: "RUB", rate: 90, amount: 900},
+		{name: "reverse", pivots: []string{"USD"}, from: "RUB", to: "USD", rate: 90, amount: 0.11},
+		{name: "cross via the pivot", pivots: []string{"USD"}, from: "EUR", to: "RUB", rate: 100, amount: 1000},
+		{name: "cross via the first pivot", pivots: []string{"GBP", "USD"}, from: "EUR", to: "RUB", rate: 91.67, amount: 916.7},
+		{name: "cross via a later pivot", pivots: []string{"CHF", "XXX", "USD"}, from: "EUR", to: "RUB", rate: 100, amount: 1000},
+		{name: "no pivot with both rates", pivots: []string{"CHF", "XXX"}, from: "EUR", to: "RUB", err: NotFoundError},
+		{name: "no pivots", from: "EUR", to: "RUB", err: NotFoundError},
+		{name: "currency without rates", pivots: []string{"USD"}, from: "CHF", to: "EUR", err: NotFoundError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := New(fx.currencies, fx.exchangeRates, tt.pivots, fx.ids[tt.from], fx.ids[tt.to], 10)
+
+			rate, err := exchange.Rate(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+
+			if rate != tt.rate || exchange.ConvertedAmount() != tt.amount {
+				t.Errorf("rate %v and amount %v, want %v and %v", rate, exchange.ConvertedAmount(), tt.rate, tt.amount)
+			}
+		})
+	}
+}
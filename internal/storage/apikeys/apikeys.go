@@ -0,0 +1,220 @@
+package apikeys
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"strings"
+	"time"
+)
+
+const f = "storage.ApiKeys"
+
+const selectQuery = "SELECT ID, Name, Prefix, Hash, Scopes, CreatedAt, RevokedAt FROM ApiKeys"
+
+type StorageAPIKeys interface {
+	All() ([]entity.APIKey, error)
+	ByHash(hash string) (entity.APIKey, error)
+	Add(apiKey entity.APIKey) (int64, error)
+	Revoke(id int64) error
+}
+
+type ApiKeys struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *ApiKeys {
+	return &ApiKeys{
+		pathToDb: pathToDb,
+	}
+}
+
+func (c *ApiKeys) All() ([]entity.APIKey, error) {
+	const op = "All"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	stmt, err := db.Query(selectQuery + " ORDER BY ID")
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	apiKeys := []entity.APIKey{}
+
+	for stmt.Next() {
+		apiKey, err := c.scan(stmt)
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return nil, err
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	if stmt.Err() != nil {
+		logging.Error(f, op, stmt.Err())
+
+		return nil, stmt.Err()
+	}
+
+	return apiKeys, nil
+}
+
+// ByHash returns the key with the hash, revoked keys included.
+func (c *ApiKeys) ByHash(hash string) (entity.APIKey, error) {
+	const op = "ByHash"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	apiKey, err := c.scan(db.QueryRow(selectQuery+" WHERE Hash = ?", hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.APIKey{}, storage.EntitiesNotFoundError
+		}
+
+		logging.Error(f, op, err)
+
+		return entity.APIKey{}, err
+	}
+
+	return apiKey, nil
+}
+
+func (c *ApiKeys) Add(apiKey entity.APIKey) (int64, error) {
+	const op = "Add"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		"INSERT INTO ApiKeys (Name, Prefix, Hash, Scopes, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		apiKey.Name,
+		apiKey.Prefix,
+		apiKey.Hash,
+		strings.Join(apiKey.Scopes, ","),
+		apiKey.CreatedAt.UTC(),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, storage.EntityAlreadyExistsError
+		}
+
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Revoke revokes the key, it returns storage.EntitiesNotFoundError if there is no such key not revoked yet.
+func (c *ApiKeys) Revoke(id int64) error {
+	const op = "Revoke"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec("UPDATE ApiKeys SET RevokedAt = ? WHERE ID = ? AND RevokedAt IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	if affected == 0 {
+		return storage.EntitiesNotFoundError
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (c *ApiKeys) scan(row scanner) (entity.APIKey, error) {
+	apiKey := entity.APIKey{}
+
+	var scopes string
+	var revokedAt sql.NullTime
+
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.Hash,
+		&scopes,
+		&apiKey.CreatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+
+	apiKey.Scopes = strings.Split(scopes, ",")
+
+	if revokedAt.Valid {
+		apiKey.RevokedAt = &revokedAt.Time
+	}
+
+	return apiKey, nil
+}
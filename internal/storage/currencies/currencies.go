@@ -1,34 +1,65 @@
 package currencies
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
 	"github.com/albakov/go-currency-exchange/internal/storage"
-	"github.com/albakov/go-currency-exchange/internal/util"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
 	"strings"
+	"time"
 )
 
 const f = "storage.Currencies"
 
 type StorageCurrencies interface {
-	All() []entity.Currency
-	ByCode(code string) (entity.Currency, error)
-	Add(currency entity.Currency) (int64, error)
+	All(ctx context.Context) []entity.Currency
+	List(ctx context.Context, filter Filter) ([]entity.Currency, int64, error)
+	ByCode(ctx context.Context, code string) (entity.Currency, error)
+	Add(ctx context.Context, currency entity.Currency) (int64, error)
+}
+
+// Filter narrows down List. CodePrefix matches the beginning of the code,
+// Name matches any part of the full name.
+type Filter struct {
+	CodePrefix string
+	Name       string
+	storage.ListOptions
+}
+
+// sortColumns maps the accepted sort keys to the ORDER BY clause.
+var sortColumns = map[string]string{
+	"id":   "ID",
+	"code": "Code",
+	"name": "FullName",
 }
 
 type Currencies struct {
-	pathToDb string
+	pathToDb  string
+	publisher events.Publisher
+	outbox    events.Outbox
 }
 
-func New(pathToDb string) *Currencies {
+// New returns the storage queueing the event of every added currency in outbox along with the currency and
+// notifying publisher once it is stored, both may be nil.
+func New(pathToDb string, publisher events.Publisher, outbox events.Outbox) *Currencies {
 	return &Currencies{
-		pathToDb: pathToDb,
+		pathToDb:  pathToDb,
+		publisher: publisher,
+		outbox:    outbox,
 	}
 }
 
-func (c *Currencies) All() []entity.Currency {
+func (c *Currencies) All(ctx context.Context) []entity.Currency {
 	const op = "All"
+	defer metrics.ObserveQuery("StorageCurrencies", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageCurrencies", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -37,18 +68,21 @@ func (c *Currencies) All() []entity.Currency {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	stmt, err := db.Query("SELECT ID, Code, FullName, Sign FROM Currencies")
+	query := "SELECT ID, Code, FullName, Sign FROM Currencies"
+	tracing.Statement(span, query)
+
+	stmt, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return []entity.Currency{}
 	}
 	defer func(stmt *sql.Rows) {
 		err := stmt.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(stmt)
 
@@ -59,7 +93,7 @@ func (c *Currencies) All() []entity.Currency {
 
 		err := stmt.Scan(&currency.ID, &currency.Code, &currency.FullName, &currency.Sign)
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 
 			return []entity.Currency{}
 		}
@@ -70,8 +104,106 @@ func (c *Currencies) All() []entity.Currency {
 	return currencies
 }
 
-func (c *Currencies) ByCode(code string) (entity.Currency, error) {
-	const op = "Code"
+// List returns the page of currencies matching filter and the total number of matching currencies.
+func (c *Currencies) List(ctx context.Context, filter Filter) ([]entity.Currency, int64, error) {
+	const op = "List"
+	defer metrics.ObserveQuery("StorageCurrencies", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageCurrencies", op)
+	defer span.End()
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+		}
+	}(db)
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if filter.CodePrefix != "" {
+		where = append(where, `Code LIKE ? ESCAPE '\'`)
+		args = append(args, storage.EscapeLike(filter.CodePrefix)+"%")
+	}
+
+	if filter.Name != "" {
+		where = append(where, `FullName LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+storage.EscapeLike(filter.Name)+"%")
+	}
+
+	conditions := strings.Join(where, " AND ")
+
+	var total int64
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Currencies WHERE "+conditions, args...).Scan(&total)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, 0, err
+	}
+
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		column = sortColumns["id"]
+	}
+
+	query := "SELECT ID, Code, FullName, Sign FROM Currencies WHERE " + conditions +
+		" ORDER BY " + column + " " + filter.Direction() + ", ID " + filter.Direction()
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	tracing.Statement(span, query)
+
+	stmt, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+		}
+	}(stmt)
+
+	currencies := []entity.Currency{}
+
+	for stmt.Next() {
+		currency := entity.Currency{}
+
+		err := stmt.Scan(&currency.ID, &currency.Code, &currency.FullName, &currency.Sign)
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+
+			return nil, 0, err
+		}
+
+		currencies = append(currencies, currency)
+	}
+
+	if stmt.Err() != nil {
+		logging.ErrorContext(ctx, f, op, stmt.Err())
+
+		return nil, 0, stmt.Err()
+	}
+
+	return currencies, total, nil
+}
+
+func (c *Currencies) ByCode(ctx context.Context, code string) (entity.Currency, error) {
+	const op = "ByCode"
+	defer metrics.ObserveQuery("StorageCurrencies", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageCurrencies", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -80,15 +212,18 @@ func (c *Currencies) ByCode(code string) (entity.Currency, error) {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
 	currency := entity.Currency{}
 
-	row := db.QueryRow("SELECT ID, Code, FullName, Sign FROM Currencies WHERE Code = ?", code)
+	query := "SELECT ID, Code, FullName, Sign FROM Currencies WHERE Code = ?"
+	tracing.Statement(span, query)
+
+	row := db.QueryRowContext(ctx, query, code)
 	if row.Err() != nil {
-		util.LogError(f, op, row.Err())
+		logging.ErrorContext(ctx, f, op, row.Err())
 
 		return currency, row.Err()
 	}
@@ -99,7 +234,7 @@ func (c *Currencies) ByCode(code string) (entity.Currency, error) {
 			return entity.Currency{}, storage.EntitiesNotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return entity.Currency{}, err
 	}
@@ -107,8 +242,12 @@ func (c *Currencies) ByCode(code string) (entity.Currency, error) {
 	return currency, nil
 }
 
-func (c *Currencies) Add(currency entity.Currency) (int64, error) {
+func (c *Currencies) Add(ctx context.Context, currency entity.Currency) (int64, error) {
 	const op = "Add"
+	defer metrics.ObserveQuery("StorageCurrencies", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageCurrencies", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -117,40 +256,61 @@ func (c *Currencies) Add(currency entity.Currency) (int64, error) {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	stmt, err := db.Prepare("INSERT INTO Currencies (Code, FullName, Sign) VALUES (?, ?, ?)")
+	query := "INSERT INTO Currencies (Code, FullName, Sign) VALUES (?, ?, ?)"
+	tracing.Statement(span, query)
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
-	defer func(stmt *sql.Stmt) {
-		err := stmt.Close()
-		if err != nil {
-			util.LogError(f, op, err)
-		}
-	}(stmt)
+	defer storage.Rollback(tx)
 
-	exec, err := stmt.Exec(currency.Code, currency.FullName, currency.Sign)
+	exec, err := tx.ExecContext(ctx, query, currency.Code, currency.FullName, currency.Sign)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE") {
 			return 0, storage.EntityAlreadyExistsError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
 
 	id, err := exec.LastInsertId()
 	if err != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
+
+		return 0, err
+	}
+
+	currency.ID = id
+	event := events.Event{Type: events.CurrencyCreated, Currency: currency, OccurredAt: time.Now().UTC()}
+
+	if c.outbox != nil {
+		err = c.outbox.Enqueue(ctx, tx, event)
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
 
+	if c.publisher != nil {
+		c.publisher.Publish(event)
+	}
+
 	return id, nil
 }
@@ -0,0 +1,105 @@
+package currencies
+
+import (
+	"bytes"
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	_ "github.com/mattn/go-sqlite3"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currencies := New(c.PathToDB, nil, nil)
+
+	for _, currency := range []entity.Currency{
+		{Code: "USD", FullName: "US Dollar", Sign: "$"},
+		{Code: "EUR", FullName: "Euro", Sign: "€"},
+		{Code: "UAH", FullName: "Hryvnia", Sign: "₴"},
+		{Code: "AUD", FullName: "Australian Dollar", Sign: "A$"},
+		{Code: "XB_", FullName: "100% Bond", Sign: "B"},
+	} {
+		_, err = currencies.Add(context.Background(), currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+		total  int64
+	}{
+		{name: "all by id", want: "USD,EUR,UAH,AUD,XB_", total: 5},
+		{name: "by code", filter: Filter{ListOptions: storage.ListOptions{Sort: "code"}}, want: "AUD,EUR,UAH,USD,XB_", total: 5},
+		{name: "by name descending", filter: Filter{ListOptions: storage.ListOptions{Sort: "name", Desc: true}}, want: "USD,UAH,EUR,AUD,XB_", total: 5},
+		{name: "unknown sort", filter: Filter{ListOptions: storage.ListOptions{Sort: "sign"}}, want: "USD,EUR,UAH,AUD,XB_", total: 5},
+		{name: "first page", filter: Filter{ListOptions: storage.ListOptions{Limit: 2, Sort: "code"}}, want: "AUD,EUR", total: 5},
+		{name: "next page", filter: Filter{ListOptions: storage.ListOptions{Limit: 2, Offset: 2, Sort: "code"}}, want: "UAH,USD", total: 5},
+		{name: "past the end", filter: Filter{ListOptions: storage.ListOptions{Limit: 2, Offset: 6}}, total: 5},
+		{name: "code prefix", filter: Filter{CodePrefix: "U"}, want: "USD,UAH", total: 2},
+		{name: "name search", filter: Filter{Name: "dollar"}, want: "USD,AUD", total: 2},
+		{name: "filter and page", filter: Filter{Name: "dollar", ListOptions: storage.ListOptions{Limit: 1, Offset: 1}}, want: "AUD", total: 2},
+		{name: "wildcards matched literally", filter: Filter{CodePrefix: "XB_", Name: "100%"}, want: "XB_", total: 1},
+		{name: "wildcard not matching", filter: Filter{CodePrefix: "_"}, total: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, total, err := currencies.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			codes := make([]string, 0, len(list))
+			for _, currency := range list {
+				codes = append(codes, currency.Code)
+			}
+
+			if strings.Join(codes, ",") != tt.want || total != tt.total {
+				t.Errorf("currencies %v of %d, want %s of %d", codes, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestErrorsLoggedWithRequest(t *testing.T) {
+	// the database has no tables, every query fails
+	c := New(filepath.Join(t.TempDir(), "sqlite.db"), nil, nil)
+
+	tests := []struct {
+		op   string
+		call func(ctx context.Context)
+	}{
+		{op: "List", call: func(ctx context.Context) { _, _, _ = c.List(ctx, Filter{}) }},
+		{op: "ByCode", call: func(ctx context.Context) { _, _ = c.ByCode(ctx, "USD") }},
+		{op: "Add", call: func(ctx context.Context) { _, _ = c.Add(ctx, entity.Currency{Code: "USD"}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil)).With("requestId", "r-1")
+
+			tt.call(logging.NewContext(context.Background(), logger))
+
+			if !strings.Contains(buf.String(), "requestId=r-1") || !strings.Contains(buf.String(), "op="+tt.op) {
+				t.Errorf("log %q, want the failure of %s with the request id", buf.String(), tt.op)
+			}
+		})
+	}
+}
@@ -7,4 +7,6 @@ import (
 var (
 	EntityAlreadyExistsError = fmt.Errorf("entity already exists")
 	EntitiesNotFoundError    = fmt.Errorf("entities not found")
+	// EntityChangedError is returned when an entity was changed since the version the caller read
+	EntityChangedError = fmt.Errorf("entity changed")
 )
@@ -1,35 +1,88 @@
 package exchangerates
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/metrics"
 	"github.com/albakov/go-currency-exchange/internal/storage"
-	"github.com/albakov/go-currency-exchange/internal/util"
+	"github.com/albakov/go-currency-exchange/internal/tracing"
 	"strings"
+	"time"
 )
 
 const f = "storage.ExchangeRatesHandler"
 
+const selectQuery = `SELECT ExchangeRates.ID, ExchangeRates.Rate, ExchangeRates.UpdatedAt, ExchangeRates.Version,
+       		BaseCurrency.ID as BaseCurrencyID,
+       		BaseCurrency.Code as BaseCurrencyCode,
+       		BaseCurrency.FullName as BaseCurrencyFullName,
+       		BaseCurrency.Sign as BaseCurrencySign,
+       		TargetCurrency.ID as TargetCurrencyID,
+       		TargetCurrency.Code as TargetCurrencyCode,
+       		TargetCurrency.FullName as TargetCurrencyFullName,
+       		TargetCurrency.Sign as TargetCurrencySign
+       	FROM ExchangeRates
+		LEFT JOIN Currencies as BaseCurrency ON BaseCurrency.Id = ExchangeRates.BaseCurrencyId
+		LEFT JOIN Currencies as TargetCurrency ON TargetCurrency.Id = ExchangeRates.TargetCurrencyId`
+
+// StorageExchangeRates stores the UpdatedAt of written rates as given, a zero value stands for the current time.
+// Add and UpdateRate expect the rate to hold both currencies, they are queued in the outbox and published along
+// with it.
+// UpdateRate writes the rate only if its Version is still the stored one, the stored version is incremented then,
+// and returns storage.EntityChangedError otherwise.
 type StorageExchangeRates interface {
-	All() []entity.ExchangeRates
-	Add(exchangeRates entity.ExchangeRates) (int64, error)
-	ByBaseCurrencyIdAndTargetCurrencyId(baseCurrencyId int64, targetCurrencyId int64) (entity.ExchangeRates, error)
-	UpdateRate(exchangeRates entity.ExchangeRates) error
+	All(ctx context.Context) []entity.ExchangeRates
+	List(ctx context.Context, filter Filter) ([]entity.ExchangeRates, int64, error)
+	Add(ctx context.Context, exchangeRates entity.ExchangeRates) (int64, error)
+	ByBaseCurrencyIdAndTargetCurrencyId(
+		ctx context.Context,
+		baseCurrencyId int64,
+		targetCurrencyId int64,
+	) (entity.ExchangeRates, error)
+	UpdateRate(ctx context.Context, exchangeRates entity.ExchangeRates) error
+}
+
+// Filter narrows down List. Base and Target are exact currency codes.
+type Filter struct {
+	Base   string
+	Target string
+	storage.ListOptions
+}
+
+// sortColumns maps the accepted sort keys to the ORDER BY clause, %s is replaced by the direction.
+var sortColumns = map[string]string{
+	"id":      "ExchangeRates.ID %s",
+	"code":    "BaseCurrency.Code %s, TargetCurrency.Code %s",
+	"rate":    "ExchangeRates.Rate %s",
+	"updated": "ExchangeRates.UpdatedAt %s",
 }
 
 type ExchangeRates struct {
-	pathToDb string
+	pathToDb  string
+	publisher events.Publisher
+	outbox    events.Outbox
 }
 
-func New(pathToDb string) *ExchangeRates {
+// New returns the storage queueing the event of every written rate in outbox along with the rate and notifying
+// publisher once it is stored, both may be nil.
+func New(pathToDb string, publisher events.Publisher, outbox events.Outbox) *ExchangeRates {
 	return &ExchangeRates{
-		pathToDb: pathToDb,
+		pathToDb:  pathToDb,
+		publisher: publisher,
+		outbox:    outbox,
 	}
 }
 
-func (c *ExchangeRates) All() []entity.ExchangeRates {
+func (c *ExchangeRates) All(ctx context.Context) []entity.ExchangeRates {
 	const op = "All"
+	defer metrics.ObserveQuery("StorageExchangeRates", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageExchangeRates", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -38,70 +91,128 @@ func (c *ExchangeRates) All() []entity.ExchangeRates {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	stmt, err := db.Query(
-		`SELECT ExchangeRates.ID, ExchangeRates.Rate, 
-       		BaseCurrency.ID as BaseCurrencyID,
-       		BaseCurrency.Code as BaseCurrencyCode,
-       		BaseCurrency.FullName as BaseCurrencyFullName,
-       		BaseCurrency.Sign as BaseCurrencySign,
-       		TargetCurrency.ID as TargetCurrencyID,
-       		TargetCurrency.Code as TargetCurrencyCode,
-       		TargetCurrency.FullName as TargetCurrencyFullName,
-       		TargetCurrency.Sign as TargetCurrencySign
-       	FROM ExchangeRates
-		LEFT JOIN Currencies as BaseCurrency ON BaseCurrency.Id = ExchangeRates.BaseCurrencyId 
-		LEFT JOIN Currencies as TargetCurrency ON TargetCurrency.Id = ExchangeRates.TargetCurrencyId`,
-	)
+	tracing.Statement(span, selectQuery)
+
+	stmt, err := db.QueryContext(ctx, selectQuery)
 	if err != nil {
 		return []entity.ExchangeRates{}
 	}
 	defer func(stmt *sql.Rows) {
 		err := stmt.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(stmt)
 
-	currencies := []entity.ExchangeRates{}
-
-	for stmt.Next() {
-		exchangeRates := entity.ExchangeRates{}
-		baseCurrency := entity.Currency{}
-		targetCurrency := entity.Currency{}
-
-		err := stmt.Scan(
-			&exchangeRates.ID,
-			&exchangeRates.Rate,
-			&baseCurrency.ID,
-			&baseCurrency.Code,
-			&baseCurrency.FullName,
-			&baseCurrency.Sign,
-			&targetCurrency.ID,
-			&targetCurrency.Code,
-			&targetCurrency.FullName,
-			&targetCurrency.Sign,
-		)
+	currencies, err := c.scanAll(stmt)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return []entity.ExchangeRates{}
+	}
+
+	return currencies
+}
+
+// List returns the page of exchange rates matching filter and the total number of matching rates.
+func (c *ExchangeRates) List(ctx context.Context, filter Filter) ([]entity.ExchangeRates, int64, error) {
+	const op = "List"
+	defer metrics.ObserveQuery("StorageExchangeRates", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageExchangeRates", op)
+	defer span.End()
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
+		}
+	}(db)
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
 
-			return []entity.ExchangeRates{}
+	if filter.Base != "" {
+		where = append(where, "BaseCurrency.Code = ?")
+		args = append(args, filter.Base)
+	}
+
+	if filter.Target != "" {
+		where = append(where, "TargetCurrency.Code = ?")
+		args = append(args, filter.Target)
+	}
+
+	conditions := strings.Join(where, " AND ")
+
+	var total int64
+	err = db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM ExchangeRates
+		LEFT JOIN Currencies as BaseCurrency ON BaseCurrency.Id = ExchangeRates.BaseCurrencyId
+		LEFT JOIN Currencies as TargetCurrency ON TargetCurrency.Id = ExchangeRates.TargetCurrencyId
+		WHERE `+conditions,
+		args...,
+	).Scan(&total)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, 0, err
+	}
+
+	order, ok := sortColumns[filter.Sort]
+	if !ok {
+		order = sortColumns["id"]
+	}
+
+	direction := filter.Direction()
+	order = strings.ReplaceAll(order, "%s", direction) + ", ExchangeRates.ID " + direction
+
+	query := selectQuery + " WHERE " + conditions + " ORDER BY " + order
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	tracing.Statement(span, query)
+
+	stmt, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
 		}
+	}(stmt)
 
-		exchangeRates.BaseCurrency = baseCurrency
-		exchangeRates.TargetCurrency = targetCurrency
+	exchangeRates, err := c.scanAll(stmt)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
 
-		currencies = append(currencies, exchangeRates)
+		return nil, 0, err
 	}
 
-	return currencies
+	return exchangeRates, total, nil
 }
 
-func (c *ExchangeRates) Add(exchangeRates entity.ExchangeRates) (int64, error) {
+func (c *ExchangeRates) Add(ctx context.Context, exchangeRates entity.ExchangeRates) (int64, error) {
 	const op = "Add"
+	defer metrics.ObserveQuery("StorageExchangeRates", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageExchangeRates", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -110,39 +221,54 @@ func (c *ExchangeRates) Add(exchangeRates entity.ExchangeRates) (int64, error) {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	stmt, err := db.Prepare(
-		"INSERT INTO ExchangeRates (BaseCurrencyId, TargetCurrencyId, Rate) VALUES (?, ?, ?)",
-	)
+	query := "INSERT INTO ExchangeRates (BaseCurrencyId, TargetCurrencyId, Rate, UpdatedAt) VALUES (?, ?, ?, ?)"
+	tracing.Statement(span, query)
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
-	defer func(stmt *sql.Stmt) {
-		err := stmt.Close()
-		if err != nil {
-			util.LogError(f, op, err)
-		}
-	}(stmt)
+	defer storage.Rollback(tx)
+
+	exchangeRates.UpdatedAt = c.updatedAt(exchangeRates)
 
-	exec, err := stmt.Exec(exchangeRates.BaseCurrency.ID, exchangeRates.TargetCurrency.ID, exchangeRates.Rate)
+	exec, err := tx.ExecContext(
+		ctx,
+		query,
+		exchangeRates.BaseCurrency.ID,
+		exchangeRates.TargetCurrency.ID,
+		exchangeRates.Rate,
+		exchangeRates.UpdatedAt,
+	)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE") {
 			return 0, storage.EntityAlreadyExistsError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
 
 	id, err := exec.LastInsertId()
 	if err != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
+
+		return 0, err
+	}
+
+	exchangeRates.ID = id
+	exchangeRates.Version = 1
+
+	err = c.commit(ctx, tx, events.RateCreated, exchangeRates)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
 
 		return 0, err
 	}
@@ -151,10 +277,15 @@ func (c *ExchangeRates) Add(exchangeRates entity.ExchangeRates) (int64, error) {
 }
 
 func (c *ExchangeRates) ByBaseCurrencyIdAndTargetCurrencyId(
+	ctx context.Context,
 	baseCurrencyId int64,
 	targetCurrencyId int64,
 ) (entity.ExchangeRates, error) {
 	const op = "ByBaseCurrencyIdAndTargetCurrencyId"
+	defer metrics.ObserveQuery("StorageExchangeRates", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageExchangeRates", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -163,67 +294,40 @@ func (c *ExchangeRates) ByBaseCurrencyIdAndTargetCurrencyId(
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	row := db.QueryRow(
-		`SELECT ExchangeRates.ID, ExchangeRates.Rate, 
-       		BaseCurrency.ID as BaseCurrencyID,
-       		BaseCurrency.Code as BaseCurrencyCode,
-       		BaseCurrency.FullName as BaseCurrencyFullName,
-       		BaseCurrency.Sign as BaseCurrencySign,
-       		TargetCurrency.ID as TargetCurrencyID,
-       		TargetCurrency.Code as TargetCurrencyCode,
-       		TargetCurrency.FullName as TargetCurrencyFullName,
-       		TargetCurrency.Sign as TargetCurrencySign
-       	FROM ExchangeRates
-		LEFT JOIN Currencies as BaseCurrency ON BaseCurrency.Id = ExchangeRates.BaseCurrencyId 
-		LEFT JOIN Currencies as TargetCurrency ON TargetCurrency.Id = ExchangeRates.TargetCurrencyId 
-		WHERE ExchangeRates.BaseCurrencyId = ? AND ExchangeRates.TargetCurrencyId = ?`,
-		baseCurrencyId,
-		targetCurrencyId,
-	)
+	query := selectQuery + ` WHERE ExchangeRates.BaseCurrencyId = ? AND ExchangeRates.TargetCurrencyId = ?`
+	tracing.Statement(span, query)
+
+	row := db.QueryRowContext(ctx, query, baseCurrencyId, targetCurrencyId)
 	if row.Err() != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, row.Err())
 
 		return entity.ExchangeRates{}, row.Err()
 	}
 
-	exchangeRates := entity.ExchangeRates{}
-	baseCurrency := entity.Currency{}
-	targetCurrency := entity.Currency{}
-
-	err = row.Scan(
-		&exchangeRates.ID,
-		&exchangeRates.Rate,
-		&baseCurrency.ID,
-		&baseCurrency.Code,
-		&baseCurrency.FullName,
-		&baseCurrency.Sign,
-		&targetCurrency.ID,
-		&targetCurrency.Code,
-		&targetCurrency.FullName,
-		&targetCurrency.Sign,
-	)
+	exchangeRates, err := c.scan(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return entity.ExchangeRates{}, storage.EntitiesNotFoundError
 		}
 
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return entity.ExchangeRates{}, err
 	}
 
-	exchangeRates.BaseCurrency = baseCurrency
-	exchangeRates.TargetCurrency = targetCurrency
-
 	return exchangeRates, nil
 }
 
-func (c *ExchangeRates) UpdateRate(exchangeRates entity.ExchangeRates) error {
+func (c *ExchangeRates) UpdateRate(ctx context.Context, exchangeRates entity.ExchangeRates) error {
 	const op = "UpdateRate"
+	defer metrics.ObserveQuery("StorageExchangeRates", op, time.Now())
+
+	ctx, span := tracing.StartQuery(ctx, "StorageExchangeRates", op)
+	defer span.End()
 
 	db, err := sql.Open("sqlite3", c.pathToDb)
 	if err != nil {
@@ -232,29 +336,145 @@ func (c *ExchangeRates) UpdateRate(exchangeRates entity.ExchangeRates) error {
 	defer func(db *sql.DB) {
 		err := db.Close()
 		if err != nil {
-			util.LogError(f, op, err)
+			logging.ErrorContext(ctx, f, op, err)
 		}
 	}(db)
 
-	stmt, err := db.Prepare("UPDATE ExchangeRates SET Rate = ? WHERE ID = ?")
+	query := "UPDATE ExchangeRates SET Rate = ?, UpdatedAt = ?, Version = Version + 1 WHERE ID = ? AND Version = ?"
+	tracing.Statement(span, query)
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		util.LogError(f, op, err)
+		logging.ErrorContext(ctx, f, op, err)
 
 		return err
 	}
-	defer func(stmt *sql.Stmt) {
-		err := stmt.Close()
+	defer storage.Rollback(tx)
+
+	exchangeRates.UpdatedAt = c.updatedAt(exchangeRates)
+
+	exec, err := tx.ExecContext(
+		ctx,
+		query,
+		exchangeRates.Rate,
+		exchangeRates.UpdatedAt,
+		exchangeRates.ID,
+		exchangeRates.Version,
+	)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return err
+	}
+
+	if affected == 0 {
+		return storage.EntityChangedError
+	}
+
+	exchangeRates.Version++
+
+	err = c.commit(ctx, tx, events.RateUpdated, exchangeRates)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// commit queues the event of the written rate in the outbox and commits tx, the event is published then.
+func (c *ExchangeRates) commit(
+	ctx context.Context,
+	tx *sql.Tx,
+	eventType events.Type,
+	exchangeRates entity.ExchangeRates,
+) error {
+	event := events.Event{
+		Type:         eventType,
+		ExchangeRate: exchangeRates,
+		OccurredAt:   exchangeRates.UpdatedAt,
+	}
+
+	if c.outbox != nil {
+		err := c.outbox.Enqueue(ctx, tx, event)
 		if err != nil {
-			util.LogError(f, op, err)
+			return err
 		}
-	}(stmt)
+	}
 
-	_, err = stmt.Exec(exchangeRates.Rate, exchangeRates.ID)
+	err := tx.Commit()
 	if err != nil {
-		util.LogError(f, op, err)
-
 		return err
 	}
 
+	if c.publisher != nil {
+		c.publisher.Publish(event)
+	}
+
 	return nil
 }
+
+// updatedAt returns the modification time set by the caller, or the current time.
+func (c *ExchangeRates) updatedAt(exchangeRates entity.ExchangeRates) time.Time {
+	if exchangeRates.UpdatedAt.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return exchangeRates.UpdatedAt.UTC()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (c *ExchangeRates) scan(row scanner) (entity.ExchangeRates, error) {
+	exchangeRates := entity.ExchangeRates{}
+	baseCurrency := entity.Currency{}
+	targetCurrency := entity.Currency{}
+
+	err := row.Scan(
+		&exchangeRates.ID,
+		&exchangeRates.Rate,
+		&exchangeRates.UpdatedAt,
+		&exchangeRates.Version,
+		&baseCurrency.ID,
+		&baseCurrency.Code,
+		&baseCurrency.FullName,
+		&baseCurrency.Sign,
+		&targetCurrency.ID,
+		&targetCurrency.Code,
+		&targetCurrency.FullName,
+		&targetCurrency.Sign,
+	)
+	if err != nil {
+		return entity.ExchangeRates{}, err
+	}
+
+	exchangeRates.BaseCurrency = baseCurrency
+	exchangeRates.TargetCurrency = targetCurrency
+
+	return exchangeRates, nil
+}
+
+func (c *ExchangeRates) scanAll(rows *sql.Rows) ([]entity.ExchangeRates, error) {
+	exchangeRates := []entity.ExchangeRates{}
+
+	for rows.Next() {
+		exchangeRate, err := c.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		exchangeRates = append(exchangeRates, exchangeRate)
+	}
+
+	return exchangeRates, rows.Err()
+}
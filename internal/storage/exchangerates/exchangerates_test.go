@@ -0,0 +1,99 @@
+package exchangerates
+
+import (
+	"context"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestList(t *testing.T) {
+	c := &config.Config{PathToDB: filepath.Join(t.TempDir(), "sqlite.db")}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currencies := storageCurrencies.New(c.PathToDB, nil, nil)
+	ids := map[string]int64{}
+
+	for _, code := range []string{"USD", "EUR", "RUB", "GBP"} {
+		ids[code], err = currencies.Add(context.Background(), entity.Currency{Code: code, FullName: code, Sign: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exchangeRates := New(c.PathToDB, nil, nil)
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, rate := range []struct {
+		base, target string
+		rate         float64
+		updated      time.Duration
+	}{
+		{base: "USD", target: "EUR", rate: 0.9, updated: 3 * time.Hour},
+		{base: "USD", target: "RUB", rate: 90, updated: time.Hour},
+		{base: "GBP", target: "EUR", rate: 1.2, updated: 4 * time.Hour},
+		{base: "EUR", target: "RUB", rate: 100, updated: 2 * time.Hour},
+	} {
+		_, err = exchangeRates.Add(context.Background(), entity.ExchangeRates{
+			BaseCurrency:   entity.Currency{ID: ids[rate.base]},
+			TargetCurrency: entity.Currency{ID: ids[rate.target]},
+			Rate:           rate.rate,
+			UpdatedAt:      updated.Add(rate.updated),
+		})
+		if err != nil {
+			t.Fatalf("rate %d: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+		total  int64
+	}{
+		{name: "all by id", want: "USDEUR,USDRUB,GBPEUR,EURRUB", total: 4},
+		{name: "by codes", filter: Filter{ListOptions: storage.ListOptions{Sort: "code"}}, want: "EURRUB,GBPEUR,USDEUR,USDRUB", total: 4},
+		{name: "by rate descending", filter: Filter{ListOptions: storage.ListOptions{Sort: "rate", Desc: true}}, want: "EURRUB,USDRUB,GBPEUR,USDEUR", total: 4},
+		{name: "by update time", filter: Filter{ListOptions: storage.ListOptions{Sort: "updated"}}, want: "USDRUB,EURRUB,USDEUR,GBPEUR", total: 4},
+		{name: "base", filter: Filter{Base: "USD"}, want: "USDEUR,USDRUB", total: 2},
+		{name: "target", filter: Filter{Target: "EUR"}, want: "USDEUR,GBPEUR", total: 2},
+		{name: "base and target", filter: Filter{Base: "GBP", Target: "EUR"}, want: "GBPEUR", total: 1},
+		{name: "unknown base", filter: Filter{Base: "CHF"}, total: 0},
+		{name: "first page", filter: Filter{ListOptions: storage.ListOptions{Limit: 3, Sort: "rate"}}, want: "USDEUR,GBPEUR,USDRUB", total: 4},
+		{name: "last page", filter: Filter{ListOptions: storage.ListOptions{Limit: 3, Offset: 3, Sort: "rate"}}, want: "EURRUB", total: 4},
+		{
+			name:   "filter and page",
+			filter: Filter{Target: "RUB", ListOptions: storage.ListOptions{Limit: 1, Offset: 1, Sort: "rate"}},
+			want:   "EURRUB",
+			total:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, total, err := exchangeRates.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pairs := make([]string, 0, len(list))
+			for _, rate := range list {
+				pairs = append(pairs, rate.BaseCurrency.Code+rate.TargetCurrency.Code)
+			}
+
+			if strings.Join(pairs, ",") != tt.want || total != tt.total {
+				t.Errorf("rates %v of %d, want %s of %d", pairs, total, tt.want, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+)
+
+const f = "storage.Idempotency"
+
+type StorageIdempotency interface {
+	Start(record entity.IdempotencyRecord) (entity.IdempotencyRecord, bool, error)
+	Complete(record entity.IdempotencyRecord) error
+	Delete(client, key string) error
+}
+
+type Idempotency struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *Idempotency {
+	return &Idempotency{
+		pathToDb: pathToDb,
+	}
+}
+
+// Start stores the record of a request in progress and returns it along with true. If the client has used
+// the key already, the stored record is returned along with false. Expired records are removed first.
+func (c *Idempotency) Start(record entity.IdempotencyRecord) (entity.IdempotencyRecord, bool, error) {
+	const op = "Start"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec("DELETE FROM IdempotencyKeys WHERE ExpiresAt <= ?", record.CreatedAt.UTC())
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	exec, err := db.Exec(
+		`INSERT INTO IdempotencyKeys (Client, Key, RequestHash, CreatedAt, ExpiresAt) VALUES (?, ?, ?, ?, ?)
+                             ON CONFLICT (Client, Key) DO NOTHING`,
+		record.Client,
+		record.Key,
+		record.RequestHash,
+		record.CreatedAt.UTC(),
+		record.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	if affected == 1 {
+		return record, true, nil
+	}
+
+	stored := entity.IdempotencyRecord{}
+
+	var headers string
+
+	err = db.QueryRow(
+		`SELECT Client, Key, RequestHash, Status, Headers, Body, CreatedAt, ExpiresAt FROM IdempotencyKeys
+                 WHERE Client = ? AND Key = ?`,
+		record.Client,
+		record.Key,
+	).Scan(
+		&stored.Client,
+		&stored.Key,
+		&stored.RequestHash,
+		&stored.Status,
+		&headers,
+		&stored.Body,
+		&stored.CreatedAt,
+		&stored.ExpiresAt,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	err = json.Unmarshal([]byte(headers), &stored.Headers)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	return stored, false, nil
+}
+
+// Complete stores the response of the record.
+func (c *Idempotency) Complete(record entity.IdempotencyRecord) error {
+	const op = "Complete"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE IdempotencyKeys SET Status = ?, Headers = ?, Body = ? WHERE Client = ? AND Key = ?",
+		record.Status,
+		string(headers),
+		record.Body,
+		record.Client,
+		record.Key,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the record, so the key may be used again.
+func (c *Idempotency) Delete(client, key string) error {
+	const op = "Delete"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec("DELETE FROM IdempotencyKeys WHERE Client = ? AND Key = ?", client, key)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var InvalidCursorError = errors.New("invalid cursor")
+
+// ListOptions describes which slice of a sorted table is requested.
+// Limit 0 means the whole table.
+type ListOptions struct {
+	Limit  int64
+	Offset int64
+	Sort   string
+	Desc   bool
+}
+
+// NextOffset returns the offset of the page following the one of size count,
+// or -1 if there is nothing left.
+func (o ListOptions) NextOffset(count int, total int64) int64 {
+	if o.Limit <= 0 {
+		return -1
+	}
+
+	next := o.Offset + int64(count)
+	if count == 0 || next >= total {
+		return -1
+	}
+
+	return next
+}
+
+// Direction returns the SQL sort direction.
+func (o ListOptions) Direction() string {
+	if o.Desc {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// EncodeCursor returns an opaque cursor pointing to offset.
+func EncodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.FormatInt(offset, 10)))
+}
+
+// DecodeCursor returns the offset the cursor points to.
+func DecodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, InvalidCursorError
+	}
+
+	value, found := strings.CutPrefix(string(raw), "o:")
+	if !found {
+		return 0, InvalidCursorError
+	}
+
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, InvalidCursorError
+	}
+
+	return offset, nil
+}
+
+// EscapeLike escapes the LIKE wildcards in s, so it can be used with ESCAPE '\'.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		offset int64
+		err    error
+	}{
+		{name: "encoded offset", cursor: EncodeCursor(40), offset: 40},
+		{name: "first row", cursor: EncodeCursor(0), offset: 0},
+		{name: "not base64", cursor: "o:40", err: InvalidCursorError},
+		{name: "without the prefix", cursor: base64.RawURLEncoding.EncodeToString([]byte("40")), err: InvalidCursorError},
+		{name: "not a number", cursor: base64.RawURLEncoding.EncodeToString([]byte("o:x")), err: InvalidCursorError},
+		{name: "negative offset", cursor: base64.RawURLEncoding.EncodeToString([]byte("o:-1")), err: InvalidCursorError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, err := DecodeCursor(tt.cursor)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+
+			if offset != tt.offset {
+				t.Errorf("offset %d, want %d", offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestNextOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ListOptions
+		count   int
+		total   int64
+		want    int64
+	}{
+		{name: "first page", options: ListOptions{Limit: 2}, count: 2, total: 5, want: 2},
+		{name: "middle page", options: ListOptions{Limit: 2, Offset: 2}, count: 2, total: 5, want: 4},
+		{name: "last page", options: ListOptions{Limit: 2, Offset: 4}, count: 1, total: 5, want: -1},
+		{name: "page ending with the table", options: ListOptions{Limit: 2, Offset: 2}, count: 2, total: 4, want: -1},
+		{name: "past the end", options: ListOptions{Limit: 2, Offset: 10}, total: 5, want: -1},
+		{name: "whole table", count: 5, total: 5, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.options.NextOffset(tt.count, tt.total)
+			if got != tt.want {
+				t.Errorf("next offset %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	got := EscapeLike(`10%_off\`)
+	if got != `10\%\_off\\` {
+		t.Errorf("escaped %q, want the wildcards and the escape character escaped", got)
+	}
+}
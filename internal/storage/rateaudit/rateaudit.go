@@ -0,0 +1,192 @@
+package rateaudit
+
+import (
+	"database/sql"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+)
+
+const f = "storage.RateAudit"
+
+const selectQuery = `SELECT ID, ExchangeRateId, BaseCurrencyCode, TargetCurrencyCode, Action, OldRate, NewRate,
+       Subject, ProposalId, ProposedBy, RequestId, ChangedAt FROM RateAudit`
+
+type StorageRateAudit interface {
+	Add(entry entity.RateAudit) (int64, error)
+	List(filter Filter) ([]entity.RateAudit, int64, error)
+}
+
+// Filter selects the entries of the base and the target currency, if given.
+type Filter struct {
+	Base   string
+	Target string
+	storage.ListOptions
+}
+
+type RateAudit struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *RateAudit {
+	return &RateAudit{
+		pathToDb: pathToDb,
+	}
+}
+
+func (c *RateAudit) Add(entry entity.RateAudit) (int64, error) {
+	const op = "Add"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		`INSERT INTO RateAudit (ExchangeRateId, BaseCurrencyCode, TargetCurrencyCode, Action, OldRate, NewRate,
+                       Subject, ProposalId, ProposedBy, RequestId, ChangedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ExchangeRateID,
+		entry.BaseCurrencyCode,
+		entry.TargetCurrencyCode,
+		entry.Action,
+		entry.OldRate,
+		entry.NewRate,
+		entry.Subject,
+		entry.ProposalID,
+		entry.ProposedBy,
+		entry.RequestID,
+		entry.ChangedAt.UTC(),
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// List returns the entries, the newest first, and their total number.
+func (c *RateAudit) List(filter Filter) ([]entity.RateAudit, int64, error) {
+	const op = "List"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	conditions := "1 = 1"
+	args := []interface{}{}
+
+	if filter.Base != "" {
+		conditions += " AND BaseCurrencyCode = ?"
+		args = append(args, filter.Base)
+	}
+
+	if filter.Target != "" {
+		conditions += " AND TargetCurrencyCode = ?"
+		args = append(args, filter.Target)
+	}
+
+	var total int64
+	err = db.QueryRow("SELECT COUNT(*) FROM RateAudit WHERE "+conditions, args...).Scan(&total)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+
+	query := selectQuery + " WHERE " + conditions + " ORDER BY ID DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	stmt, err := db.Query(query, args...)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	entries := []entity.RateAudit{}
+
+	for stmt.Next() {
+		entry, err := c.scan(stmt)
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return nil, 0, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if stmt.Err() != nil {
+		logging.Error(f, op, stmt.Err())
+
+		return nil, 0, stmt.Err()
+	}
+
+	return entries, total, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (c *RateAudit) scan(row scanner) (entity.RateAudit, error) {
+	entry := entity.RateAudit{}
+
+	var oldRate sql.NullFloat64
+
+	err := row.Scan(
+		&entry.ID,
+		&entry.ExchangeRateID,
+		&entry.BaseCurrencyCode,
+		&entry.TargetCurrencyCode,
+		&entry.Action,
+		&oldRate,
+		&entry.NewRate,
+		&entry.Subject,
+		&entry.ProposalID,
+		&entry.ProposedBy,
+		&entry.RequestID,
+		&entry.ChangedAt,
+	)
+	if err != nil {
+		return entity.RateAudit{}, err
+	}
+
+	if oldRate.Valid {
+		entry.OldRate = &oldRate.Float64
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,362 @@
+package rateproposals
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"time"
+)
+
+const f = "storage.RateProposals"
+
+const selectQuery = `SELECT ID, Action, ExchangeRateId, BaseCurrencyCode, TargetCurrencyCode, OldRate,
+       ExchangeRateVersion, Rate, Status, ProposedBy, DecidedBy, Reason, CreatedAt, ExpiresAt, DecidedAt FROM RateProposals`
+
+type StorageRateProposals interface {
+	ById(id int64) (entity.RateProposal, error)
+	List(filter Filter) ([]entity.RateProposal, int64, error)
+	Add(proposal entity.RateProposal) (int64, error)
+	Decide(proposal entity.RateProposal) error
+	Reopen(id int64) error
+	SetExchangeRateId(id, exchangeRateId int64) error
+	Expire(now time.Time) error
+}
+
+// Filter selects the proposals of the status, all if it is empty.
+type Filter struct {
+	Status string
+	storage.ListOptions
+}
+
+type RateProposals struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *RateProposals {
+	return &RateProposals{
+		pathToDb: pathToDb,
+	}
+}
+
+func (c *RateProposals) ById(id int64) (entity.RateProposal, error) {
+	const op = "ById"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	proposal, err := c.scan(db.QueryRow(selectQuery+" WHERE ID = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.RateProposal{}, storage.EntitiesNotFoundError
+		}
+
+		logging.Error(f, op, err)
+
+		return entity.RateProposal{}, err
+	}
+
+	return proposal, nil
+}
+
+// List returns the proposals, the newest first, and their total number.
+func (c *RateProposals) List(filter Filter) ([]entity.RateProposal, int64, error) {
+	const op = "List"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	conditions := "1 = 1"
+	args := []interface{}{}
+
+	if filter.Status != "" {
+		conditions += " AND Status = ?"
+		args = append(args, filter.Status)
+	}
+
+	var total int64
+	err = db.QueryRow("SELECT COUNT(*) FROM RateProposals WHERE "+conditions, args...).Scan(&total)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+
+	query := selectQuery + " WHERE " + conditions + " ORDER BY ID DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	stmt, err := db.Query(query, args...)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	proposals := []entity.RateProposal{}
+
+	for stmt.Next() {
+		proposal, err := c.scan(stmt)
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return nil, 0, err
+		}
+
+		proposals = append(proposals, proposal)
+	}
+
+	if stmt.Err() != nil {
+		logging.Error(f, op, stmt.Err())
+
+		return nil, 0, stmt.Err()
+	}
+
+	return proposals, total, nil
+}
+
+func (c *RateProposals) Add(proposal entity.RateProposal) (int64, error) {
+	const op = "Add"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		`INSERT INTO RateProposals (Action, ExchangeRateId, BaseCurrencyCode, TargetCurrencyCode, OldRate,
+                           ExchangeRateVersion, Rate, Status, ProposedBy, CreatedAt, ExpiresAt)
+                           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		proposal.Action,
+		proposal.ExchangeRateID,
+		proposal.BaseCurrencyCode,
+		proposal.TargetCurrencyCode,
+		proposal.OldRate,
+		proposal.ExchangeRateVersion,
+		proposal.Rate,
+		proposal.Status,
+		proposal.ProposedBy,
+		proposal.CreatedAt.UTC(),
+		proposal.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Decide stores the decision on the proposal, its status, decider, reason and rate id. It returns
+// storage.EntitiesNotFoundError if the proposal is not pending anymore.
+func (c *RateProposals) Decide(proposal entity.RateProposal) error {
+	const op = "Decide"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		`UPDATE RateProposals SET Status = ?, DecidedBy = ?, Reason = ?, ExchangeRateId = ?, DecidedAt = ?
+                     WHERE ID = ? AND Status = ?`,
+		proposal.Status,
+		proposal.DecidedBy,
+		proposal.Reason,
+		proposal.ExchangeRateID,
+		proposal.DecidedAt.UTC(),
+		proposal.ID,
+		entity.ProposalPending,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	if affected == 0 {
+		return storage.EntitiesNotFoundError
+	}
+
+	return nil
+}
+
+// Reopen returns the approved proposal to pending, when the approval claimed it but could not be applied.
+func (c *RateProposals) Reopen(id int64) error {
+	const op = "Reopen"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec(
+		"UPDATE RateProposals SET Status = ?, DecidedBy = '', DecidedAt = NULL WHERE ID = ? AND Status = ?",
+		entity.ProposalPending,
+		id,
+		entity.ProposalApproved,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// SetExchangeRateId links the proposal to the rate it created.
+func (c *RateProposals) SetExchangeRateId(id, exchangeRateId int64) error {
+	const op = "SetExchangeRateId"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec("UPDATE RateProposals SET ExchangeRateId = ? WHERE ID = ?", exchangeRateId, id)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Expire marks the pending proposals expired by now, they count as decided at their expiry.
+func (c *RateProposals) Expire(now time.Time) error {
+	const op = "Expire"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec(
+		"UPDATE RateProposals SET Status = ?, DecidedAt = ExpiresAt WHERE Status = ? AND ExpiresAt <= ?",
+		entity.ProposalExpired,
+		entity.ProposalPending,
+		now.UTC(),
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (c *RateProposals) scan(row scanner) (entity.RateProposal, error) {
+	proposal := entity.RateProposal{}
+
+	var oldRate sql.NullFloat64
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&proposal.ID,
+		&proposal.Action,
+		&proposal.ExchangeRateID,
+		&proposal.BaseCurrencyCode,
+		&proposal.TargetCurrencyCode,
+		&oldRate,
+		&proposal.ExchangeRateVersion,
+		&proposal.Rate,
+		&proposal.Status,
+		&proposal.ProposedBy,
+		&proposal.DecidedBy,
+		&proposal.Reason,
+		&proposal.CreatedAt,
+		&proposal.ExpiresAt,
+		&decidedAt,
+	)
+	if err != nil {
+		return entity.RateProposal{}, err
+	}
+
+	if oldRate.Valid {
+		proposal.OldRate = &oldRate.Float64
+	}
+
+	if decidedAt.Valid {
+		proposal.DecidedAt = &decidedAt.Time
+	}
+
+	return proposal, nil
+}
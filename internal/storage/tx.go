@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+)
+
+// Rollback rolls tx back unless it is committed, it is deferred by the methods writing in a transaction.
+func Rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logging.Error("storage", "Rollback", err)
+	}
+}
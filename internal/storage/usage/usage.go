@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"math"
+)
+
+const f = "storage.Usage"
+
+var QuotaExceededError = errors.New("daily quota exceeded")
+
+type StorageUsage interface {
+	Count(client, route, day string, quota int64) (int64, error)
+	List(filter Filter) ([]entity.Usage, int64, error)
+}
+
+// Filter selects the usage of the client and of the days from From to To, each optional.
+type Filter struct {
+	Client string
+	From   string
+	To     string
+	storage.ListOptions
+}
+
+type Usage struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *Usage {
+	return &Usage{
+		pathToDb: pathToDb,
+	}
+}
+
+// Count counts a request of the client and returns the number of its requests on the day. It returns
+// QuotaExceededError without counting the request if the client has made quota requests already, zero quota
+// standing for no limit.
+func (c *Usage) Count(client, route, day string, quota int64) (int64, error) {
+	const op = "Count"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	if quota <= 0 {
+		quota = math.MaxInt64
+	}
+
+	var requests int64
+
+	err = db.QueryRow(
+		`INSERT INTO Usage (Client, Route, Day, Requests) VALUES (?, ?, ?, 1)
+                      ON CONFLICT (Client, Route, Day) DO UPDATE SET Requests = Requests + 1 WHERE Requests < ?
+                      RETURNING Requests`,
+		client,
+		route,
+		day,
+		quota,
+	).Scan(&requests)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, QuotaExceededError
+		}
+
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	return requests, nil
+}
+
+// List returns the usage, the latest days first, and the total number of its rows.
+func (c *Usage) List(filter Filter) ([]entity.Usage, int64, error) {
+	const op = "List"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	conditions := "1 = 1"
+	args := []interface{}{}
+
+	if filter.Client != "" {
+		conditions += " AND Client = ?"
+		args = append(args, filter.Client)
+	}
+
+	if filter.From != "" {
+		conditions += " AND Day >= ?"
+		args = append(args, filter.From)
+	}
+
+	if filter.To != "" {
+		conditions += " AND Day <= ?"
+		args = append(args, filter.To)
+	}
+
+	var total int64
+	err = db.QueryRow("SELECT COUNT(*) FROM Usage WHERE "+conditions, args...).Scan(&total)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+
+	query := "SELECT Client, Route, Day, Requests FROM Usage WHERE " + conditions +
+		" ORDER BY Day DESC, Client, Route"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	stmt, err := db.Query(query, args...)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	items := []entity.Usage{}
+
+	for stmt.Next() {
+		item := entity.Usage{}
+
+		err = stmt.Scan(&item.Client, &item.Route, &item.Day, &item.Requests)
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return nil, 0, err
+		}
+
+		items = append(items, item)
+	}
+
+	if stmt.Err() != nil {
+		logging.Error(f, op, stmt.Err())
+
+		return nil, 0, stmt.Err()
+	}
+
+	return items, total, nil
+}
@@ -0,0 +1,501 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"strings"
+	"time"
+)
+
+const f = "storage.Webhooks"
+
+const selectDeliveryQuery = `SELECT WebhookDeliveries.ID, WebhookDeliveries.WebhookId, WebhookDeliveries.EventId,
+			WebhookDeliveries.EventType, WebhookDeliveries.Payload, WebhookDeliveries.Status,
+			WebhookDeliveries.Attempts, WebhookDeliveries.NextAttemptAt, WebhookDeliveries.LastAttemptAt,
+			WebhookDeliveries.ResponseStatus, WebhookDeliveries.LastError, WebhookDeliveries.CreatedAt,
+			Webhooks.Url, Webhooks.Secret
+		FROM WebhookDeliveries
+		JOIN Webhooks ON Webhooks.ID = WebhookDeliveries.WebhookId`
+
+// StorageWebhooks keeps the webhooks and the queue of their deliveries.
+type StorageWebhooks interface {
+	All() ([]entity.Webhook, error)
+	ById(id int64) (entity.Webhook, error)
+	Add(webhook entity.Webhook) (int64, error)
+	Delete(id int64) error
+	Enqueue(ctx context.Context, tx *sql.Tx, delivery entity.WebhookDelivery) (int64, error)
+	Due(now time.Time, limit int64) ([]entity.WebhookDelivery, error)
+	UpdateDelivery(delivery entity.WebhookDelivery) error
+	Deliveries(webhookId int64, filter DeliveriesFilter) ([]entity.WebhookDelivery, int64, error)
+	Redeliver(webhookId, id int64) error
+}
+
+// DeliveriesFilter narrows down Deliveries, an empty Status matches all deliveries.
+// The deliveries are listed from the newest.
+type DeliveriesFilter struct {
+	Status string
+	storage.ListOptions
+}
+
+type Webhooks struct {
+	pathToDb string
+}
+
+func New(pathToDb string) *Webhooks {
+	return &Webhooks{
+		pathToDb: pathToDb,
+	}
+}
+
+func (c *Webhooks) All() ([]entity.Webhook, error) {
+	const op = "All"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	stmt, err := db.Query("SELECT ID, Url, Events, CreatedAt FROM Webhooks ORDER BY ID")
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	webhooks := []entity.Webhook{}
+
+	for stmt.Next() {
+		webhook, err := c.scan(stmt)
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if stmt.Err() != nil {
+		logging.Error(f, op, stmt.Err())
+
+		return nil, stmt.Err()
+	}
+
+	return webhooks, nil
+}
+
+// ById returns the webhook without its secret.
+func (c *Webhooks) ById(id int64) (entity.Webhook, error) {
+	const op = "ById"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	webhook, err := c.scan(db.QueryRow("SELECT ID, Url, Events, CreatedAt FROM Webhooks WHERE ID = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.Webhook{}, storage.EntitiesNotFoundError
+		}
+
+		logging.Error(f, op, err)
+
+		return entity.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (c *Webhooks) Add(webhook entity.Webhook) (int64, error) {
+	const op = "Add"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		"INSERT INTO Webhooks (Url, Secret, Events, CreatedAt) VALUES (?, ?, ?, ?)",
+		webhook.URL,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+		webhook.CreatedAt.UTC(),
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	id, err := exec.LastInsertId()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Delete removes the webhook along with its deliveries.
+func (c *Webhooks) Delete(id int64) error {
+	const op = "Delete"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	tx, err := db.Begin()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	exec, err := tx.Exec("DELETE FROM Webhooks WHERE ID = ?", id)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM WebhookDeliveries WHERE WebhookId = ?", id)
+	}
+
+	if err != nil {
+		_ = tx.Rollback()
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil || affected == 0 {
+		_ = tx.Rollback()
+
+		if err != nil {
+			logging.Error(f, op, err)
+
+			return err
+		}
+
+		return storage.EntitiesNotFoundError
+	}
+
+	return tx.Commit()
+}
+
+// Enqueue queues the delivery for every webhook receiving its event type in tx, the transaction writing the change
+// of the event, and returns the number of queued deliveries.
+func (c *Webhooks) Enqueue(ctx context.Context, tx *sql.Tx, delivery entity.WebhookDelivery) (int64, error) {
+	const op = "Enqueue"
+
+	exec, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO WebhookDeliveries (WebhookId, EventId, EventType, Payload, Status, NextAttemptAt, CreatedAt)
+		SELECT ID, ?, ?, ?, ?, ?, ? FROM Webhooks
+		WHERE Events = '' OR instr(',' || Events || ',', ?) > 0`,
+		delivery.EventID,
+		delivery.EventType,
+		string(delivery.Payload),
+		entity.DeliveryPending,
+		delivery.CreatedAt.UTC(),
+		delivery.CreatedAt.UTC(),
+		","+delivery.EventType+",",
+	)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return 0, err
+	}
+
+	queued, err := exec.RowsAffected()
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return 0, err
+	}
+
+	return queued, nil
+}
+
+// Due returns up to limit pending deliveries whose next attempt is due at now, the oldest first.
+func (c *Webhooks) Due(now time.Time, limit int64) ([]entity.WebhookDelivery, error) {
+	const op = "Due"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	stmt, err := db.Query(
+		selectDeliveryQuery+` WHERE WebhookDeliveries.Status = ? AND WebhookDeliveries.NextAttemptAt <= ?
+		ORDER BY WebhookDeliveries.NextAttemptAt, WebhookDeliveries.ID LIMIT ?`,
+		entity.DeliveryPending,
+		now.UTC(),
+		limit,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	deliveries, err := c.scanDeliveries(stmt)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the status, the attempts and the outcome of the last attempt of the delivery.
+func (c *Webhooks) UpdateDelivery(delivery entity.WebhookDelivery) error {
+	const op = "UpdateDelivery"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	_, err = db.Exec(
+		`UPDATE WebhookDeliveries
+		SET Status = ?, Attempts = ?, NextAttemptAt = ?, LastAttemptAt = ?, ResponseStatus = ?, LastError = ?
+		WHERE ID = ?`,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.LastAttemptAt,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.ID,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Deliveries returns the page of deliveries of the webhook matching filter and the total number of matching deliveries.
+func (c *Webhooks) Deliveries(webhookId int64, filter DeliveriesFilter) ([]entity.WebhookDelivery, int64, error) {
+	const op = "Deliveries"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	conditions := "WebhookDeliveries.WebhookId = ?"
+	args := []interface{}{webhookId}
+
+	if filter.Status != "" {
+		conditions += " AND WebhookDeliveries.Status = ?"
+		args = append(args, filter.Status)
+	}
+
+	var total int64
+	err = db.QueryRow("SELECT COUNT(*) FROM WebhookDeliveries WHERE "+conditions, args...).Scan(&total)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+
+	query := selectDeliveryQuery + " WHERE " + conditions + " ORDER BY WebhookDeliveries.ID DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	stmt, err := db.Query(query, args...)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+	defer func(stmt *sql.Rows) {
+		err := stmt.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(stmt)
+
+	deliveries, err := c.scanDeliveries(stmt)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// Redeliver queues the dead delivery of the webhook again with a fresh number of attempts.
+// It returns storage.EntitiesNotFoundError if the webhook has no such dead delivery.
+func (c *Webhooks) Redeliver(webhookId, id int64) error {
+	const op = "Redeliver"
+
+	db, err := sql.Open("sqlite3", c.pathToDb)
+	if err != nil {
+		panic(err)
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logging.Error(f, op, err)
+		}
+	}(db)
+
+	exec, err := db.Exec(
+		`UPDATE WebhookDeliveries SET Status = ?, Attempts = 0, NextAttemptAt = ?
+		WHERE ID = ? AND WebhookId = ? AND Status = ?`,
+		entity.DeliveryPending,
+		time.Now().UTC(),
+		id,
+		webhookId,
+		entity.DeliveryDead,
+	)
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		logging.Error(f, op, err)
+
+		return err
+	}
+
+	if affected == 0 {
+		return storage.EntitiesNotFoundError
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (c *Webhooks) scan(row scanner) (entity.Webhook, error) {
+	webhook := entity.Webhook{}
+
+	var events string
+
+	err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.CreatedAt)
+	if err != nil {
+		return entity.Webhook{}, err
+	}
+
+	webhook.Events = []string{}
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+
+	return webhook, nil
+}
+
+func (c *Webhooks) scanDeliveries(rows *sql.Rows) ([]entity.WebhookDelivery, error) {
+	deliveries := []entity.WebhookDelivery{}
+
+	for rows.Next() {
+		delivery := entity.WebhookDelivery{}
+
+		var payload string
+		var lastAttemptAt sql.NullTime
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&lastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Payload = json.RawMessage(payload)
+
+		if lastAttemptAt.Valid {
+			delivery.LastAttemptAt = &lastAttemptAt.Time
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+// Exporters of the spans.
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+const serviceName = "currency-exchange"
+
+var tracer = otel.Tracer("github.com/albakov/go-currency-exchange")
+
+// Setup exports the spans as configured and propagates the W3C trace context of incoming requests.
+// The returned function flushes the spans not exported yet and closes the file of the file exporter, spans are
+// dropped if tracing is not configured.
+func Setup(config *config.Config) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, file, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter == nil {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TraceSampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	if file == nil {
+		return provider.Shutdown, nil
+	}
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), file.Close())
+	}, nil
+}
+
+// newExporter returns the exporter of the config along with the file it writes to, nil for the other exporters.
+func newExporter(config *config.Config) (sdktrace.SpanExporter, *os.File, error) {
+	switch config.TraceExporter {
+	case ExporterNone:
+		return nil, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+		return exporter, nil, err
+	case ExporterFile:
+		file, err := os.OpenFile(config.TraceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return nil, nil, errors.Join(err, file.Close())
+		}
+
+		return exporter, file, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.TraceEndpoint))
+
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", config.TraceExporter)
+	}
+}
+
+// Start starts a span named name as a child of the span of ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartServer starts the span of a request served under the route, as a child of the span propagated by the client.
+func StartServer(ctx context.Context, route string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, route, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// StartQuery starts the span of the method of the storage.
+func StartQuery(ctx context.Context, storage, method string) (context.Context, trace.Span) {
+	return tracer.Start(
+		ctx,
+		storage+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite),
+	)
+}
+
+// Statement attaches the SQL statement of the storage method to its span, the one reading the rows if it runs several.
+func Statement(span trace.Span, statement string) {
+	span.SetAttributes(semconv.DBQueryText(statement))
+}
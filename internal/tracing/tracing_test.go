@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// collector is an OTLP/HTTP collector stub keeping the spans it receives.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" ||
+		r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	var request coltracepb.ExportTraceServiceRequest
+
+	err = proto.Unmarshal(body, &request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	c.mu.Lock()
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+	c.mu.Unlock()
+
+	response, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(response)
+}
+
+func (c *collector) span(name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, span := range c.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+
+	return nil
+}
+
+func TestSetupExportsSpansToOTLPCollector(t *testing.T) {
+	stub := &collector{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	shutdown, err := Setup(&config.Config{Tracing: config.Tracing{
+		TraceExporter:    ExporterOTLP,
+		TraceEndpoint:    server.URL + "/v1/traces",
+		TraceSampleRatio: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+
+	// the span of a request continues the trace propagated by the client
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{
+		"traceparent": "00-" + traceID + "-" + parentID + "-01",
+	})
+
+	ctx, request := StartServer(ctx, "GET /v2/currencies")
+	_, query := StartQuery(ctx, "currencies", "All")
+	Statement(query, "SELECT 1")
+	query.End()
+	request.End()
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := stub.span("GET /v2/currencies")
+	if served == nil {
+		t.Fatal("span of the request not exported")
+	}
+
+	if hex.EncodeToString(served.TraceId) != traceID {
+		t.Errorf("trace id %x, want %s", served.TraceId, traceID)
+	}
+
+	if hex.EncodeToString(served.ParentSpanId) != parentID {
+		t.Errorf("parent span id %x, want %s", served.ParentSpanId, parentID)
+	}
+
+	if served.Kind != tracepb.Span_SPAN_KIND_SERVER {
+		t.Errorf("kind %v, want server", served.Kind)
+	}
+
+	queried := stub.span("currencies.All")
+	if queried == nil {
+		t.Fatal("span of the query not exported")
+	}
+
+	if string(queried.ParentSpanId) != string(served.SpanId) {
+		t.Errorf("parent of the query %x, want the request %x", queried.ParentSpanId, served.SpanId)
+	}
+
+	found := false
+	for _, attribute := range queried.Attributes {
+		if attribute.Key == "db.query.text" && attribute.Value.GetStringValue() == "SELECT 1" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("statement not among the attributes %v", queried.Attributes)
+	}
+}
+
+func TestSetupClosesTraceFileOnShutdown(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files not listed:", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := Setup(&config.Config{Tracing: config.Tracing{
+		TraceExporter:    ExporterFile,
+		TraceFile:        path,
+		TraceSampleRatio: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the package tracer stays bound to the provider of the first test
+	_, span := otel.Tracer("test").Start(context.Background(), "Exchange.direct")
+	span.End()
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(exported), `"Name":"Exchange.direct"`) {
+		t.Errorf("file %q, want the span flushed on shutdown", exported)
+	}
+
+	after, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) > len(fds) {
+		t.Errorf("%d files open after the shutdown, want %d", len(after), len(fds))
+	}
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/auth"
+	"net/http"
+	"slices"
+)
+
+func apiKeysFields() []Field {
+	return []Field{
+		{Name: "name", Rules: []Rule{Required(), Length(1, 64)}},
+		{Name: "scopes", Rules: []Rule{Required(), Check(isScopes)}},
+	}
+}
+
+// NewAPIKeys validates the body of a new API key, scopes is a comma separated list of scopes.
+func NewAPIKeys(r *http.Request) *Validator {
+	return newValidator(r, fromBody, apiKeysFields()...)
+}
+
+// NewAPIKeysValues validates the values of a new API key, like NewAPIKeys.
+func NewAPIKeysValues(values map[string]string) *Validator {
+	return NewValues(values, apiKeysFields()...)
+}
+
+func isScopes(value string) bool {
+	scopes := splitList(value)
+
+	for _, scope := range scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return false
+		}
+	}
+
+	return len(scopes) > 0
+}
@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxBodySize limits the size of request bodies read by the validators.
+const MaxBodySize = 64 << 10
+
+// fromQuery reads the fields from the URL query.
+func fromQuery(r *http.Request, fields []Field) (map[string]string, Errors, int) {
+	query := r.URL.Query()
+	values := map[string]string{}
+
+	for _, field := range fields {
+		values[field.Name] = query.Get(field.Name)
+	}
+
+	return values, nil, 0
+}
+
+// fromBody reads the fields from the request body, the format is chosen by the Content-Type header.
+func fromBody(r *http.Request, fields []Field) (map[string]string, Errors, int) {
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxBodySize)
+	}
+
+	if isJSON(r.Header.Get("Content-Type")) {
+		return fromJSON(r, fields)
+	}
+
+	return fromForm(r, fields)
+}
+
+func fromForm(r *http.Request, fields []Field) (map[string]string, Errors, int) {
+	err := r.ParseForm()
+	if err != nil {
+		return bodyError(err)
+	}
+
+	values := map[string]string{}
+
+	for _, field := range fields {
+		values[field.Name] = r.Form.Get(field.Name)
+	}
+
+	return values, nil, 0
+}
+
+func fromJSON(r *http.Request, fields []Field) (map[string]string, Errors, int) {
+	if r.Body == nil {
+		return nil, Errors{{Message: i18n.NewMessage(controller.CodeBodyInvalid)}}, http.StatusBadRequest
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bodyError(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	body := map[string]interface{}{}
+
+	err = decoder.Decode(&body)
+	if err != nil || decoder.More() {
+		return nil, Errors{{Message: i18n.NewMessage(controller.CodeBodyInvalid)}}, http.StatusBadRequest
+	}
+
+	errs := Errors{}
+
+	unknown := []string{}
+
+	for name := range body {
+		if !slices.ContainsFunc(fields, func(field Field) bool { return field.Name == name }) {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	for _, name := range unknown {
+		errs = append(errs, FieldError{Field: name, Message: i18n.NewMessage(controller.MessageFieldUnknown, name)})
+	}
+
+	values := map[string]string{}
+
+	for _, field := range fields {
+		switch v := body[field.Name].(type) {
+		case nil:
+			values[field.Name] = ""
+		case string:
+			values[field.Name] = v
+		case json.Number:
+			if !field.numeric() {
+				errs = append(errs, incorrectField(field.Name))
+
+				continue
+			}
+
+			values[field.Name] = v.String()
+		default:
+			errs = append(errs, incorrectField(field.Name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return values, errs, http.StatusBadRequest
+	}
+
+	return values, nil, 0
+}
+
+// bodyError turns a body read error into a request error.
+func bodyError(err error) (map[string]string, Errors, int) {
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		return nil, Errors{{Message: i18n.NewMessage(controller.CodeBodyTooLarge)}}, http.StatusRequestEntityTooLarge
+	}
+
+	return nil, Errors{{Message: i18n.NewMessage(controller.CodeBodyInvalid)}}, http.StatusBadRequest
+}
+
+func incorrectField(name string) FieldError {
+	return FieldError{Field: name, Message: i18n.NewMessage(controller.MessageFieldIncorrectError, name)}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,143 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+
+	return r
+}
+
+func TestNewExchangeRatesBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		invalid     []string
+	}{
+		{name: "JSON", contentType: "application/json", body: `{"baseCurrencyCode":"usd","targetCurrencyCode":"EUR","rate":0.9}`},
+		{
+			name:        "JSON with charset and rate as a string",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"baseCurrencyCode":"USD","targetCurrencyCode":"EUR","rate":"0.9"}`,
+		},
+		{name: "JSON suffix", contentType: "application/merge-patch+json", body: `{"baseCurrencyCode":"USD","targetCurrencyCode":"EUR","rate":0.9}`},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"baseCurrencyCode": {"USD"}, "targetCurrencyCode": {"EUR"}, "rate": {"0.9"}}.Encode(),
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"baseCurrencyCode":"USD","targetCurrencyCode":"EUR","rate":0.9,"comment":"x"}`,
+			status:      http.StatusBadRequest,
+			code:        controller.CodeValidationFailed,
+			invalid:     []string{"comment"},
+		},
+		{
+			name:        "type errors",
+			contentType: "application/json",
+			body:        `{"baseCurrencyCode":840,"targetCurrencyCode":["EUR"],"rate":true}`,
+			status:      http.StatusBadRequest,
+			code:        controller.CodeValidationFailed,
+			invalid:     []string{"baseCurrencyCode", "rate", "targetCurrencyCode"},
+		},
+		{
+			name:        "null as a missing field",
+			contentType: "application/json",
+			body:        `{"baseCurrencyCode":"USD","targetCurrencyCode":null,"rate":0.9}`,
+			status:      http.StatusBadRequest,
+			code:        controller.CodeValidationFailed,
+			invalid:     []string{"targetCurrencyCode"},
+		},
+		{name: "malformed JSON", contentType: "application/json", body: `{"rate":`, status: http.StatusBadRequest, code: controller.CodeBodyInvalid},
+		{name: "not an object", contentType: "application/json", body: `["USD"]`, status: http.StatusBadRequest, code: controller.CodeBodyInvalid},
+		{
+			name:        "two documents",
+			contentType: "application/json",
+			body:        `{"baseCurrencyCode":"USD","targetCurrencyCode":"EUR","rate":0.9}{}`,
+			status:      http.StatusBadRequest,
+			code:        controller.CodeBodyInvalid,
+		},
+		{
+			name:        "JSON too large",
+			contentType: "application/json",
+			body:        `{"baseCurrencyCode":"` + strings.Repeat("U", MaxBodySize) + `"}`,
+			status:      http.StatusRequestEntityTooLarge,
+			code:        controller.CodeBodyTooLarge,
+		},
+		{
+			name:        "form too large",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "baseCurrencyCode=" + strings.Repeat("U", MaxBodySize),
+			status:      http.StatusRequestEntityTooLarge,
+			code:        controller.CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewExchangeRates(jsonRequest(tt.contentType, tt.body))
+			v.Validate()
+
+			if v.StatusCode() != tt.status || v.Code() != tt.code {
+				t.Fatalf("status %d and code %q, want %d and %q", v.StatusCode(), v.Code(), tt.status, tt.code)
+			}
+
+			invalid := invalidFields(v)
+			if strings.Join(invalid, ",") != strings.Join(tt.invalid, ",") {
+				t.Errorf("invalid fields %v, want %v", invalid, tt.invalid)
+			}
+
+			if v.IsValid() && (v.Field("baseCurrencyCode") != "USD" || v.Float("rate") != 0.9) {
+				t.Errorf("base %q and rate %v, want USD and 0.9", v.Field("baseCurrencyCode"), v.Float("rate"))
+			}
+		})
+	}
+}
+
+func TestBodyErrorsMatchForm(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		form url.Values
+	}{
+		{name: "missing fields", json: `{}`, form: url.Values{}},
+		{
+			name: "invalid values",
+			json: `{"baseCurrencyCode":"US","targetCurrencyCode":"EURO","rate":"-1"}`,
+			form: url.Values{"baseCurrencyCode": {"US"}, "targetCurrencyCode": {"EURO"}, "rate": {"-1"}},
+		},
+		{
+			name: "rate as a negative number",
+			json: `{"baseCurrencyCode":"USD","targetCurrencyCode":"EUR","rate":-1}`,
+			form: url.Values{"baseCurrencyCode": {"USD"}, "targetCurrencyCode": {"EUR"}, "rate": {"-1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromJSON := NewExchangeRates(jsonRequest("application/json", tt.json))
+			fromJSON.Validate()
+
+			fromForm := NewExchangeRates(formRequest(tt.form))
+			fromForm.Validate()
+
+			if fromJSON.IsValid() || !reflect.DeepEqual(fromJSON.Errors(), fromForm.Errors()) {
+				t.Errorf("JSON errors %v, want the form errors %v", fromJSON.Errors(), fromForm.Errors())
+			}
+		})
+	}
+}
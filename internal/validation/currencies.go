@@ -1,46 +1,36 @@
 package validation
 
 import (
-	"fmt"
-	"github.com/albakov/go-currency-exchange/internal/controller"
 	"net/http"
 )
 
-type RequestCurrenciesAdd struct {
-	r            *http.Request
-	fields       map[string]string
-	errorMessage string
+// NewCurrencies validates the body of a new currency, nameField is the name of the full name field.
+func NewCurrencies(r *http.Request, nameField string) *Validator {
+	return newValidator(
+		r,
+		fromBody,
+		Field{Name: nameField, Rules: []Rule{Required(), Length(1, 64)}},
+		Field{Name: "code", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+		Field{Name: "sign", Rules: []Rule{Required(), Length(1, 8)}},
+	)
 }
 
-func NewCurrencies(r *http.Request, fields map[string]string) *RequestCurrenciesAdd {
-	return &RequestCurrenciesAdd{
-		r:      r,
-		fields: fields,
-	}
-}
-
-func (cc *RequestCurrenciesAdd) Validate() {
-	for field := range cc.fields {
-		v := cc.r.FormValue(field)
-
-		if v == "" {
-			cc.errorMessage = fmt.Sprintf(controller.MessageFieldEmpty, field)
+// currenciesSortable are the sort keys of the currency list.
+var currenciesSortable = []string{"id", "code", "name"}
 
-			return
-		}
-
-		cc.fields[field] = v
+func currenciesFilters() []Field {
+	return []Field{
+		{Name: "code", Rules: []Rule{Length(1, 3)}, Upper: true},
+		{Name: "name", Rules: []Rule{Length(1, 64)}},
 	}
 }
 
-func (cc *RequestCurrenciesAdd) IsValid() bool {
-	return cc.errorMessage == ""
-}
-
-func (cc *RequestCurrenciesAdd) ErrorMessage() string {
-	return cc.errorMessage
+// NewCurrenciesList validates the query of the currency list.
+func NewCurrenciesList(r *http.Request) *Validator {
+	return NewList(r, currenciesSortable, currenciesFilters()...)
 }
 
-func (cc *RequestCurrenciesAdd) Field(field string) string {
-	return cc.fields[field]
+// NewCurrenciesListValues validates the values of the currency list, like NewCurrenciesList.
+func NewCurrenciesListValues(values map[string]string) *Validator {
+	return NewListValues(values, currenciesSortable, currenciesFilters()...)
 }
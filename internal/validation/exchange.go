@@ -1,63 +1,23 @@
 package validation
 
 import (
-	"fmt"
-	"github.com/albakov/go-currency-exchange/internal/controller"
 	"net/http"
-	"strconv"
 )
 
-type RequestExchange struct {
-	r            *http.Request
-	fields       map[string]string
-	errorMessage string
-	amount       float64
-}
-
-func NewExchange(r *http.Request, fields map[string]string) *RequestExchange {
-	return &RequestExchange{
-		r:      r,
-		fields: fields,
-	}
-}
-
-func (re *RequestExchange) Validate() {
-	query := re.r.URL.Query()
-
-	for field := range re.fields {
-		v := query.Get(field)
-
-		if v == "" {
-			re.errorMessage = fmt.Sprintf(controller.MessageFieldEmpty, field)
-
-			return
-		}
-
-		re.fields[field] = v
+func exchangeFields() []Field {
+	return []Field{
+		{Name: "from", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+		{Name: "to", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+		{Name: "amount", Rules: []Rule{Required(), PositiveDecimal()}},
 	}
-
-	amount, err := strconv.ParseFloat(re.fields["amount"], 64)
-	if err != nil || amount <= 0 {
-		re.errorMessage = fmt.Sprintf(controller.MessageFieldIncorrectError, "amount")
-
-		return
-	}
-
-	re.amount = amount
-}
-
-func (re *RequestExchange) IsValid() bool {
-	return re.errorMessage == ""
-}
-
-func (re *RequestExchange) ErrorMessage() string {
-	return re.errorMessage
 }
 
-func (re *RequestExchange) Field(field string) string {
-	return re.fields[field]
+// NewExchange validates the query of a conversion.
+func NewExchange(r *http.Request) *Validator {
+	return newValidator(r, fromQuery, exchangeFields()...)
 }
 
-func (re *RequestExchange) Amount() float64 {
-	return re.amount
+// NewExchangeValues validates the values of a conversion, like NewExchange.
+func NewExchangeValues(values map[string]string) *Validator {
+	return NewValues(values, exchangeFields()...)
 }
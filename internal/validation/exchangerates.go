@@ -1,61 +1,62 @@
 package validation
 
 import (
-	"fmt"
-	"github.com/albakov/go-currency-exchange/internal/controller"
 	"net/http"
-	"strconv"
 )
 
-type RequestExchangeRatesAdd struct {
-	r            *http.Request
-	fields       map[string]string
-	errorMessage string
-	rate         float64
-}
-
-func NewExchangeRates(r *http.Request, fields map[string]string) *RequestExchangeRatesAdd {
-	return &RequestExchangeRatesAdd{
-		r:      r,
-		fields: fields,
+func exchangeRatesFields() []Field {
+	return []Field{
+		{Name: "baseCurrencyCode", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+		{Name: "targetCurrencyCode", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+		{Name: "rate", Rules: []Rule{Required(), PositiveDecimal()}},
 	}
 }
 
-func (er *RequestExchangeRatesAdd) Validate() {
-	for field := range er.fields {
-		v := er.r.FormValue(field)
+// NewExchangeRates validates the body of a new exchange rate.
+func NewExchangeRates(r *http.Request) *Validator {
+	return newValidator(r, fromBody, exchangeRatesFields()...)
+}
 
-		if v == "" {
-			er.errorMessage = fmt.Sprintf(controller.MessageFieldEmpty, field)
+// NewExchangeRatesValues validates the values of a new exchange rate, like NewExchangeRates.
+func NewExchangeRatesValues(values map[string]string) *Validator {
+	return NewValues(values, exchangeRatesFields()...)
+}
 
-			return
-		}
+// NewPairValues validates the currency codes of a pair.
+func NewPairValues(values map[string]string) *Validator {
+	return NewValues(values, exchangeRatesFields()[:2]...)
+}
 
-		er.fields[field] = v
-	}
+// NewExchangeRatesUpdate validates the body of an exchange rate update.
+func NewExchangeRatesUpdate(r *http.Request) *Validator {
+	return newValidator(
+		r,
+		fromBody,
+		Field{Name: "rate", Rules: []Rule{Required(), PositiveDecimal()}},
+	)
+}
 
-	rate, err := strconv.ParseFloat(er.fields["rate"], 64)
-	if err != nil || rate <= 0 {
-		er.errorMessage = fmt.Sprintf(controller.MessageFieldIncorrectError, "rate")
+// exchangeRatesSortable are the sort keys of the exchange rate list.
+var exchangeRatesSortable = []string{"id", "code", "rate", "updated"}
 
-		return
+func exchangeRatesFilters() []Field {
+	return []Field{
+		{Name: "base", Rules: []Rule{ISOCode()}, Upper: true},
+		{Name: "target", Rules: []Rule{ISOCode()}, Upper: true},
 	}
-
-	er.rate = rate
-}
-
-func (er *RequestExchangeRatesAdd) IsValid() bool {
-	return er.errorMessage == ""
 }
 
-func (er *RequestExchangeRatesAdd) ErrorMessage() string {
-	return er.errorMessage
+// NewExchangeRatesList validates the query of the exchange rate list.
+func NewExchangeRatesList(r *http.Request) *Validator {
+	return NewList(r, exchangeRatesSortable, exchangeRatesFilters()...)
 }
 
-func (er *RequestExchangeRatesAdd) Rate() float64 {
-	return er.rate
+// NewExchangeRatesListValues validates the values of the exchange rate list, like NewExchangeRatesList.
+func NewExchangeRatesListValues(values map[string]string) *Validator {
+	return NewListValues(values, exchangeRatesSortable, exchangeRatesFilters()...)
 }
 
-func (er *RequestExchangeRatesAdd) Field(field string) string {
-	return er.fields[field]
+// NewRateAudit validates the query of the rate audit log, filtered by the currencies of the pair.
+func NewRateAudit(r *http.Request) *Validator {
+	return NewList(r, nil, exchangeRatesFilters()...)
 }
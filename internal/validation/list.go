@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"net/http"
+	"strings"
+)
+
+const maxListLimit = 500
+
+// NewList validates the limit, cursor and sort query parameters shared by the list endpoints
+// along with the filters of the particular list.
+func NewList(r *http.Request, sortable []string, filters ...Field) *Validator {
+	return newValidator(r, fromQuery, append(listFields(sortable), filters...)...)
+}
+
+// NewListValues validates the limit, cursor and sort values along with the filters, like NewList.
+func NewListValues(values map[string]string, sortable []string, filters ...Field) *Validator {
+	return NewValues(values, append(listFields(sortable), filters...)...)
+}
+
+func listFields(sortable []string) []Field {
+	sorts := make([]string, 0, len(sortable)*2)
+
+	for _, key := range sortable {
+		sorts = append(sorts, key, "-"+key)
+	}
+
+	return []Field{
+		{Name: "limit", Rules: []Rule{Integer(1, maxListLimit)}},
+		{Name: "cursor", Rules: []Rule{Check(isCursor)}},
+		{Name: "sort", Rules: []Rule{Enum(sorts...)}},
+	}
+}
+
+// ListOptions returns the paging and sorting options of a query validated by NewList.
+func (v *Validator) ListOptions() storage.ListOptions {
+	options := storage.ListOptions{Limit: v.Int("limit")}
+	options.Offset, _ = storage.DecodeCursor(v.Field("cursor"))
+	options.Sort, options.Desc = strings.CutPrefix(v.Field("sort"), "-")
+
+	return options
+}
+
+func isCursor(value string) bool {
+	_, err := storage.DecodeCursor(value)
+
+	return err == nil
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewList(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		invalid []string
+		options storage.ListOptions
+	}{
+		{name: "no parameters", invalid: []string{}},
+		{
+			name:    "page sorted descending",
+			query:   "limit=20&cursor=" + storage.EncodeCursor(40) + "&sort=-rate",
+			invalid: []string{},
+			options: storage.ListOptions{Limit: 20, Offset: 40, Sort: "rate", Desc: true},
+		},
+		{name: "ascending sort", query: "sort=code", invalid: []string{}, options: storage.ListOptions{Sort: "code"}},
+		{name: "largest limit", query: "limit=500", invalid: []string{}, options: storage.ListOptions{Limit: 500}},
+		{name: "limit too large", query: "limit=501", invalid: []string{"limit"}},
+		{name: "zero limit", query: "limit=0", invalid: []string{"limit"}},
+		{name: "limit not a number", query: "limit=ten", invalid: []string{"limit"}},
+		{name: "malformed cursor", query: "cursor=o:40", invalid: []string{"cursor"}},
+		{name: "unknown sort", query: "sort=sign", invalid: []string{"sort"}},
+		{name: "doubled direction", query: "sort=--rate", invalid: []string{"sort"}},
+		{name: "filter", query: "base=usd", invalid: []string{"base"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			v := NewList(r, []string{"code", "rate"}, Field{Name: "base", Rules: []Rule{ISOCode()}})
+			v.Validate()
+
+			invalid := invalidFields(v)
+			if strings.Join(invalid, ",") != strings.Join(tt.invalid, ",") {
+				t.Fatalf("invalid fields %v, want %v", invalid, tt.invalid)
+			}
+
+			if v.IsValid() && !reflect.DeepEqual(v.ListOptions(), tt.options) {
+				t.Errorf("options %+v, want %+v", v.ListOptions(), tt.options)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"net/http"
+)
+
+// NewProposals validates the query of the rate proposal list.
+func NewProposals(r *http.Request) *Validator {
+	return NewList(
+		r,
+		nil,
+		Field{
+			Name: "status",
+			Rules: []Rule{
+				Enum(entity.ProposalPending, entity.ProposalApproved, entity.ProposalRejected, entity.ProposalExpired),
+			},
+		},
+	)
+}
+
+// NewProposalRejection validates the body of a rejection, its reason is optional.
+func NewProposalRejection(r *http.Request) *Validator {
+	return newValidator(r, fromBody, Field{Name: "reason", Rules: []Rule{Length(1, 1000)}})
+}
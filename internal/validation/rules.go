@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	isoCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// Rule checks a field value and returns an error message, or an empty message if the value is valid.
+// Rules other than Required are skipped for empty values.
+type Rule struct {
+	check    func(field, value string) i18n.Message
+	required bool
+	// numeric allows sending the value as a JSON number
+	numeric bool
+}
+
+// Required rejects empty values.
+func Required() Rule {
+	return Rule{
+		required: true,
+		check: func(field, value string) i18n.Message {
+			if value == "" {
+				return i18n.NewMessage(controller.MessageFieldEmpty, field)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// ISOCode accepts three upper-case latin letters, as in ISO 4217.
+func ISOCode() Rule {
+	return Rule{
+		check: func(field, value string) i18n.Message {
+			if !isoCodePattern.MatchString(value) {
+				return i18n.NewMessage(controller.MessageFieldCurrencyCode, field)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// Length accepts values from min to max characters long.
+func Length(min, max int) Rule {
+	return Rule{
+		check: func(field, value string) i18n.Message {
+			n := utf8.RuneCountInString(value)
+			if n < min || n > max {
+				return i18n.NewMessage(controller.MessageFieldLength, field, min, max)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// PositiveDecimal accepts decimal numbers greater than zero.
+func PositiveDecimal() Rule {
+	return Rule{
+		numeric: true,
+		check: func(field, value string) i18n.Message {
+			if !decimalPattern.MatchString(value) {
+				return i18n.NewMessage(controller.MessageFieldIncorrectError, field)
+			}
+
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil || number <= 0 || math.IsInf(number, 0) {
+				return i18n.NewMessage(controller.MessageFieldIncorrectError, field)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// Integer accepts whole numbers from min to max.
+func Integer(min, max int64) Rule {
+	return Rule{
+		numeric: true,
+		check: func(field, value string) i18n.Message {
+			number, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || number < min || number > max {
+				return i18n.NewMessage(controller.MessageFieldRange, field, min, max)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// Enum accepts only the listed values.
+func Enum(values ...string) Rule {
+	return Rule{
+		check: func(field, value string) i18n.Message {
+			if !slices.Contains(values, value) {
+				return i18n.NewMessage(controller.MessageFieldEnum, field, strings.Join(values, ", "))
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
+
+// Check accepts values for which valid returns true.
+func Check(valid func(value string) bool) Rule {
+	return Rule{
+		check: func(field, value string) i18n.Message {
+			if !valid(value) {
+				return i18n.NewMessage(controller.MessageFieldIncorrectError, field)
+			}
+
+			return i18n.Message{}
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		value string
+		// want is the key of the message, empty if the value is valid
+		want string
+	}{
+		{name: "required", rule: Required(), value: "x"},
+		{name: "required empty", rule: Required(), value: "", want: controller.MessageFieldEmpty},
+		{name: "ISO code", rule: ISOCode(), value: "USD"},
+		{name: "ISO code lower-case", rule: ISOCode(), value: "usd", want: controller.MessageFieldCurrencyCode},
+		{name: "ISO code too long", rule: ISOCode(), value: "USDT", want: controller.MessageFieldCurrencyCode},
+		{name: "ISO code of digits", rule: ISOCode(), value: "840", want: controller.MessageFieldCurrencyCode},
+		{name: "length in characters", rule: Length(1, 2), value: "€$"},
+		{name: "length too long", rule: Length(1, 8), value: "123456789", want: controller.MessageFieldLength},
+		{name: "positive decimal", rule: PositiveDecimal(), value: "0.0001"},
+		{name: "exponent", rule: PositiveDecimal(), value: "1e-3"},
+		{name: "zero", rule: PositiveDecimal(), value: "0", want: controller.MessageFieldIncorrectError},
+		{name: "negative", rule: PositiveDecimal(), value: "-1", want: controller.MessageFieldIncorrectError},
+		{name: "comma separator", rule: PositiveDecimal(), value: "0,9", want: controller.MessageFieldIncorrectError},
+		{name: "infinite", rule: PositiveDecimal(), value: "1e400", want: controller.MessageFieldIncorrectError},
+		{name: "hexadecimal", rule: PositiveDecimal(), value: "0x10", want: controller.MessageFieldIncorrectError},
+		{name: "integer", rule: Integer(1, 10), value: "10"},
+		{name: "integer out of range", rule: Integer(1, 10), value: "11", want: controller.MessageFieldRange},
+		{name: "integer fraction", rule: Integer(1, 10), value: "1.5", want: controller.MessageFieldRange},
+		{name: "enum", rule: Enum("a", "b"), value: "b"},
+		{name: "enum other value", rule: Enum("a", "b"), value: "c", want: controller.MessageFieldEnum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rule.check("field", tt.value)
+			if got.Key != tt.want {
+				t.Errorf("message %q, want %q", got.Key, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCollectsAllErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		// want are the invalid fields in the order of the errors
+		want  []string
+		first string
+	}{
+		{name: "valid", values: map[string]string{"code": " usd ", "name": "US Dollar", "sign": "$"}},
+		{name: "all missing", values: map[string]string{}, want: []string{"name", "code", "sign"}, first: controller.MessageFieldEmpty},
+		{
+			name:   "formats",
+			values: map[string]string{"code": "DOLLAR", "name": "US Dollar", "sign": "123456789"},
+			want:   []string{"code", "sign"},
+			first:  controller.MessageFieldCurrencyCode,
+		},
+		{
+			name:   "first failing rule only",
+			values: map[string]string{"code": "  ", "name": "US Dollar", "sign": "$"},
+			want:   []string{"code"},
+			first:  controller.MessageFieldEmpty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v *Validator
+
+			// the order of the errors does not depend on the order of the map
+			for i := 0; i < 10; i++ {
+				// the fields of NewCurrencies
+				v = NewValues(
+					tt.values,
+					Field{Name: "name", Rules: []Rule{Required(), Length(1, 64)}},
+					Field{Name: "code", Rules: []Rule{Required(), ISOCode()}, Upper: true},
+					Field{Name: "sign", Rules: []Rule{Required(), Length(1, 8)}},
+				)
+				v.Validate()
+
+				got := []string{}
+				for _, fe := range v.Errors() {
+					got = append(got, fe.Field)
+				}
+
+				if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+					t.Fatalf("errors of %v, want %v", got, tt.want)
+				}
+			}
+
+			if v.ErrorMessage().Key != tt.first {
+				t.Errorf("message %q, want the one of the first field %q", v.ErrorMessage().Key, tt.first)
+			}
+
+			if v.IsValid() && v.Field("code") != "USD" {
+				t.Errorf("code %q, want it trimmed and upper-cased", v.Field("code"))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var pairPattern = regexp.MustCompile(`^[A-Z]{6}$`)
+
+func ratesStreamFields() []Field {
+	return []Field{
+		{Name: "pairs", Rules: []Rule{Check(isPairs)}, Upper: true},
+	}
+}
+
+// NewRatesStream validates the query of the rate streams, pairs is a comma separated list like USDEUR,EURRUB.
+func NewRatesStream(r *http.Request) *Validator {
+	return newValidator(r, fromQuery, ratesStreamFields()...)
+}
+
+// NewRatesStreamValues validates the pairs of a rate stream, like NewRatesStream.
+func NewRatesStreamValues(values map[string]string) *Validator {
+	return NewValues(values, ratesStreamFields()...)
+}
+
+// Pairs returns the pairs validated by NewRatesStream, none if the client asked for all pairs.
+func (v *Validator) Pairs() []string {
+	return SplitPairs(v.Field("pairs"))
+}
+
+// SplitPairs splits the comma separated list of pairs, skipping empty items.
+func SplitPairs(value string) []string {
+	pairs := []string{}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.ToUpper(strings.TrimSpace(pair))
+		if pair != "" {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
+
+// IsPair reports whether the value is a pair of currency codes like USDEUR.
+func IsPair(value string) bool {
+	return pairPattern.MatchString(value)
+}
+
+func isPairs(value string) bool {
+	for _, pair := range SplitPairs(value) {
+		if !IsPair(pair) {
+			return false
+		}
+	}
+
+	return true
+}
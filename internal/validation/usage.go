@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"net/http"
+	"time"
+)
+
+// NewUsage validates the query of the usage report, from and to are UTC dates as 2006-01-02.
+func NewUsage(r *http.Request) *Validator {
+	return NewList(
+		r,
+		nil,
+		Field{Name: "client", Rules: []Rule{Length(1, 255)}},
+		Field{Name: "from", Rules: []Rule{Check(isDate)}},
+		Field{Name: "to", Rules: []Rule{Check(isDate)}},
+	)
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse(time.DateOnly, value)
+
+	return err == nil
+}
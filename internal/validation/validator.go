@@ -0,0 +1,242 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/i18n"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field declares a request field and the rules its value must pass.
+// Rules are checked in order, the first failing rule reports the field error.
+type Field struct {
+	Name  string
+	Rules []Rule
+	// Upper upper-cases the value before validation
+	Upper bool
+}
+
+// numeric reports whether the value may be sent as a JSON number.
+func (f Field) numeric() bool {
+	for _, rule := range f.Rules {
+		if rule.numeric {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldError is the validation error of a single field.
+// Errors of the request as a whole, like a malformed body, have no Field.
+type FieldError struct {
+	Field   string
+	Message i18n.Message
+}
+
+// Errors lists field errors, unknown fields first and then the declared ones in the order of declaration.
+type Errors []FieldError
+
+// Map returns the errors as field -> message.
+func (e Errors) Map() map[string]i18n.Message {
+	m := make(map[string]i18n.Message, len(e))
+
+	for _, fe := range e {
+		if fe.Field == "" {
+			continue
+		}
+
+		if _, ok := m[fe.Field]; !ok {
+			m[fe.Field] = fe.Message
+		}
+	}
+
+	return m
+}
+
+// source reads the raw values of the fields from the request. Along with errors it returns
+// the values that could be read, or nil if the request can not be read at all.
+type source func(r *http.Request, fields []Field) (map[string]string, Errors, int)
+
+// Validator checks the request fields against their declared rules and collects all errors.
+type Validator struct {
+	r            *http.Request
+	source       source
+	fields       []Field
+	values       map[string]string
+	errors       Errors
+	errorMessage i18n.Message
+	statusCode   int
+	code         string
+}
+
+func newValidator(r *http.Request, source source, fields ...Field) *Validator {
+	return &Validator{
+		r:      r,
+		source: source,
+		fields: fields,
+		values: map[string]string{},
+	}
+}
+
+// NewValues validates values read elsewhere than from an HTTP request, e.g. from a gRPC message.
+func NewValues(values map[string]string, fields ...Field) *Validator {
+	return newValidator(
+		nil,
+		func(*http.Request, []Field) (map[string]string, Errors, int) { return values, nil, 0 },
+		fields...,
+	)
+}
+
+func (v *Validator) Validate() {
+	values, errs, statusCode := v.source(v.r, v.fields)
+	if values == nil {
+		v.fail(errs, statusCode)
+
+		return
+	}
+
+	v.errors = errs
+
+	for _, field := range v.fields {
+		value := strings.TrimSpace(values[field.Name])
+		if field.Upper {
+			value = strings.ToUpper(value)
+		}
+
+		if v.hasError(field.Name) {
+			continue
+		}
+
+		for _, rule := range field.Rules {
+			if value == "" && !rule.required {
+				continue
+			}
+
+			message := rule.check(field.Name, value)
+			if message.Key != "" {
+				v.errors = append(v.errors, FieldError{Field: field.Name, Message: message})
+
+				break
+			}
+		}
+
+		v.values[field.Name] = value
+	}
+
+	if len(v.errors) > 0 {
+		// unknown fields first, then the declared ones in declaration order
+		sort.SliceStable(v.errors, func(i, j int) bool {
+			return v.position(v.errors[i].Field) < v.position(v.errors[j].Field)
+		})
+
+		v.fail(nil, statusCode)
+	}
+}
+
+func (v *Validator) IsValid() bool {
+	return v.statusCode == 0
+}
+
+// StatusCode returns the HTTP status matching the validation error.
+func (v *Validator) StatusCode() int {
+	return v.statusCode
+}
+
+// Code returns the error code of the failed validation.
+func (v *Validator) Code() string {
+	return v.code
+}
+
+// ErrorMessage returns the message of the first error.
+func (v *Validator) ErrorMessage() i18n.Message {
+	return v.errorMessage
+}
+
+// Errors returns the errors of all invalid fields.
+func (v *Validator) Errors() Errors {
+	return v.errors
+}
+
+// Fields returns the errors of all invalid fields as field -> message.
+func (v *Validator) Fields() map[string]i18n.Message {
+	return v.errors.Map()
+}
+
+func (v *Validator) Field(field string) string {
+	return v.values[field]
+}
+
+// Split returns the distinct items of the comma separated field value, skipping empty ones.
+func (v *Validator) Split(field string) []string {
+	return splitList(v.values[field])
+}
+
+// Float returns the field value parsed as a number, the field must be validated with PositiveDecimal.
+func (v *Validator) Float(field string) float64 {
+	value, _ := strconv.ParseFloat(v.values[field], 64)
+
+	return value
+}
+
+// Int returns the field value parsed as an integer, the field must be validated with Integer.
+func (v *Validator) Int(field string) int64 {
+	value, _ := strconv.ParseInt(v.values[field], 10, 64)
+
+	return value
+}
+
+func (v *Validator) position(field string) int {
+	return slices.IndexFunc(v.fields, func(f Field) bool { return f.Name == field })
+}
+
+func (v *Validator) hasError(field string) bool {
+	for _, fe := range v.errors {
+		if fe.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *Validator) fail(errs Errors, statusCode int) {
+	if errs != nil {
+		v.errors = errs
+	}
+
+	if statusCode == 0 {
+		statusCode = http.StatusBadRequest
+	}
+
+	v.statusCode = statusCode
+
+	switch {
+	case statusCode == http.StatusRequestEntityTooLarge:
+		v.code = controller.CodeBodyTooLarge
+	case len(v.errors) == 1 && v.errors[0].Field == "":
+		v.code = controller.CodeBodyInvalid
+	default:
+		v.code = controller.CodeValidationFailed
+	}
+
+	if v.errorMessage.Key == "" && len(v.errors) > 0 {
+		v.errorMessage = v.errors[0].Message
+	}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
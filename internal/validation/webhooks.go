@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// NewWebhooks validates the body of a new webhook, events is a comma separated list of event types,
+// none standing for all of them. The secret is generated if not given.
+func NewWebhooks(r *http.Request) *Validator {
+	return newValidator(
+		r,
+		fromBody,
+		Field{Name: "url", Rules: []Rule{Required(), Length(1, 2048), Check(isWebhookURL)}},
+		Field{Name: "events", Rules: []Rule{Check(isEventTypes)}},
+		Field{Name: "secret", Rules: []Rule{Length(16, 255)}},
+	)
+}
+
+// NewWebhookDeliveries validates the query of the delivery log of a webhook.
+func NewWebhookDeliveries(r *http.Request) *Validator {
+	return NewList(
+		r,
+		nil,
+		Field{
+			Name:  "status",
+			Rules: []Rule{Enum(entity.DeliveryPending, entity.DeliveryDelivered, entity.DeliveryDead)},
+		},
+	)
+}
+
+func isWebhookURL(value string) bool {
+	u, err := url.Parse(value)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isEventTypes(value string) bool {
+	for _, eventType := range splitList(value) {
+		if !slices.Contains(events.Types, events.Type(eventType)) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func formRequest(form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return r
+}
+
+// invalidFields returns the sorted names of the fields failing validation.
+func invalidFields(v *Validator) []string {
+	names := []string{}
+	for name := range v.Fields() {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func TestNewWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		invalid []string
+	}{
+		{name: "all events", form: url.Values{"url": {"https://example.com/hook"}}, invalid: []string{}},
+		{
+			name:    "listed events",
+			form:    url.Values{"url": {"http://example.com"}, "events": {"currency.created, rate.updated"}},
+			invalid: []string{},
+		},
+		{name: "no url", form: url.Values{}, invalid: []string{"url"}},
+		{name: "url without a host", form: url.Values{"url": {"https://"}}, invalid: []string{"url"}},
+		{name: "url of another scheme", form: url.Values{"url": {"ftp://example.com"}}, invalid: []string{"url"}},
+		{
+			name:    "unknown event",
+			form:    url.Values{"url": {"https://example.com"}, "events": {"rate.created,rate.deleted"}},
+			invalid: []string{"events"},
+		},
+		{
+			name:    "short secret",
+			form:    url.Values{"url": {"https://example.com"}, "secret": {"short"}},
+			invalid: []string{"secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewWebhooks(formRequest(tt.form))
+			v.Validate()
+
+			if got := invalidFields(v); strings.Join(got, ",") != strings.Join(tt.invalid, ",") {
+				t.Errorf("invalid fields %v, want %v", got, tt.invalid)
+			}
+
+			if v.IsValid() != (len(tt.invalid) == 0) {
+				t.Errorf("valid %t with the invalid fields %v", v.IsValid(), tt.invalid)
+			}
+		})
+	}
+}
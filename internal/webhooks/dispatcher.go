@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/controller"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/logging"
+	"github.com/albakov/go-currency-exchange/internal/storage/webhooks"
+	"io"
+	"net/http"
+	"time"
+)
+
+const f = "webhooks.Dispatcher"
+
+// Headers of the deliveries. The signature is "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">",
+// keyed with the secret of the webhook.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	batchSize    = 20
+	pollInterval = time.Second
+	maxBackoff   = time.Hour
+	// maxResponseSize is the part of the response body read before the connection is reused
+	maxResponseSize = 64 << 10
+)
+
+// Dispatcher queues the events for the webhooks in the transactions of their changes and delivers them once
+// published. A failed delivery is retried with exponentially growing delays, after the last attempt it is moved
+// to the dead-letter list.
+type Dispatcher struct {
+	storage     webhooks.StorageWebhooks
+	client      *http.Client
+	maxAttempts int64
+	backoff     time.Duration
+	wake        chan struct{}
+}
+
+// payload is the body of the deliveries, Data is the currency or the rate in the v2 contract.
+type payload struct {
+	ID         string      `json:"id"`
+	Type       events.Type `json:"type"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+func New(config *config.Config, storage webhooks.StorageWebhooks) *Dispatcher {
+	return &Dispatcher{
+		storage:     storage,
+		client:      &http.Client{Timeout: time.Duration(config.WebhookTimeout) * time.Second},
+		maxAttempts: config.WebhookMaxAttempts,
+		backoff:     time.Duration(config.WebhookBackoff) * time.Second,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues the event for the webhooks receiving its type in tx, the transaction writing its change.
+func (d *Dispatcher) Enqueue(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	const op = "Enqueue"
+
+	var data interface{} = event.ExchangeRate
+	if event.Type == events.CurrencyCreated {
+		data = event.Currency
+	}
+
+	id := randomHex(16)
+
+	body, err := json.Marshal(payload{
+		ID:         id,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       controller.Present(controller.V2, data),
+	})
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return err
+	}
+
+	_, err = d.storage.Enqueue(ctx, tx, entity.WebhookDelivery{
+		EventID:   id,
+		EventType: string(event.Type),
+		Payload:   body,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Publish starts the delivery of the deliveries queued for the committed event.
+func (d *Dispatcher) Publish(events.Event) {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the due deliveries until ctx is done. The queue is kept in the storage,
+// so the deliveries left pending on shutdown are sent on the next run.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	const op = "dispatch"
+
+	for {
+		deliveries, err := d.storage.Due(time.Now(), batchSize)
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+
+			return
+		}
+
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+
+			d.deliver(ctx, delivery)
+		}
+
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery entity.WebhookDelivery) {
+	const op = "deliver"
+
+	now := time.Now().UTC()
+
+	status, err := d.send(ctx, delivery, now)
+	if ctx.Err() != nil {
+		// interrupted by the shutdown, the attempt is repeated on the next run
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	delivery.Status = entity.DeliveryDelivered
+
+	if err != nil {
+		delivery.LastError = err.Error()
+		delivery.Status = entity.DeliveryPending
+		delivery.NextAttemptAt = now.Add(d.backoffAfter(delivery.Attempts))
+
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = entity.DeliveryDead
+		}
+	}
+
+	err = d.storage.UpdateDelivery(delivery)
+	if err != nil {
+		logging.ErrorContext(ctx, f, op, err)
+	}
+}
+
+// send posts the payload and returns the response status, a status other than 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery entity.WebhookDelivery, now time.Time) (int, error) {
+	const op = "send"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "currency-exchange-webhooks")
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, now, delivery.Payload))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.EventID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func(body io.ReadCloser) {
+		err := body.Close()
+		if err != nil {
+			logging.ErrorContext(ctx, f, op, err)
+		}
+	}(resp.Body)
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoffAfter returns the delay after the attempt, doubling the configured backoff with every attempt.
+func (d *Dispatcher) backoffAfter(attempts int64) time.Duration {
+	backoff := d.backoff
+
+	for i := int64(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// Sign returns the signature header of the body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	_, _ = mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// NewSecret returns a random secret of 64 hex digits.
+func NewSecret() string {
+	return randomHex(32)
+}
+
+func randomHex(size int) string {
+	b := make([]byte, size)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
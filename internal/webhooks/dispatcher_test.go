@@ -0,0 +1,321 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-currency-exchange/dbinit"
+	"github.com/albakov/go-currency-exchange/internal/config"
+	"github.com/albakov/go-currency-exchange/internal/entity"
+	"github.com/albakov/go-currency-exchange/internal/events"
+	"github.com/albakov/go-currency-exchange/internal/storage"
+	storageCurrencies "github.com/albakov/go-currency-exchange/internal/storage/currencies"
+	storageExchangeRates "github.com/albakov/go-currency-exchange/internal/storage/exchangerates"
+	"github.com/albakov/go-currency-exchange/internal/storage/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "test-secret"
+
+// delivery is a request received by the receiver stub.
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is the endpoint of a webhook responding with status and passing on the requests it receives.
+func receiver(t *testing.T, status int) (*httptest.Server, chan delivery) {
+	t.Helper()
+
+	received := make(chan delivery, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		received <- delivery{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, received
+}
+
+// fixture is a dispatcher of a new database with a webhook receiving all events.
+type fixture struct {
+	dispatcher *Dispatcher
+	webhooks   *webhooks.Webhooks
+	webhookID  int64
+	pathToDb   string
+}
+
+func setup(t *testing.T, url string, maxAttempts int64) fixture {
+	t.Helper()
+
+	c := &config.Config{
+		PathToDB: filepath.Join(t.TempDir(), "sqlite.db"),
+		Webhooks: config.Webhooks{WebhookMaxAttempts: maxAttempts, WebhookBackoff: 30, WebhookTimeout: 5},
+	}
+
+	err := dbinit.New(c).CreateDatabaseIfNotExists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storageWebhooks := webhooks.New(c.PathToDB)
+
+	id, err := storageWebhooks.Add(entity.Webhook{URL: url, Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fixture{
+		dispatcher: New(c, storageWebhooks),
+		webhooks:   storageWebhooks,
+		webhookID:  id,
+		pathToDb:   c.PathToDB,
+	}
+}
+
+// verify checks the signature header of the body the way receivers are documented to.
+func verify(header string, body []byte) bool {
+	timestamp, signature, found := strings.Cut(header, ",v1=")
+	if !found || !strings.HasPrefix(timestamp, "t=") {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.TrimPrefix(timestamp, "t=") + "."))
+	mac.Write(body)
+
+	expected, err := hex.DecodeString(signature)
+
+	return err == nil && hmac.Equal(mac.Sum(nil), expected)
+}
+
+func TestDeliversSignedEvents(t *testing.T) {
+	server, received := receiver(t, http.StatusNoContent)
+	fx := setup(t, server.URL, 3)
+
+	currencies := storageCurrencies.New(fx.pathToDb, fx.dispatcher, fx.dispatcher)
+
+	_, err := currencies.Add(context.Background(), entity.Currency{Code: "USD", FullName: "US Dollar", Sign: "$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go fx.dispatcher.Run(ctx)
+
+	var got delivery
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+
+	if got.header.Get(EventHeader) != string(events.CurrencyCreated) {
+		t.Errorf("event header %q, want %s", got.header.Get(EventHeader), events.CurrencyCreated)
+	}
+
+	if !verify(got.header.Get(SignatureHeader), got.body) {
+		t.Errorf("signature %q does not verify the body", got.header.Get(SignatureHeader))
+	}
+
+	if verify(got.header.Get(SignatureHeader), append(got.body, ' ')) {
+		t.Error("signature verifies a changed body")
+	}
+
+	signedAt, _, _ := strings.Cut(strings.TrimPrefix(got.header.Get(SignatureHeader), "t="), ",")
+	timestamp, _ := strconv.ParseInt(signedAt, 10, 64)
+	if time.Since(time.Unix(timestamp, 0)).Abs() > time.Minute {
+		t.Errorf("signature timestamp %d is not the time of sending", timestamp)
+	}
+
+	sent := payload{}
+
+	err = json.Unmarshal(got.body, &sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.ID != got.header.Get(DeliveryHeader) || sent.Type != events.CurrencyCreated {
+		t.Errorf("payload %+v does not match the headers %v", sent, got.header)
+	}
+
+	data, _ := sent.Data.(map[string]interface{})
+	if data["code"] != "USD" || data["fullName"] != "US Dollar" {
+		t.Errorf("data %v, want the currency in the v2 contract", sent.Data)
+	}
+
+	// the outcome is stored right after the response
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, _, err := fx.webhooks.Deliveries(fx.webhookID, webhooks.DeliveriesFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(deliveries) == 1 && deliveries[0].Status == entity.DeliveryDelivered {
+			if deliveries[0].ResponseStatus != http.StatusNoContent || deliveries[0].Attempts != 1 {
+				t.Errorf("delivery %+v, want delivered on the first attempt", deliveries[0])
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries %+v, want one delivered", deliveries)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRetriesFailedDeliveriesUntilDead(t *testing.T) {
+	server, received := receiver(t, http.StatusInternalServerError)
+	fx := setup(t, server.URL, 2)
+
+	exchangeRates := storageExchangeRates.New(fx.pathToDb, nil, fx.dispatcher)
+	currencies := storageCurrencies.New(fx.pathToDb, nil, nil)
+	base, target := addPair(t, currencies)
+
+	_, err := exchangeRates.Add(context.Background(), entity.ExchangeRates{
+		BaseCurrency:   base,
+		TargetCurrency: target,
+		Rate:           0.9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fx.dispatcher.dispatch(context.Background())
+	<-received
+
+	deliveries, _, err := fx.webhooks.Deliveries(fx.webhookID, webhooks.DeliveriesFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 {
+		t.Fatalf("deliveries %+v, want one", deliveries)
+	}
+
+	first := deliveries[0]
+	if first.Status != entity.DeliveryPending || first.Attempts != 1 || first.ResponseStatus != 500 ||
+		first.LastError == "" || first.EventType != string(events.RateCreated) {
+		t.Errorf("delivery %+v, want pending after the failed attempt", first)
+	}
+
+	if first.NextAttemptAt.Before(time.Now().Add(20 * time.Second)) {
+		t.Errorf("next attempt at %s, want after the backoff", first.NextAttemptAt)
+	}
+
+	// the retry is not due before the backoff
+	fx.dispatcher.dispatch(context.Background())
+
+	select {
+	case <-received:
+		t.Fatal("delivery retried before the backoff")
+	default:
+	}
+
+	due, err := fx.webhooks.Due(first.NextAttemptAt, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("due deliveries %+v, %v, want the failed one", due, err)
+	}
+
+	fx.dispatcher.deliver(context.Background(), due[0])
+	<-received
+
+	deliveries, _, err = fx.webhooks.Deliveries(fx.webhookID, webhooks.DeliveriesFilter{Status: entity.DeliveryDead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Attempts != 2 {
+		t.Errorf("dead deliveries %+v, want the delivery after its last attempt", deliveries)
+	}
+}
+
+// failingOutbox fails to queue every event.
+type failingOutbox struct{}
+
+func (failingOutbox) Enqueue(context.Context, *sql.Tx, events.Event) error {
+	return errors.New("outbox unavailable")
+}
+
+func TestQueuesEventsOnlyWithTheirChanges(t *testing.T) {
+	server, _ := receiver(t, http.StatusNoContent)
+	fx := setup(t, server.URL, 3)
+
+	currencies := storageCurrencies.New(fx.pathToDb, nil, fx.dispatcher)
+	base, target := addPair(t, currencies)
+
+	// a change rolled back queues no event
+	_, err := currencies.Add(context.Background(), entity.Currency{Code: "USD", FullName: "Again", Sign: "$"})
+	if !errors.Is(err, storage.EntityAlreadyExistsError) {
+		t.Fatalf("error %v, want EntityAlreadyExistsError", err)
+	}
+
+	// a change whose event cannot be queued is rolled back
+	exchangeRates := storageExchangeRates.New(fx.pathToDb, nil, failingOutbox{})
+
+	_, err = exchangeRates.Add(context.Background(), entity.ExchangeRates{
+		BaseCurrency:   base,
+		TargetCurrency: target,
+		Rate:           0.9,
+	})
+	if err == nil {
+		t.Fatal("rate added although its event was not queued")
+	}
+
+	_, err = exchangeRates.ByBaseCurrencyIdAndTargetCurrencyId(context.Background(), base.ID, target.ID)
+	if !errors.Is(err, storage.EntitiesNotFoundError) {
+		t.Errorf("error %v, want the rate not stored", err)
+	}
+
+	deliveries, _, err := fx.webhooks.Deliveries(fx.webhookID, webhooks.DeliveriesFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 2 {
+		t.Errorf("deliveries %+v, want the two currencies added", deliveries)
+	}
+}
+
+// addPair adds USD and EUR and returns them.
+func addPair(t *testing.T, currencies *storageCurrencies.Currencies) (entity.Currency, entity.Currency) {
+	t.Helper()
+
+	pair := []entity.Currency{
+		{Code: "USD", FullName: "US Dollar", Sign: "$"},
+		{Code: "EUR", FullName: "Euro", Sign: "€"},
+	}
+
+	for i := range pair {
+		id, err := currencies.Add(context.Background(), pair[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pair[i].ID = id
+	}
+
+	return pair[0], pair[1]
+}